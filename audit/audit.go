@@ -0,0 +1,258 @@
+// Package audit records who changed what in the services of the store, and
+// when, from which client and with which values before and after.
+package audit
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joesjo/grpc-store/authentication/security"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	mongouri     = "mongodb://localhost:2717"
+	databaseName = "store"
+	auditfile    = "audit.jsonl"
+
+	storeSink = "store"
+	fileSink  = "file"
+
+	// The metadata keys a proxy passes on about the client it calls on
+	// behalf of. They are only recorded for the proxies trusted with
+	// AUDIT_TRUSTED_PROXIES.
+	ClientMetadataKey    = "client-address"
+	UserAgentMetadataKey = "client-user-agent"
+)
+
+// Entry is a change recorded in the audit log. Before and After hold the
+// entity as JSON, and are empty when it did not exist. Failed calls are
+// recorded with their error and without an After value.
+type Entry struct {
+	Id                 string    `bson:"_id" json:"id"`
+	Time               time.Time `bson:"time" json:"time"`
+	Service            string    `bson:"service" json:"service"`
+	Action             string    `bson:"action" json:"action"`
+	Actor              string    `bson:"actor,omitempty" json:"actor,omitempty"`
+	Client             string    `bson:"client,omitempty" json:"client,omitempty"`
+	UserAgent          string    `bson:"useragent,omitempty" json:"userAgent,omitempty"`
+	ForwardedFor       string    `bson:"forwardedfor,omitempty" json:"forwardedFor,omitempty"`
+	ForwardedUserAgent string    `bson:"forwardeduseragent,omitempty" json:"forwardedUserAgent,omitempty"`
+	EntityType         string    `bson:"entitytype" json:"entityType"`
+	EntityId           string    `bson:"entityid,omitempty" json:"entityId,omitempty"`
+	Before             string    `bson:"before,omitempty" json:"before,omitempty"`
+	After              string    `bson:"after,omitempty" json:"after,omitempty"`
+	Error              string    `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// NewEntry starts an entry for a call to service made with ctx, filling in
+// who made it and from where. The actor is the user whose token the call
+// carried, as verified by the security interceptors, and the client is the
+// peer that made the call. The client a trusted proxy calls on behalf of is
+// kept apart, in ForwardedFor. Times are kept to milliseconds, the
+// precision MongoDB stores them with.
+func NewEntry(ctx context.Context, service string, action string, entityType string, entityId string) *Entry {
+	entry := &Entry{
+		Id:         primitive.NewObjectID().Hex(),
+		Time:       time.Now().UTC().Truncate(time.Millisecond),
+		Service:    service,
+		Action:     action,
+		EntityType: entityType,
+		EntityId:   entityId,
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	entry.Actor = security.Username(ctx)
+	entry.UserAgent = first(md, "user-agent")
+	if p, ok := peer.FromContext(ctx); ok {
+		entry.Client = p.Addr.String()
+		if trustedProxy(p.Addr) {
+			entry.ForwardedFor = first(md, ClientMetadataKey)
+			entry.ForwardedUserAgent = first(md, UserAgentMetadataKey)
+		}
+	}
+	return entry
+}
+
+// trustedProxy reports whether addr is one of the proxies named in the
+// comma-separated AUDIT_TRUSTED_PROXIES, by address or by a host name that
+// resolves to it.
+func trustedProxy(addr net.Addr) bool {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range strings.Split(os.Getenv("AUDIT_TRUSTED_PROXIES"), ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		hosts := []string{proxy}
+		if net.ParseIP(proxy) == nil {
+			if hosts, err = net.LookupHost(proxy); err != nil {
+				continue
+			}
+		}
+		for _, host := range hosts {
+			if ip.Equal(net.ParseIP(host)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Query selects entries of the audit log, newest first. Empty fields and
+// zero times match every entry; From is inclusive and To exclusive. A zero
+// Limit means no limit.
+type Query struct {
+	Actor      string
+	EntityType string
+	EntityId   string
+	From       time.Time
+	To         time.Time
+	Limit      int
+	PageToken  string
+}
+
+// Sink stores audit entries. Query returns the entries after the page
+// token and the token of the next page, empty on the last page.
+type Sink interface {
+	Record(entry *Entry) error
+	Query(query Query) ([]*Entry, string, error)
+}
+
+// Init creates the sink selected by the AUDIT_SINK environment variable.
+// The default, store, keeps entries where the service keeps its data: in
+// the audit collection for the MongoDB backend and in memory for the memory
+// backend. The file sink appends them as JSON lines to AUDIT_FILE, which
+// services can share; it is also used by the store sink of the file
+// backend.
+func Init() (Sink, error) {
+	sink, exists := os.LookupEnv("AUDIT_SINK")
+	if !exists || sink == "" {
+		sink = storeSink
+	}
+	path, exists := os.LookupEnv("AUDIT_FILE")
+	if !exists {
+		path = auditfile
+	}
+	switch sink {
+	case storeSink:
+		switch os.Getenv("STORE_BACKEND") {
+		case "memory":
+			return NewMemorySink(), nil
+		case "file":
+			return NewFileSink(path)
+		}
+		url, exists := os.LookupEnv("MONGO_URI")
+		if !exists {
+			url = mongouri
+		}
+		return NewMongoSink(url)
+	case fileSink:
+		return NewFileSink(path)
+	}
+	return nil, fmt.Errorf("unknown audit sink: %s", sink)
+}
+
+// cursor is the decoded form of a page token: the time and id of the last
+// entry handed out.
+type cursor struct {
+	Time time.Time `json:"time"`
+	Id   string    `json:"id"`
+}
+
+func encodePageToken(entry *Entry) string {
+	data, _ := json.Marshal(cursor{Time: entry.Time, Id: entry.Id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodePageToken(token string) (*cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+	invalid := status.Errorf(codes.InvalidArgument, "invalid page token")
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, invalid
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, invalid
+	}
+	return &c, nil
+}
+
+// newer reports whether a comes before b in the audit log, newest first.
+func newer(a *Entry, b *Entry) bool {
+	if !a.Time.Equal(b.Time) {
+		return a.Time.After(b.Time)
+	}
+	return a.Id > b.Id
+}
+
+// matches reports whether entry is selected by query and comes after the
+// page cursor.
+func matches(entry *Entry, query Query, after *cursor) bool {
+	if query.Actor != "" && entry.Actor != query.Actor {
+		return false
+	}
+	if query.EntityType != "" && entry.EntityType != query.EntityType {
+		return false
+	}
+	if query.EntityId != "" && entry.EntityId != query.EntityId {
+		return false
+	}
+	if !query.From.IsZero() && entry.Time.Before(query.From) {
+		return false
+	}
+	if !query.To.IsZero() && !entry.Time.Before(query.To) {
+		return false
+	}
+	return after == nil || newer(&Entry{Time: after.Time, Id: after.Id}, entry)
+}
+
+// page selects the entries of query, newest first, from the entries of a
+// sink that filters in memory.
+func page(entries []*Entry, query Query) ([]*Entry, string, error) {
+	after, err := decodePageToken(query.PageToken)
+	if err != nil {
+		return nil, "", err
+	}
+	var result []*Entry
+	for _, entry := range entries {
+		if matches(entry, query, after) {
+			result = append(result, entry)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return newer(result[i], result[j])
+	})
+	if query.Limit > 0 && len(result) > query.Limit {
+		result = result[:query.Limit]
+		return result, encodePageToken(result[len(result)-1]), nil
+	}
+	return result, "", nil
+}
@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestNewEntryClient(t *testing.T) {
+	forwarded := metadata.Pairs(
+		ClientMetadataKey, "203.0.113.7:4000",
+		UserAgentMetadataKey, "browser",
+		"user-agent", "grpc-go")
+	tests := []struct {
+		name          string
+		trusted       string
+		md            metadata.MD
+		wantForwarded string
+		wantUserAgent string
+	}{
+		{"untrusted peer", "", forwarded, "", ""},
+		{"other trusted proxy", "10.0.0.9", forwarded, "", ""},
+		{"trusted proxy", "10.0.0.9, 10.0.0.2", forwarded, "203.0.113.7:4000", "browser"},
+		{"trusted proxy by name", "localhost", forwarded, "", ""},
+		{"trusted proxy without a client", "10.0.0.2", metadata.Pairs("user-agent", "grpc-go"), "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("AUDIT_TRUSTED_PROXIES", test.trusted)
+			ctx := metadata.NewIncomingContext(context.Background(), test.md)
+			ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 5000}})
+			entry := NewEntry(ctx, "inventory", "UpdateItem", "item", "1")
+			if entry.Client != "10.0.0.2:5000" || entry.UserAgent != "grpc-go" {
+				t.Errorf("recorded client %q with user agent %q, want the peer", entry.Client, entry.UserAgent)
+			}
+			if entry.ForwardedFor != test.wantForwarded || entry.ForwardedUserAgent != test.wantUserAgent {
+				t.Errorf("recorded forwarded client %q with user agent %q, want %q with %q",
+					entry.ForwardedFor, entry.ForwardedUserAgent, test.wantForwarded, test.wantUserAgent)
+			}
+		})
+	}
+}
+
+func TestNewEntryTrustsProxiesByName(t *testing.T) {
+	t.Setenv("AUDIT_TRUSTED_PROXIES", "localhost")
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(ClientMetadataKey, "203.0.113.7:4000"))
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5000}})
+	if entry := NewEntry(ctx, "inventory", "UpdateItem", "item", "1"); entry.ForwardedFor != "203.0.113.7:4000" {
+		t.Errorf("recorded forwarded client %q from a proxy trusted by name", entry.ForwardedFor)
+	}
+}
@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// FileSink appends entries to a file as JSON lines. Each entry is written
+// with a single append, so several services may share the file.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: file}, nil
+}
+
+func (s *FileSink) Record(entry *Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+// Query reads the whole file. Lines that cannot be parsed, such as one
+// being written, are skipped.
+func (s *FileSink) Query(query Query) ([]*Entry, string, error) {
+	file, err := os.Open(s.file.Name())
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+	var entries []*Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var entry Entry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, &entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, "", err
+	}
+	return page(entries, query)
+}
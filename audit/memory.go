@@ -0,0 +1,26 @@
+package audit
+
+import "sync"
+
+// MemorySink keeps entries in memory, for the memory backends.
+type MemorySink struct {
+	mu      sync.RWMutex
+	entries []*Entry
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (s *MemorySink) Record(entry *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *MemorySink) Query(query Query) ([]*Entry, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return page(s.entries, query)
+}
@@ -0,0 +1,106 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const collectionName = "audit"
+
+// MongoSink keeps entries in the audit collection of the store database.
+type MongoSink struct {
+	client     *mongo.Client
+	collection *mongo.Collection
+}
+
+func NewMongoSink(url string) (*MongoSink, error) {
+	client, err := mongo.NewClient(options.Client().ApplyURI(url))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = client.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = client.Ping(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to mongodb server on: %s", url)
+	}
+	collection := client.Database(databaseName).Collection(collectionName)
+	_, err = collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "time", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "actor", Value: 1}, {Key: "time", Value: -1}}},
+		{Keys: bson.D{{Key: "entitytype", Value: 1}, {Key: "entityid", Value: 1}, {Key: "time", Value: -1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &MongoSink{client: client, collection: collection}, nil
+}
+
+func (s *MongoSink) Record(entry *Entry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := s.collection.InsertOne(ctx, entry)
+	return err
+}
+
+func (s *MongoSink) Query(query Query) ([]*Entry, string, error) {
+	after, err := decodePageToken(query.PageToken)
+	if err != nil {
+		return nil, "", err
+	}
+	filter := bson.D{}
+	if query.Actor != "" {
+		filter = append(filter, bson.E{Key: "actor", Value: query.Actor})
+	}
+	if query.EntityType != "" {
+		filter = append(filter, bson.E{Key: "entitytype", Value: query.EntityType})
+	}
+	if query.EntityId != "" {
+		filter = append(filter, bson.E{Key: "entityid", Value: query.EntityId})
+	}
+	between := bson.D{}
+	if !query.From.IsZero() {
+		between = append(between, bson.E{Key: "$gte", Value: query.From})
+	}
+	if !query.To.IsZero() {
+		between = append(between, bson.E{Key: "$lt", Value: query.To})
+	}
+	if len(between) > 0 {
+		filter = append(filter, bson.E{Key: "time", Value: between})
+	}
+	if after != nil {
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "time", Value: bson.D{{Key: "$lt", Value: after.Time}}}},
+			bson.D{{Key: "time", Value: after.Time}, {Key: "_id", Value: bson.D{{Key: "$lt", Value: after.Id}}}},
+		}})
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "time", Value: -1}, {Key: "_id", Value: -1}})
+	if query.Limit > 0 {
+		// One more than asked for tells whether there is a next page.
+		opts.SetLimit(int64(query.Limit) + 1)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cursor, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	var entries []*Entry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, "", err
+	}
+	if query.Limit > 0 && len(entries) > query.Limit {
+		entries = entries[:query.Limit]
+		return entries, encodePageToken(entries[len(entries)-1]), nil
+	}
+	return entries, "", nil
+}
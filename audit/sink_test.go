@@ -0,0 +1,161 @@
+package audit
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testSinks returns a fresh sink of every backend that needs no server.
+func testSinks() map[string]func(t *testing.T) Sink {
+	return map[string]func(t *testing.T) Sink{
+		"memory": func(t *testing.T) Sink {
+			return NewMemorySink()
+		},
+		"file": func(t *testing.T) Sink {
+			sink, err := NewFileSink(filepath.Join(t.TempDir(), "audit.jsonl"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return sink
+		},
+	}
+}
+
+// recordEntries records one entry a minute from start, alternating between
+// two actors, and returns their ids oldest first.
+func recordEntries(t *testing.T, sink Sink, start time.Time, count int) []string {
+	t.Helper()
+	var ids []string
+	for i := 0; i < count; i++ {
+		entry := &Entry{
+			Id:         strconv.Itoa(100 + i),
+			Time:       start.Add(time.Duration(i) * time.Minute),
+			Service:    "inventory",
+			Action:     "UpdateItem",
+			Actor:      []string{"alice", "bob"}[i%2],
+			EntityType: "item",
+			EntityId:   "item-" + strconv.Itoa(i%3),
+		}
+		if err := sink.Record(entry); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, entry.Id)
+	}
+	return ids
+}
+
+func entryIds(entries []*Entry) []string {
+	var ids []string
+	for _, entry := range entries {
+		ids = append(ids, entry.Id)
+	}
+	return ids
+}
+
+func equalIds(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSinkQuery(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"everything, newest first", Query{}, []string{"105", "104", "103", "102", "101", "100"}},
+		{"actor", Query{Actor: "bob"}, []string{"105", "103", "101"}},
+		{"entity", Query{EntityType: "item", EntityId: "item-1"}, []string{"104", "101"}},
+		{"other entity type", Query{EntityType: "user"}, nil},
+		{"time range", Query{From: start.Add(time.Minute), To: start.Add(3 * time.Minute)}, []string{"102", "101"}},
+		{"limit", Query{Limit: 2}, []string{"105", "104"}},
+	}
+	for backend, newSink := range testSinks() {
+		t.Run(backend, func(t *testing.T) {
+			sink := newSink(t)
+			recordEntries(t, sink, start, 6)
+			for _, test := range tests {
+				t.Run(test.name, func(t *testing.T) {
+					entries, _, err := sink.Query(test.query)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if got := entryIds(entries); !equalIds(got, test.want) {
+						t.Errorf("got entries %v, want %v", got, test.want)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestSinkPages(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for backend, newSink := range testSinks() {
+		t.Run(backend, func(t *testing.T) {
+			sink := newSink(t)
+			ids := recordEntries(t, sink, start, 5)
+			// Entries at the same time are told apart by their ids.
+			if err := sink.Record(&Entry{Id: "099", Time: start, EntityType: "item"}); err != nil {
+				t.Fatal(err)
+			}
+			want := []string{ids[4], ids[3], ids[2], ids[1], ids[0], "099"}
+			var got []string
+			query := Query{Limit: 4}
+			for pages := 0; ; pages++ {
+				if pages > len(want) {
+					t.Fatal("the pages do not end")
+				}
+				entries, next, err := sink.Query(query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, entryIds(entries)...)
+				if next == "" {
+					break
+				}
+				query.PageToken = next
+			}
+			if !equalIds(got, want) {
+				t.Errorf("paged through %v, want %v", got, want)
+			}
+			_, _, err := sink.Query(Query{PageToken: "not a token"})
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("got error %v for an invalid page token, want InvalidArgument", err)
+			}
+		})
+	}
+}
+
+func TestFileSinkPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := recordEntries(t, sink, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), 2)
+	reopened, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, _, err := reopened.Query(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := entryIds(entries); !equalIds(got, []string{ids[1], ids[0]}) {
+		t.Errorf("reopened sink has entries %v", got)
+	}
+}
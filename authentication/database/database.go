@@ -1,78 +1,72 @@
 package database
 
 import (
-	"context"
-	"log"
+	"errors"
+	"fmt"
 	"os"
-	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const (
 	mongouri       = "mongodb://localhost:2717"
 	databaseName   = "store"
 	collectionName = "users"
+	userfile       = "users.json"
+
+	MongoBackend  = "mongo"
+	MemoryBackend = "memory"
+	FileBackend   = "file"
 )
 
 var (
-	client     *mongo.Client
-	collection *mongo.Collection
-	err        error
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserExists   = errors.New("user already exists")
 )
 
 type User struct {
-	Id       primitive.ObjectID `bson:"_id,omitempty"`
-	Username string             `bson:"username"`
-	Password string             `bson:"password"`
+	Id       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Username string             `bson:"username" json:"username"`
+	Password string             `bson:"password" json:"password"`
 }
 
-func Init() {
-	url, exists := os.LookupEnv("MONGO_URI")
-	if !exists {
-		url = mongouri
-	}
-	client, err = mongo.NewClient(options.Client().ApplyURI(url))
-	if err != nil {
-		log.Fatal(err)
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	err = client.Connect(ctx)
-	if err != nil {
-		log.Fatal(err)
-	}
-	err = client.Ping(ctx, nil)
-	if err != nil {
-		log.Fatal("Could not connect to mongodb server on: ", mongouri)
-	}
-	collection = client.Database(databaseName).Collection(collectionName)
+// UserStore is the persistence layer behind the authentication service.
+// CreateUser returns ErrUserExists when a user has the given username.
+// FindUser returns ErrUserNotFound when no user has the given username.
+type UserStore interface {
+	CreateUser(username string, password string) error
+	FindUser(username string) (*User, error)
 }
 
-func CreateUser(username string, password string) error {
-	user := User{
-		Username: username,
-		Password: password,
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	_, err = collection.InsertOne(ctx, user)
-	if err != nil {
-		return err
+// Init creates the user store selected by the STORE_BACKEND environment
+// variable, defaulting to MongoDB.
+func Init() (UserStore, error) {
+	backend, exists := os.LookupEnv("STORE_BACKEND")
+	if !exists {
+		backend = MongoBackend
 	}
-	return nil
-}
-
-func FindUser(username string) (*User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	var user User
-	err = collection.FindOne(ctx, bson.M{"username": username}).Decode(&user)
-	if err != nil {
-		return nil, err
+	switch backend {
+	case MongoBackend:
+		url, exists := os.LookupEnv("MONGO_URI")
+		if !exists {
+			url = mongouri
+		}
+		store, err := NewMongoStore(url)
+		if err != nil {
+			return nil, err
+		}
+		if err := store.createIndexes(); err != nil {
+			return nil, err
+		}
+		return store, nil
+	case MemoryBackend:
+		return NewMemoryStore(), nil
+	case FileBackend:
+		path, exists := os.LookupEnv("STORE_FILE")
+		if !exists {
+			path = userfile
+		}
+		return NewFileStore(path)
 	}
-	return &user, nil
+	return nil, fmt.Errorf("unknown store backend: %s", backend)
 }
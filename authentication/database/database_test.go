@@ -0,0 +1,184 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testStores returns a fresh store of every backend, with a prefix for the
+// usernames the test uses. The MongoDB store is only tested when
+// MONGO_TEST_URI names a server to test against; the users the test creates
+// there are deleted afterwards.
+func testStores() map[string]func(t *testing.T) (UserStore, string) {
+	return map[string]func(t *testing.T) (UserStore, string){
+		"memory": func(t *testing.T) (UserStore, string) {
+			return NewMemoryStore(), ""
+		},
+		"file": func(t *testing.T) (UserStore, string) {
+			store, err := NewFileStore(filepath.Join(t.TempDir(), "users.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return store, ""
+		},
+		"mongo": func(t *testing.T) (UserStore, string) {
+			url, exists := os.LookupEnv("MONGO_TEST_URI")
+			if !exists {
+				t.Skip("MONGO_TEST_URI is not set")
+			}
+			store, err := NewMongoStore(url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.createIndexes(); err != nil {
+				t.Fatal(err)
+			}
+			prefix := "test-" + primitive.NewObjectID().Hex() + "-"
+			t.Cleanup(func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				filter := bson.M{"username": bson.M{"$regex": "^" + prefix}}
+				if _, err := store.collection.DeleteMany(ctx, filter); err != nil {
+					t.Error(err)
+				}
+				store.client.Disconnect(ctx)
+			})
+			return store, prefix
+		},
+	}
+}
+
+func TestUserStores(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, store UserStore, prefix string)
+	}{
+		{"create and find", func(t *testing.T, store UserStore, prefix string) {
+			if err := store.CreateUser(prefix+"alice", "hash"); err != nil {
+				t.Fatal(err)
+			}
+			user, err := store.FindUser(prefix + "alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.Username != prefix+"alice" || user.Password != "hash" {
+				t.Errorf("found %+v", user)
+			}
+		}},
+		{"find missing", func(t *testing.T, store UserStore, prefix string) {
+			if _, err := store.FindUser(prefix + "nobody"); err != ErrUserNotFound {
+				t.Errorf("got error %v, want %v", err, ErrUserNotFound)
+			}
+		}},
+		{"create existing", func(t *testing.T, store UserStore, prefix string) {
+			if err := store.CreateUser(prefix+"alice", "first"); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.CreateUser(prefix+"alice", "second"); err != ErrUserExists {
+				t.Errorf("got error %v, want %v", err, ErrUserExists)
+			}
+			user, err := store.FindUser(prefix + "alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.Password != "first" {
+				t.Errorf("password was overwritten with %q", user.Password)
+			}
+		}},
+		{"create concurrently", func(t *testing.T, store UserStore, prefix string) {
+			var wg sync.WaitGroup
+			errs := make(chan error, 10)
+			for i := 0; i < cap(errs); i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errs <- store.CreateUser(prefix+"bob", "hash")
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			created := 0
+			for err := range errs {
+				switch err {
+				case nil:
+					created++
+				case ErrUserExists:
+				default:
+					t.Error(err)
+				}
+			}
+			if created != 1 {
+				t.Errorf("created the user %d times, want once", created)
+			}
+		}},
+	}
+	for backend, newStore := range testStores() {
+		t.Run(backend, func(t *testing.T) {
+			for _, test := range tests {
+				t.Run(test.name, func(t *testing.T) {
+					store, prefix := newStore(t)
+					test.run(t, store, prefix)
+				})
+			}
+		})
+	}
+}
+
+func TestFileStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateUser("alice", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := reopened.FindUser("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Password != "hash" || user.Id.IsZero() {
+		t.Errorf("found %+v", user)
+	}
+}
+
+func TestFileStoreForgetsUnsavedUsers(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+	store, err := NewFileStore(filepath.Join(dir, "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateUser("alice", "hash"); err == nil {
+		t.Fatal("created a user without a directory to save it in")
+	}
+	if _, err := store.FindUser("alice"); err != ErrUserNotFound {
+		t.Errorf("got error %v for an unsaved user, want %v", err, ErrUserNotFound)
+	}
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateUser("alice", "hash"); err != nil {
+		t.Errorf("retrying after the save failed: %v", err)
+	}
+}
+
+func TestFileStoreReadsCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileStore(path); err == nil {
+		t.Error("opened a corrupt store file")
+	}
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// FileStore is a UserStore that keeps users in memory and persists them to a
+// JSON file after every write, so accounts survive restarts without a
+// database server.
+type FileStore struct {
+	*MemoryStore
+	path   string
+	saveMu sync.Mutex
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var users []User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		s.users[user.Username] = user
+	}
+	return s, nil
+}
+
+func (s *FileStore) CreateUser(username string, password string) error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	if err := s.MemoryStore.CreateUser(username, password); err != nil {
+		return err
+	}
+	if err := s.save(); err != nil {
+		// Users only exist once they are saved.
+		s.mu.Lock()
+		delete(s.users, username)
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// save writes all users to a temporary file and renames it over the store
+// file so a crash never leaves a partially written file behind.
+func (s *FileStore) save() error {
+	s.mu.RLock()
+	users := make([]User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user)
+	}
+	s.mu.RUnlock()
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+	data, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
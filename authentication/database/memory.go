@@ -0,0 +1,42 @@
+package database
+
+import (
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryStore is a UserStore that keeps all users in process memory. It is
+// safe for concurrent use and intended for local development and tests.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	users map[string]User
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{users: make(map[string]User)}
+}
+
+func (s *MemoryStore) CreateUser(username string, password string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[username]; ok {
+		return ErrUserExists
+	}
+	s.users[username] = User{
+		Id:       primitive.NewObjectID(),
+		Username: username,
+		Password: password,
+	}
+	return nil
+}
+
+func (s *MemoryStore) FindUser(username string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user, ok := s.users[username]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return &user, nil
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore is a UserStore backed by a MongoDB collection.
+type MongoStore struct {
+	client     *mongo.Client
+	collection *mongo.Collection
+}
+
+func NewMongoStore(url string) (*MongoStore, error) {
+	client, err := mongo.NewClient(options.Client().ApplyURI(url))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = client.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = client.Ping(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to mongodb server on: %s", url)
+	}
+	return &MongoStore{
+		client:     client,
+		collection: client.Database(databaseName).Collection(collectionName),
+	}, nil
+}
+
+// createIndexes creates the unique index on usernames.
+func (s *MongoStore) createIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "username", Value: 1}},
+		Options: options.Index().SetName("username_unique").SetUnique(true),
+	})
+	return err
+}
+
+func (s *MongoStore) CreateUser(username string, password string) error {
+	user := User{
+		Username: username,
+		Password: password,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := s.collection.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrUserExists
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *MongoStore) FindUser(username string) (*User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var user User
+	err := s.collection.FindOne(ctx, bson.M{"username": username}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"log"
+
 	"github.com/joesjo/grpc-store/authentication/database"
 	"github.com/joesjo/grpc-store/authentication/service"
 )
 
 func main() {
-	database.Init()
-	service.Start()
+	store, err := database.Init()
+	if err != nil {
+		log.Fatal(err)
+	}
+	service.Start(store)
 }
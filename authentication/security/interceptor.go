@@ -0,0 +1,68 @@
+package security
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AuthorizationMetadataKey is the request metadata carrying the bearer token
+// of the user a client makes a request for.
+const AuthorizationMetadataKey = "authorization"
+
+type usernameKey struct{}
+
+// Username returns the user whose token a request carried, as verified by
+// the interceptors, or an empty string for anonymous requests.
+func Username(ctx context.Context) string {
+	username, _ := ctx.Value(usernameKey{}).(string)
+	return username
+}
+
+// authenticate verifies the bearer token of a request, if it has one, and
+// returns ctx with its user. Requests with an invalid token are refused
+// rather than treated as anonymous, so that clients notice.
+func authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(AuthorizationMetadataKey)
+	if len(values) == 0 || values[0] == "" {
+		return ctx, nil
+	}
+	username, err := ValidateToken(strings.TrimPrefix(values[0], "Bearer "))
+	if err != nil || username == "" {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token")
+	}
+	return context.WithValue(ctx, usernameKey{}, username), nil
+}
+
+// UnaryServerInterceptor verifies the bearer tokens of unary calls. The
+// user of a call is then read with Username.
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming calls.
+func StreamServerInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
@@ -1,29 +1,62 @@
 package security
 
 import (
+	"errors"
 	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt"
 )
 
+// ErrNoSecret is returned when JWT_SECRET is unset or empty, as tokens
+// signed with an empty key could be forged by anyone.
+var ErrNoSecret = errors.New("JWT_SECRET is not set")
+
+// CheckSecret returns ErrNoSecret when there is no secret to sign and verify
+// tokens with, so that services can refuse to start without one.
+func CheckSecret() error {
+	_, err := secret()
+	return err
+}
+
+func secret() ([]byte, error) {
+	key := os.Getenv("JWT_SECRET")
+	if key == "" {
+		return nil, ErrNoSecret
+	}
+	return []byte(key), nil
+}
+
 func CreateToken(username string) (string, error) {
+	key, err := secret()
+	if err != nil {
+		return "", err
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"username": username,
 		"exp":      time.Now().Add(time.Hour * 24).Unix(),
 	})
-	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	return token.SignedString(key)
 }
 
 func ValidateToken(token string) (string, error) {
+	key, err := secret()
+	if err != nil {
+		return "", err
+	}
 	tkn, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_SECRET")), nil
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key, nil
 	})
 	if err != nil {
 		return "", err
 	}
 	if claims, ok := tkn.Claims.(jwt.MapClaims); ok && tkn.Valid {
-		return claims["username"].(string), nil
+		if username, ok := claims["username"].(string); ok {
+			return username, nil
+		}
 	}
-	return "", err
+	return "", errors.New("invalid token")
 }
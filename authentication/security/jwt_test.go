@@ -0,0 +1,62 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func TestTokenRoundTrip(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+	token, err := CreateToken("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	username, err := ValidateToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "alice" {
+		t.Errorf("validated token of %q, want alice", username)
+	}
+	t.Setenv("JWT_SECRET", "other")
+	if _, err := ValidateToken(token); err == nil {
+		t.Error("validated a token signed with another secret")
+	}
+}
+
+func TestEmptySecret(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+	token, err := CreateToken("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("JWT_SECRET", "")
+	if err := CheckSecret(); err != ErrNoSecret {
+		t.Errorf("got error %v, want %v", err, ErrNoSecret)
+	}
+	if _, err := CreateToken("alice"); err != ErrNoSecret {
+		t.Errorf("created a token without a secret: %v", err)
+	}
+	if _, err := ValidateToken(token); err != ErrNoSecret {
+		t.Errorf("validated a token without a secret: %v", err)
+	}
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"username": "alice"}).SignedString([]byte{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateToken(forged); err == nil {
+		t.Error("validated a token signed with an empty key")
+	}
+}
+
+func TestUnsignedToken(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"username": "alice"}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateToken(unsigned); err == nil {
+		t.Error("validated an unsigned token")
+	}
+}
@@ -2,10 +2,12 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"net"
 	"os"
 
+	"github.com/joesjo/grpc-store/audit"
 	"github.com/joesjo/grpc-store/authentication/database"
 	pb "github.com/joesjo/grpc-store/authentication/protobuf"
 	"google.golang.org/grpc"
@@ -17,10 +19,14 @@ import (
 
 const (
 	DEFAULT_PORT = "8081"
+
+	AUDIT_SERVICE_NAME = "authentication"
 )
 
 type server struct {
 	pb.UnimplementedAuthenticationServiceServer
+	store    database.UserStore
+	auditLog audit.Sink
 }
 
 type InvalidRequestError struct {
@@ -31,7 +37,24 @@ func (e *InvalidRequestError) Error() string {
 	return "Invalid request: " + e.message
 }
 
+// CreateUser records the accounts it creates, and the attempts that fail,
+// in the audit log. The password is left out.
 func (s *server) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
+	entry := audit.NewEntry(ctx, AUDIT_SERVICE_NAME, "CreateUser", "user", req.GetUser().GetUsername())
+	response, err := s.createUser(ctx, req)
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		after, _ := json.Marshal(map[string]string{"username": req.User.Username})
+		entry.After = string(after)
+	}
+	if err := s.auditLog.Record(entry); err != nil {
+		log.Println("Recording audit entry failed:", entry.Action, entry.EntityId, err)
+	}
+	return response, err
+}
+
+func (s *server) createUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
 	log.Println("Creating user with username:", req.User.Username)
 	validate := validator.New()
 	var err error
@@ -45,11 +68,9 @@ func (s *server) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb
 		log.Println("Password err:", err)
 		return nil, &InvalidRequestError{message: err.Error()}
 	}
-	foundUser, err := database.FindUser(req.User.Username)
-	if err != nil {
-		if err.Error() != "mongo: no documents in result" {
-			return nil, err
-		}
+	foundUser, err := s.store.FindUser(req.User.Username)
+	if err != nil && err != database.ErrUserNotFound {
+		return nil, err
 	}
 	if foundUser != nil {
 		return nil, &InvalidRequestError{message: "user already exists"}
@@ -58,7 +79,10 @@ func (s *server) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb
 	if err != nil {
 		return nil, err
 	}
-	err = database.CreateUser(req.User.Username, string(hashedPassword))
+	err = s.store.CreateUser(req.User.Username, string(hashedPassword))
+	if err == database.ErrUserExists {
+		return nil, &InvalidRequestError{message: "user already exists"}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -72,9 +96,9 @@ func (s *server) Authenticate(ctx context.Context, req *pb.AuthenticateRequest)
 	if req.User.Password == "" {
 		return nil, &InvalidRequestError{message: "password is required"}
 	}
-	foundUser, err := database.FindUser(req.User.Username)
+	foundUser, err := s.store.FindUser(req.User.Username)
 	if err != nil {
-		if err.Error() == "mongo: no documents in result" {
+		if err == database.ErrUserNotFound {
 			return nil, &InvalidRequestError{message: "user not found"}
 		}
 		return nil, err
@@ -101,17 +125,24 @@ func (s *server) ValidateToken(ctx context.Context, req *pb.ValidateTokenRequest
 	return &pb.ValidateTokenResponse{Username: username}, nil
 }
 
-func Start() {
+func Start(store database.UserStore) {
 	port, exists := os.LookupEnv("PORT")
 	if !exists {
 		port = DEFAULT_PORT
 	}
+	if err := security.CheckSecret(); err != nil {
+		log.Fatal(err)
+	}
 	lis, err := net.Listen("tcp", ":"+port)
 	if err != nil {
 		log.Fatal(err)
 	}
-	s := grpc.NewServer()
-	pb.RegisterAuthenticationServiceServer(s, &server{})
+	auditLog, err := audit.Init()
+	if err != nil {
+		log.Fatal(err)
+	}
+	s := grpc.NewServer(grpc.UnaryInterceptor(security.UnaryServerInterceptor))
+	pb.RegisterAuthenticationServiceServer(s, &server{store: store, auditLog: auditLog})
 	log.Printf("Starting authentication server on port %s", port)
 	if err := s.Serve(lis); err != nil {
 		log.Fatal(err)
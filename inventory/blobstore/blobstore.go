@@ -0,0 +1,49 @@
+// Package blobstore keeps the files of the inventory service, such as item
+// images, by key.
+package blobstore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	blobdir = "blobs"
+
+	FileBackend = "file"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps blobs by key. Keys are slash-separated paths, such as
+// itemId/imageId. Open returns ErrNotFound for missing blobs, and Delete
+// and DeleteAll do nothing for them.
+type Store interface {
+	Put(key string, data io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+	// DeleteAll deletes every blob whose key starts with prefix and a
+	// slash.
+	DeleteAll(prefix string) error
+}
+
+// Init creates the blob store selected by the BLOB_STORE environment
+// variable. The only one so far, file, keeps blobs under the BLOB_DIR
+// directory.
+func Init() (Store, error) {
+	backend, exists := os.LookupEnv("BLOB_STORE")
+	if !exists || backend == "" {
+		backend = FileBackend
+	}
+	switch backend {
+	case FileBackend:
+		dir, exists := os.LookupEnv("BLOB_DIR")
+		if !exists {
+			dir = blobdir
+		}
+		return NewFileStore(dir)
+	}
+	return nil, fmt.Errorf("unknown blob store: %s", backend)
+}
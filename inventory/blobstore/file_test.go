@@ -0,0 +1,137 @@
+package blobstore
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestStore(t *testing.T) *FileStore {
+	t.Helper()
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func put(t *testing.T, store *FileStore, key, data string) {
+	t.Helper()
+	if err := store.Put(key, strings.NewReader(data)); err != nil {
+		t.Fatalf("Put(%q): %v", key, err)
+	}
+}
+
+// read returns the blob under key, or ErrNotFound.
+func read(t *testing.T, store *FileStore, key string) (string, error) {
+	t.Helper()
+	file, err := store.Open(key)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data), nil
+}
+
+func TestFileStorePutOpen(t *testing.T) {
+	store := newTestStore(t)
+	put(t, store, "item/image", "first")
+	put(t, store, "item/image", "second")
+	if data, err := read(t, store, "item/image"); err != nil || data != "second" {
+		t.Errorf("read %q, %v; want the last blob put", data, err)
+	}
+	if _, err := read(t, store, "item/other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("opened a missing blob with error %v, want ErrNotFound", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(store.dir, "item"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("left %d files behind, want only the blob", len(entries))
+	}
+}
+
+func TestFileStorePutFailureKeepsBlob(t *testing.T) {
+	store := newTestStore(t)
+	put(t, store, "item/image", "kept")
+	if err := store.Put("item/image", io.MultiReader(strings.NewReader("half"), errorReader{})); err == nil {
+		t.Fatal("Put succeeded with a failing reader")
+	}
+	if data, err := read(t, store, "item/image"); err != nil || data != "kept" {
+		t.Errorf("read %q, %v after a failed put; want the earlier blob", data, err)
+	}
+}
+
+type errorReader struct{}
+
+func (errorReader) Read([]byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestFileStoreDelete(t *testing.T) {
+	store := newTestStore(t)
+	put(t, store, "item/image", "data")
+	for i := 0; i < 2; i++ {
+		if err := store.Delete("item/image"); err != nil {
+			t.Fatalf("delete %d: %v", i, err)
+		}
+	}
+	if _, err := read(t, store, "item/image"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("opened a deleted blob with error %v, want ErrNotFound", err)
+	}
+}
+
+func TestFileStoreDeleteAll(t *testing.T) {
+	store := newTestStore(t)
+	put(t, store, "item/a", "a")
+	put(t, store, "item/b", "b")
+	put(t, store, "item2/a", "c")
+	if err := store.DeleteAll("item"); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"item/a", "item/b"} {
+		if _, err := read(t, store, key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("opened %s with error %v, want ErrNotFound", key, err)
+		}
+	}
+	if data, err := read(t, store, "item2/a"); err != nil || data != "c" {
+		t.Errorf("read %q, %v; DeleteAll removed a blob under another prefix", data, err)
+	}
+	if err := store.DeleteAll("missing"); err != nil {
+		t.Errorf("DeleteAll of a missing prefix: %v", err)
+	}
+}
+
+func TestFileStoreRefusesKeysOutsideDir(t *testing.T) {
+	store := newTestStore(t)
+	outside := filepath.Join(filepath.Dir(store.dir), "outside")
+	if err := os.WriteFile(outside, []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{"", "..", "../outside", "/etc/passwd", "item/../../outside", "item//image", "./item", "item/"}
+	for _, key := range keys {
+		if err := store.Put(key, strings.NewReader("data")); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+		if _, err := store.Open(key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Open(%q) returned %v, want an invalid key error", key, err)
+		}
+		if err := store.Delete(key); err == nil {
+			t.Errorf("Delete(%q) succeeded", key)
+		}
+		if err := store.DeleteAll(key); err == nil {
+			t.Errorf("DeleteAll(%q) succeeded", key)
+		}
+	}
+	if data, err := os.ReadFile(outside); err != nil || string(data) != "secret" {
+		t.Errorf("file outside the store is now %q, %v", data, err)
+	}
+}
@@ -0,0 +1,184 @@
+// Command catalog imports items into and exports them from the inventory
+// service as CSV or newline-delimited JSON.
+//
+//	catalog import [-dry-run] [-format csv|ndjson] [-token TOKEN] FILE
+//	catalog export [-format csv|ndjson] [-o FILE]
+//
+// The format defaults to the one the file extension names, and to CSV.
+// The service is reached at INVENTORY_URI, localhost:8082 by default. An
+// import that has failed rows lists them and exits with status 1. The
+// token, CATALOG_TOKEN by default, is one issued by the authentication
+// service; its user is recorded as the actor of the import.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/joesjo/grpc-store/authentication/security"
+	"github.com/joesjo/grpc-store/inventory/itemio"
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	INVENTORY_URI = "localhost:8082"
+	CHUNK_SIZE    = 32 << 10
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "import":
+		importItems(os.Args[2:])
+	case "export":
+		exportItems(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: catalog import [-dry-run] [-format csv|ndjson] [-token TOKEN] FILE")
+	fmt.Fprintln(os.Stderr, "       catalog export [-format csv|ndjson] [-o FILE]")
+	os.Exit(2)
+}
+
+func importItems(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "validate the file and report what would change without writing")
+	formatName := flags.String("format", "", "csv or ndjson, by default from the file extension")
+	token := flags.String("token", os.Getenv("CATALOG_TOKEN"), "the bearer token of the user recorded in the stock ledger")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+	path := flags.Arg(0)
+	format := fileFormat(*formatName, path)
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	ctx := context.Background()
+	if *token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, security.AuthorizationMetadataKey, "Bearer "+*token)
+	}
+	stream, err := connect().ImportItems(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The settings go with the first chunk, or on their own for an empty
+	// file so that the service can say so.
+	req := &pb.ImportItemsRequest{Format: format, DryRun: *dryRun}
+	buffer := make([]byte, CHUNK_SIZE)
+	for sent := false; ; sent = true {
+		n, err := file.Read(buffer)
+		if err != nil && err != io.EOF {
+			log.Fatal(err)
+		}
+		if n == 0 && sent {
+			break
+		}
+		req.Data = buffer[:n]
+		// A failed send is reported by CloseAndRecv.
+		if stream.Send(req) != nil || err == io.EOF {
+			break
+		}
+		req = &pb.ImportItemsRequest{}
+	}
+	response, err := stream.CloseAndRecv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, importError := range response.Errors {
+		fmt.Printf("line %d (%s): %s\n", importError.Line, importError.Sku, importError.Message)
+	}
+	if int(response.Failed) > len(response.Errors) {
+		fmt.Printf("... and %d more failed rows\n", int(response.Failed)-len(response.Errors))
+	}
+	verb := "Imported"
+	if response.DryRun {
+		verb = "Dry run"
+	}
+	fmt.Printf("%s: %d created, %d updated, %d failed\n", verb, response.Created, response.Updated, response.Failed)
+	if response.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func exportItems(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	formatName := flags.String("format", "", "csv or ndjson, by default from the output file extension")
+	output := flags.String("o", "", "the file to write, standard output by default")
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		usage()
+	}
+	format := fileFormat(*formatName, *output)
+	out := os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	stream, err := connect().ExportItems(context.Background(), &pb.ExportItemsRequest{Format: format})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if _, err := out.Write(chunk.Data); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// fileFormat returns the format named by the -format flag, or else by the
+// extension of path.
+func fileFormat(name string, path string) pb.ItemFormat {
+	if name == "" {
+		switch filepath.Ext(path) {
+		case ".ndjson", ".jsonl":
+			return pb.ItemFormat_FORMAT_NDJSON
+		}
+		return pb.ItemFormat_FORMAT_CSV
+	}
+	format, err := itemio.ParseFormat(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return format
+}
+
+func connect() pb.InventoryServiceClient {
+	url, exists := os.LookupEnv("INVENTORY_URI")
+	if !exists {
+		url = INVENTORY_URI
+	}
+	conn, err := grpc.Dial(url, grpc.WithInsecure())
+	if err != nil {
+		log.Fatal(err)
+	}
+	return pb.NewInventoryServiceClient(conn)
+}
@@ -0,0 +1,143 @@
+// Command reconcile checks that the stock ledger agrees with the stock of
+// every item in the store: the movements of an item must add up to its
+// quantity, those of a variant to the variant's quantity and those at a
+// location to the stock there. It lists the differences it finds and exits
+// with status 1 if there are any.
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/joesjo/grpc-store/inventory/database"
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+)
+
+const PAGE_SIZE = 500
+
+func main() {
+	store, err := database.Init()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var items []*pb.InventoryItem
+	page := database.Page{Size: PAGE_SIZE}
+	// Archived items keep their stock and ledger until they are purged.
+	all := &pb.ItemFilter{Archived: pb.ArchiveFilter_ARCHIVED_INCLUDE}
+	for {
+		info, err := store.ListItems(database.ItemQuery{Filter: all, Page: page}, func(item *pb.InventoryItem) error {
+			items = append(items, item)
+			return nil
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		if info.NextPageToken == "" {
+			break
+		}
+		page.Token = info.NextPageToken
+	}
+	mismatches := 0
+	for _, item := range items {
+		ledger := newLedger()
+		err := store.ListStockMovements(item.Id, func(movement *pb.StockMovement) error {
+			ledger.add(movement)
+			return nil
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, problem := range ledger.reconcile(item) {
+			fmt.Printf("%s (%s): %s\n", item.Id, item.Name, problem)
+			mismatches++
+		}
+	}
+	fmt.Printf("Checked %d items, found %d mismatches\n", len(items), mismatches)
+	if mismatches > 0 {
+		os.Exit(1)
+	}
+}
+
+// ledger holds the sums of the movements of one item.
+type ledger struct {
+	total      int32
+	byVariant  map[string]int32
+	byLocation map[string]map[string]int32
+}
+
+func newLedger() *ledger {
+	return &ledger{
+		byVariant:  make(map[string]int32),
+		byLocation: map[string]map[string]int32{"": {}},
+	}
+}
+
+func (l *ledger) add(movement *pb.StockMovement) {
+	l.total += movement.Amount
+	l.byLocation[""][movement.LocationId] += movement.Amount
+	if movement.VariantId != "" {
+		l.byVariant[movement.VariantId] += movement.Amount
+		if l.byLocation[movement.VariantId] == nil {
+			l.byLocation[movement.VariantId] = make(map[string]int32)
+		}
+		l.byLocation[movement.VariantId][movement.LocationId] += movement.Amount
+	}
+}
+
+// reconcile compares the sums with the stock of item.
+func (l *ledger) reconcile(item *pb.InventoryItem) []string {
+	var problems []string
+	if l.total != item.Quantity {
+		problems = append(problems, fmt.Sprintf("quantity is %d, ledger adds up to %d", item.Quantity, l.total))
+	}
+	problems = append(problems, compareStock("", item.Stock, l.byLocation[""])...)
+	variants := make(map[string]bool)
+	for _, variant := range item.Variants {
+		variants[variant.Id] = true
+		if sum := l.byVariant[variant.Id]; sum != variant.Quantity {
+			problems = append(problems, fmt.Sprintf("variant %s quantity is %d, ledger adds up to %d", variant.Id, variant.Quantity, sum))
+		}
+		problems = append(problems, compareStock(variant.Id, variant.Stock, l.byLocation[variant.Id])...)
+	}
+	for _, variantId := range sortedKeys(l.byVariant) {
+		if !variants[variantId] && l.byVariant[variantId] != 0 {
+			problems = append(problems, fmt.Sprintf("removed variant %s has %d units left in the ledger", variantId, l.byVariant[variantId]))
+		}
+	}
+	return problems
+}
+
+// compareStock compares the stock by location of an item, or of one of its
+// variants, with the sums of the ledger at each location.
+func compareStock(variantId string, stock map[string]int32, sums map[string]int32) []string {
+	locations := make(map[string]int32)
+	for locationId := range stock {
+		locations[locationId] = 0
+	}
+	for locationId := range sums {
+		locations[locationId] = 0
+	}
+	var problems []string
+	for _, locationId := range sortedKeys(locations) {
+		if stock[locationId] == sums[locationId] {
+			continue
+		}
+		subject := "stock"
+		if variantId != "" {
+			subject = "variant " + variantId + " stock"
+		}
+		problems = append(problems, fmt.Sprintf("%s at %s is %d, ledger adds up to %d", subject, locationId, stock[locationId], sums[locationId]))
+	}
+	return problems
+}
+
+func sortedKeys(m map[string]int32) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// ItemUpdate is an update in a batch, as UpdateItem takes it.
+type ItemUpdate struct {
+	Item            *pb.InventoryItem
+	Paths           []string
+	ExpectedVersion int64
+}
+
+// QuantityAdjustment is a stock change in a batch, as IncrementItemQuantity
+// takes it.
+type QuantityAdjustment struct {
+	ItemId          string
+	VariantId       string
+	LocationId      string
+	Quantity        int32
+	AllowBackorder  bool
+	ExpectedVersion int64
+	Change          StockChange
+}
+
+// batch applies the writes of a batch to working copies of the items they
+// touch, one after another, so that later writes see earlier ones. The
+// stores then save the copies together, or drop them when a write fails.
+type batch struct {
+	// load reads an item into the batch. It fails with NotFound for
+	// missing items.
+	load func(itemId string) (*pb.InventoryItem, error)
+	// items are the working copies by id, and order their ids in the order
+	// they were first touched.
+	items map[string]*pb.InventoryItem
+	order []string
+	// before holds the items as they were loaded, to tell which of them
+	// the batch takes to their reorder point.
+	before map[string]*pb.InventoryItem
+	// paths are the fields the updates of the batch wrote per item, and
+	// updated the items that had other writes than stock changes.
+	paths   map[string][]string
+	updated map[string]bool
+	// results are the ids of the items written, one per write.
+	results   []string
+	movements []*pb.StockMovement
+}
+
+func newBatch(load func(itemId string) (*pb.InventoryItem, error)) *batch {
+	return &batch{
+		load:    load,
+		items:   make(map[string]*pb.InventoryItem),
+		before:  make(map[string]*pb.InventoryItem),
+		paths:   make(map[string][]string),
+		updated: make(map[string]bool),
+	}
+}
+
+// item returns the working copy of an item, loading it on first use.
+func (b *batch) item(itemId string) (*pb.InventoryItem, error) {
+	if item, ok := b.items[itemId]; ok {
+		return item, nil
+	}
+	item, err := b.load(itemId)
+	if err != nil {
+		return nil, err
+	}
+	b.before[itemId] = item
+	b.items[itemId] = proto.Clone(item).(*pb.InventoryItem)
+	b.order = append(b.order, itemId)
+	return b.items[itemId], nil
+}
+
+// update applies an update with the same checks as UpdateItem, except that
+// a missing item fails the batch with NotFound.
+func (b *batch) update(update ItemUpdate, change StockChange) error {
+	paths, err := updatePaths(update.Paths)
+	if err != nil {
+		return err
+	}
+	item, err := b.item(update.Item.GetId())
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(item, update.ExpectedVersion); err != nil {
+		return err
+	}
+	if containsPath(paths, "quantity") {
+		if err := checkQuantityUpdate(item, update.Item.Quantity); err != nil {
+			return err
+		}
+	}
+	quantity := item.Quantity
+	applyPaths(item, update.Item, paths)
+	touch(item)
+	b.paths[item.Id] = append(b.paths[item.Id], paths...)
+	b.updated[item.Id] = true
+	b.results = append(b.results, item.Id)
+	b.movements = append(b.movements, movement(item, "", DefaultLocationId, item.Quantity-quantity, change))
+	return nil
+}
+
+// adjust applies a stock change with the same checks as
+// IncrementItemQuantity.
+func (b *batch) adjust(adjustment QuantityAdjustment) error {
+	item, err := b.item(adjustment.ItemId)
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(item, adjustment.ExpectedVersion); err != nil {
+		return err
+	}
+	variantId, quantity := adjustment.VariantId, adjustment.Quantity
+	if err := checkStock(item, variantId, -quantity, adjustment.AllowBackorder); err != nil {
+		return err
+	}
+	locationId := adjustment.LocationId
+	if locationId == "" {
+		locationId = DefaultLocationId
+		if quantity < 0 {
+			locationId = pickLocation(item, variantId)
+		}
+	}
+	if err := checkLocationStock(item, variantId, locationId, -quantity, adjustment.AllowBackorder); err != nil {
+		return err
+	}
+	addStock(item, variantId, locationId, quantity)
+	touch(item)
+	b.results = append(b.results, item.Id)
+	b.movements = append(b.movements, movement(item, variantId, locationId, quantity, adjustment.Change))
+	return nil
+}
+
+// changeType returns the kind of change the batch made to an item.
+func (b *batch) changeType(itemId string) pb.ItemChangeType {
+	if b.updated[itemId] {
+		return pb.ItemChangeType_ITEM_UPDATED
+	}
+	return pb.ItemChangeType_ITEM_QUANTITY_CHANGED
+}
+
+// checkLowStock reports the items the batch took to their reorder point.
+func (b *batch) checkLowStock(notifier *lowStockNotifier) {
+	for _, itemId := range b.order {
+		before := b.before[itemId]
+		notifier.checkLowStock(before.Quantity, before.ReorderPoint, readItem(b.items[itemId]))
+	}
+}
+
+// result returns the items after the batch, one per write.
+func (b *batch) result() []*pb.InventoryItem {
+	items := make([]*pb.InventoryItem, len(b.results))
+	for i, itemId := range b.results {
+		items[i] = readItem(b.items[itemId])
+	}
+	return items
+}
+
+func itemNotFoundError(itemId string) error {
+	return status.Errorf(codes.NotFound, "Could not find item with id "+itemId)
+}
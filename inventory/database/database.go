@@ -2,168 +2,747 @@ package database
 
 import (
 	"context"
-	"log"
+	"fmt"
 	"os"
+	"sort"
 	"time"
 
-	"github.com/jinzhu/copier"
 	pb "github.com/joesjo/grpc-store/inventory/protobuf"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 const (
 	mongouri       = "mongodb://localhost:2717"
 	databaseName   = "store"
 	collectionName = "inventory"
-)
 
-var (
-	client     *mongo.Client
-	collection *mongo.Collection
-	err        error
+	reservationCollectionName = "reservations"
+	categoryCollectionName    = "categories"
+	locationCollectionName    = "locations"
+	movementCollectionName    = "stock_movements"
+	idempotencyCollectionName = "idempotency_keys"
+	priceCollectionName       = "scheduled_prices"
+	priceGuardCollectionName  = "scheduled_price_guards"
+
+	// DefaultLocationId identifies the location that always exists and holds
+	// all stock not placed at another location.
+	DefaultLocationId   = "default"
+	defaultLocationName = "Default"
+
+	MongoBackend  = "mongo"
+	MemoryBackend = "memory"
 )
 
-type InventoryItem struct {
-	Id               primitive.ObjectID `bson:"_id,omitempty"`
-	pb.InventoryItem `bson:",inline"`
+// ItemQuery selects, orders and pages a listing of items. A nil filter
+// matches every item that is not archived. Ties in the sort order are
+// broken by id.
+type ItemQuery struct {
+	Filter    *pb.ItemFilter
+	SortBy    pb.ItemSortField
+	Direction pb.SortDirection
+	Page      Page
+}
+
+// StockChange says why and by whom stock is changed, for the stock ledger.
+type StockChange struct {
+	Reason pb.MovementReason
+	Actor  string
+}
+
+// ItemStore is the persistence layer behind the inventory service.
+//
+// Every write bumps the version of the item it touches. Writes that take an
+// expectedVersion fail with Aborted when it is non-zero and does not match
+// the stored version. Writes that take a StockChange append an entry to the
+// stock ledger for every change of stock at a location they make.
+// UpdateItem and IncrementItemQuantity report items they take to their
+// reorder point to the function set with OnLowStock.
+type ItemStore interface {
+	// ListItems calls send for every item on the requested page of the
+	// query, without buffering the page.
+	ListItems(query ItemQuery, send func(*pb.InventoryItem) error) (*PageInfo, error)
+	FindById(itemId string) (*pb.InventoryItem, error)
+	// FindBySku returns the item with sku, or else the item with a variant
+	// with sku.
+	FindBySku(sku string) (*pb.InventoryItem, error)
+	FindByBarcode(barcode string) (*pb.InventoryItem, error)
+	// InsertItem and UpdateItem fail with AlreadyExists when the sku or
+	// barcode of the item is already used by another item, and InsertItem,
+	// AddVariant and UpdateVariant when the sku of a variant is already used
+	// by a variant of another item.
+	InsertItem(item *pb.InventoryItem, change StockChange) (string, error)
+	// UpdateItem writes the top-level fields named by paths from item to the
+	// stored item, or every writable field when paths is empty. The quantity
+	// written is the total; the stock at the default location makes up the
+	// difference to the other locations. Writing the quantity of an item
+	// with variants, or one below the stock at other locations, fails with
+	// FailedPrecondition.
+	UpdateItem(item *pb.InventoryItem, paths []string, expectedVersion int64, change StockChange) (int64, error)
+	// ArchiveItem marks an item archived by actor, which hides it from
+	// listings whose filter does not ask for archived items. It returns 0
+	// for missing and already archived items, and fails with
+	// FailedPrecondition while the item has reserved stock.
+	ArchiveItem(itemId string, expectedVersion int64, actor string) (int64, error)
+	// RestoreItem brings an archived item back and returns it. It fails
+	// with FailedPrecondition for items that are not archived.
+	RestoreItem(itemId string, expectedVersion int64) (*pb.InventoryItem, error)
+	// PurgeItem permanently deletes an archived item. It fails with
+	// FailedPrecondition for items that are not archived.
+	PurgeItem(itemId string, expectedVersion int64) (int64, error)
+	// PurgeArchivedItems permanently deletes the items archived before
+	// archivedBefore and returns their ids.
+	PurgeArchivedItems(archivedBefore time.Time) ([]string, error)
+	// IncrementItemQuantity adds quantity to the stock of an item, or of one
+	// of its variants when variantId is set, at a location. Items with
+	// variants only take variant level changes. Without a location,
+	// increments go to the default location and decrements are taken from
+	// the location with the most stock. A decrement that exceeds the
+	// available quantity, or the stock at the location, fails with
+	// FailedPrecondition unless allowBackorder is set and the item is
+	// backorderable.
+	IncrementItemQuantity(itemId string, variantId string, locationId string, quantity int32, allowBackorder bool, expectedVersion int64, change StockChange) (int64, error)
+	// TransferStock atomically moves quantity of an item, or of one of its
+	// variants, from one location to another and returns the updated item.
+	// It fails with FailedPrecondition when the source holds less.
+	TransferStock(itemId string, variantId string, fromLocationId string, toLocationId string, quantity int32, expectedVersion int64, change StockChange) (*pb.InventoryItem, error)
+
+	// FindByIds returns the items with the given ids in that order. It
+	// fails with NotFound when any of them does not exist.
+	FindByIds(itemIds []string) ([]*pb.InventoryItem, error)
+	// BatchUpdateItems and BatchAdjustQuantities apply their writes in
+	// order with the checks of UpdateItem and IncrementItemQuantity, each
+	// write seeing the ones before it. Either all of them take effect or,
+	// when one fails, none does; a missing item fails the batch with
+	// NotFound. They return the items after the batch, one per write.
+	BatchUpdateItems(updates []ItemUpdate, change StockChange) ([]*pb.InventoryItem, error)
+	BatchAdjustQuantities(adjustments []QuantityAdjustment) ([]*pb.InventoryItem, error)
+
+	// AddVariant adds a variant with a new id to an item. Its quantity is
+	// added to the item's stock; the first variant replaces the item's own
+	// stock, which must not be reserved, and is recorded as an adjustment.
+	AddVariant(itemId string, variant *pb.Variant, expectedVersion int64, change StockChange) (*pb.Variant, error)
+	// UpdateVariant writes the variant fields named by paths, or all of
+	// sku, options and price when paths is empty.
+	UpdateVariant(itemId string, variant *pb.Variant, paths []string, expectedVersion int64) (*pb.Variant, error)
+	// RemoveVariant removes a variant and its stock from an item. It fails
+	// with FailedPrecondition while the variant has reserved stock.
+	RemoveVariant(itemId string, variantId string, expectedVersion int64, change StockChange) (int64, error)
+
+	// ReserveStock holds quantity of an item, or of one of its variants,
+	// until expiresAt. It fails with FailedPrecondition when less than
+	// quantity is available or the item is archived.
+	ReserveStock(itemId string, variantId string, quantity int32, expiresAt time.Time) (*pb.Reservation, error)
+	// CommitReservation turns a pending reservation into a stock decrement
+	// at a location, or at the location with the most stock when locationId
+	// is empty.
+	CommitReservation(reservationId string, locationId string, change StockChange) (*pb.Reservation, error)
+	// ReleaseReservation returns the held quantity of a pending reservation.
+	ReleaseReservation(reservationId string) (*pb.Reservation, error)
+	// ExpireReservations releases every pending reservation that expired
+	// before now and returns how many were released.
+	ExpireReservations(now time.Time) (int64, error)
+
+	ListCategories() ([]*pb.Category, error)
+	FindCategory(categoryId string) (*pb.Category, error)
+	InsertCategory(category *pb.Category) (*pb.Category, error)
+	// UpdateCategory writes the name and parent of a category.
+	UpdateCategory(category *pb.Category) (*pb.Category, error)
+	// DeleteCategory deletes a category and removes it from the items
+	// assigned to it.
+	DeleteCategory(categoryId string) (int64, error)
+
+	// ListLocations returns every location, the default location first.
+	ListLocations() ([]*pb.Location, error)
+	FindLocation(locationId string) (*pb.Location, error)
+	InsertLocation(location *pb.Location) (*pb.Location, error)
+	// UpdateLocation writes the name of a location.
+	UpdateLocation(location *pb.Location) (*pb.Location, error)
+	// DeleteLocation deletes a location. It fails with FailedPrecondition for
+	// the default location and while any item has stock there.
+	DeleteLocation(locationId string) (int64, error)
+
+	// ListStockMovements calls send for every stock ledger entry of an item,
+	// oldest first.
+	ListStockMovements(itemId string, send func(*pb.StockMovement) error) error
+
+	// ListLowStockItems calls send for every item at or below its reorder
+	// point.
+	ListLowStockItems(send func(*pb.InventoryItem) error) error
+	// OnLowStock sets the function called with an item after a write took
+	// it to its reorder point. It is called while the write still holds the
+	// store, so it must not call back into it.
+	OnLowStock(notify func(*pb.InventoryItem))
+
+	// WatchItems calls send for every change to an item matching query, in
+	// the order they are made, until ctx is done or send fails. Without a
+	// resume token it starts with the next change.
+	WatchItems(ctx context.Context, query WatchQuery, send func(*pb.ItemChange) error) error
+
+	// AddItemImage appends an image to the images of an item and returns
+	// the item.
+	AddItemImage(itemId string, image *pb.ItemImage, expectedVersion int64) (*pb.InventoryItem, error)
+	// SetItemImages replaces the images of an item with the given ones, as
+	// arranged by arrangeImages, and returns the item and the images it
+	// removed.
+	SetItemImages(itemId string, images []*pb.ItemImage, expectedVersion int64) (*pb.InventoryItem, []*pb.ItemImage, error)
+
+	// InsertScheduledPrice schedules a price for an existing item, with a new
+	// id, and returns it. It fails with FailedPrecondition when its window
+	// overlaps that of a scheduled or active price of the item.
+	InsertScheduledPrice(price *pb.ScheduledPrice) (*pb.ScheduledPrice, error)
+	// CancelScheduledPrice marks a scheduled or active price cancelled,
+	// ended at now, and returns it. It fails with FailedPrecondition for
+	// prices that already ended.
+	CancelScheduledPrice(priceId string, now time.Time) (*pb.ScheduledPrice, error)
+	// ListScheduledPrices returns every scheduled price of an item, ordered
+	// by the start of its window.
+	ListScheduledPrices(itemId string) ([]*pb.ScheduledPrice, error)
+	// ActivateScheduledPrices marks the scheduled prices whose window
+	// contains now active and returns them.
+	ActivateScheduledPrices(now time.Time) ([]*pb.ScheduledPrice, error)
+	// ExpireScheduledPrices marks the scheduled and active prices whose
+	// window ended by now expired and returns them.
+	ExpireScheduledPrices(now time.Time) ([]*pb.ScheduledPrice, error)
+
+	// ClaimIdempotencyKey records that a call made with key has started and
+	// returns nil, unless a call with that key that has not expired exists,
+	// which it returns instead.
+	ClaimIdempotencyKey(key string, requestHash string, expiresAt time.Time) (*IdempotentCall, error)
+	// CompleteIdempotencyKey stores the response of the call made with key,
+	// to be replayed until expiresAt.
+	CompleteIdempotencyKey(key string, responseType string, response []byte, expiresAt time.Time) error
+	// ReleaseIdempotencyKey forgets the call made with key, so that it can
+	// be retried after it failed.
+	ReleaseIdempotencyKey(key string) error
+	// ExpireIdempotencyKeys forgets every call that expired before now and
+	// returns how many were forgotten.
+	ExpireIdempotencyKeys(now time.Time) (int64, error)
+}
+
+// IdempotentCall is a call made with an idempotency key. Done is set, along
+// with the response and its message type, once the call has succeeded.
+type IdempotentCall struct {
+	Key          string    `bson:"_id"`
+	RequestHash  string    `bson:"requesthash"`
+	Done         bool      `bson:"done"`
+	ResponseType string    `bson:"responsetype,omitempty"`
+	Response     []byte    `bson:"response,omitempty"`
+	ExpiresAt    time.Time `bson:"expiresat"`
+}
+
+// lowStockNotifier implements OnLowStock for the stores.
+type lowStockNotifier struct {
+	notify func(*pb.InventoryItem)
+}
+
+func (n *lowStockNotifier) OnLowStock(notify func(*pb.InventoryItem)) {
+	n.notify = notify
+}
+
+// checkLowStock reports item when a write took it from above its reorder
+// point to at or below it, given its quantity and reorder point before.
+func (n *lowStockNotifier) checkLowStock(quantity int32, reorderPoint int32, item *pb.InventoryItem) {
+	if n.notify != nil && !isLowStock(quantity, reorderPoint) && isLowStock(item.Quantity, item.ReorderPoint) {
+		n.notify(item)
+	}
 }
 
-func Init() {
-	url, exists := os.LookupEnv("MONGO_URI")
+// isLowStock reports whether a quantity is at or below a reorder point. A
+// reorder point of zero turns the check off.
+func isLowStock(quantity int32, reorderPoint int32) bool {
+	return reorderPoint > 0 && quantity <= reorderPoint
+}
+
+// Init creates the item store selected by the STORE_BACKEND environment
+// variable, defaulting to MongoDB.
+func Init() (ItemStore, error) {
+	backend, exists := os.LookupEnv("STORE_BACKEND")
 	if !exists {
-		url = mongouri
+		backend = MongoBackend
 	}
-	client, err = mongo.NewClient(options.Client().ApplyURI(url))
-	if err != nil {
-		log.Fatal(err)
+	switch backend {
+	case MongoBackend:
+		url, exists := os.LookupEnv("MONGO_URI")
+		if !exists {
+			url = mongouri
+		}
+		store, err := NewMongoStore(url)
+		if err != nil {
+			return nil, err
+		}
+		if err := store.createIndexes(); err != nil {
+			return nil, err
+		}
+		return store, store.createDefaultLocation()
+	case MemoryBackend:
+		return NewMemoryStore(), nil
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	err = client.Connect(ctx)
-	if err != nil {
-		log.Fatal(err)
+	return nil, fmt.Errorf("unknown store backend: %s", backend)
+}
+
+// readOnlyFields are item fields that the store manages itself.
+var readOnlyFields = map[string]bool{
+	"id":         true,
+	"reserved":   true,
+	"available":  true,
+	"version":    true,
+	"createdAt":  true,
+	"updatedAt":  true,
+	"variants":   true,
+	"stock":      true,
+	"archivedAt": true,
+	"archivedBy": true,
+	"images":     true,
+}
+
+// variantFields are the variant fields that UpdateVariant may write.
+var variantFields = []string{"sku", "options", "price"}
+
+// variantPaths validates the field mask of a variant update and returns the
+// fields it writes, expanding an empty mask to every writable field.
+func variantPaths(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return variantFields, nil
 	}
-	err = client.Ping(ctx, nil)
-	if err != nil {
-		log.Fatal("Could not connect to mongodb server on: ", mongouri)
+	for _, path := range paths {
+		if !containsPath(variantFields, path) {
+			return nil, status.Errorf(codes.InvalidArgument, "cannot update variant field %q", path)
+		}
 	}
-	collection = client.Database(databaseName).Collection(collectionName)
+	return paths, nil
 }
 
-func find(filter primitive.D) ([]*pb.InventoryItem, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	var result []*pb.InventoryItem
-	cursor, err := collection.Find(ctx, filter)
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-	for cursor.Next(ctx) {
-		var (
-			item       InventoryItem
-			resultItem = &pb.InventoryItem{}
-		)
-		err := cursor.Decode(&item)
-		if err != nil {
-			return nil, err
+// setAvailable fills in the computed available quantities and stock
+// breakdowns of an item and its variants.
+func setAvailable(item *pb.InventoryItem) {
+	item.Available = item.Quantity - item.Reserved
+	item.Stock = stockBreakdown(item.Quantity, item.Stock)
+	for _, variant := range item.Variants {
+		setVariantAvailable(variant)
+	}
+}
+
+func setVariantAvailable(variant *pb.Variant) {
+	variant.Available = variant.Quantity - variant.Reserved
+	variant.Stock = stockBreakdown(variant.Quantity, variant.Stock)
+}
+
+// stockBreakdown returns the stock at every location holding any, given the
+// total quantity and the stock stored for locations other than the default
+// one. Whatever is not at another location is at the default location.
+func stockBreakdown(quantity int32, stored map[string]int32) map[string]int32 {
+	result := make(map[string]int32)
+	for locationId, located := range stored {
+		if locationId != DefaultLocationId && located != 0 {
+			result[locationId] = located
 		}
-		copier.Copy(resultItem, &item.InventoryItem)
-		resultItem.Id = item.Id.Hex()
-		result = append(result, resultItem)
 	}
-	return result, nil
+	if atDefault := locationStock(quantity, stored, DefaultLocationId); atDefault != 0 {
+		result[DefaultLocationId] = atDefault
+	}
+	return result
 }
 
-func GetAllItems() ([]*pb.InventoryItem, error) {
-	log.Println("Getting all items")
-	filter := bson.D{}
-	return find(filter)
+// locationStock returns the stock at one location, given the total quantity
+// and the stock by location, with or without the default location.
+func locationStock(quantity int32, stock map[string]int32, locationId string) int32 {
+	if locationId != DefaultLocationId {
+		return stock[locationId]
+	}
+	for other, located := range stock {
+		if other != DefaultLocationId {
+			quantity -= located
+		}
+	}
+	return quantity
 }
 
-func InsertItem(item *pb.InventoryItem) (primitive.ObjectID, error) {
-	log.Println("Inserting item:", item)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	var newItem = &InventoryItem{}
-	copier.Copy(newItem, item)
-	result, err := collection.InsertOne(ctx, newItem)
-	if err != nil {
-		return primitive.ObjectID{}, err
+// stockOf returns the total quantity and stock by location of an item, or
+// of one of its variants when variantId is set.
+func stockOf(item *pb.InventoryItem, variantId string) (int32, map[string]int32) {
+	if variantId == "" {
+		return item.Quantity, item.Stock
+	}
+	variant := findVariant(item, variantId)
+	if variant == nil {
+		return 0, nil
 	}
-	resultId := result.InsertedID.(primitive.ObjectID)
-	return resultId, nil
+	return variant.Quantity, variant.Stock
 }
 
-func FindById(itemId string) (*pb.InventoryItem, error) {
-	log.Println("Finding item by id:", itemId)
-	objId, err := primitive.ObjectIDFromHex(itemId)
-	if err != nil {
-		return nil, err
+// pickLocation selects the location to take stock of an item, or of one of
+// its variants, from when the caller does not name one: the location with
+// the most stock, preferring the default location on ties.
+func pickLocation(item *pb.InventoryItem, variantId string) string {
+	quantity, stock := stockOf(item, variantId)
+	breakdown := stockBreakdown(quantity, stock)
+	locationIds := make([]string, 0, len(breakdown))
+	for locationId := range breakdown {
+		if locationId != DefaultLocationId {
+			locationIds = append(locationIds, locationId)
+		}
+	}
+	sort.Strings(locationIds)
+	picked := DefaultLocationId
+	for _, locationId := range locationIds {
+		if breakdown[locationId] > breakdown[picked] {
+			picked = locationId
+		}
+	}
+	return picked
+}
+
+// checkLocationStock returns why need units of an item, or of one of its
+// variants, cannot be taken from a location, or nil if they can.
+func checkLocationStock(item *pb.InventoryItem, variantId string, locationId string, need int32, allowBackorder bool) error {
+	quantity, stock := stockOf(item, variantId)
+	located := locationStock(quantity, stock, locationId)
+	if need > 0 && located < need && !(allowBackorder && item.Backorderable) {
+		if variantId != "" {
+			return status.Errorf(codes.FailedPrecondition, "only %d of variant %s of item %s at location %s", located, variantId, item.Id, locationId)
+		}
+		return status.Errorf(codes.FailedPrecondition, "only %d of item %s at location %s", located, item.Id, locationId)
 	}
-	filter := bson.D{{Key: "_id", Value: objId}}
-	res, err := find(filter)
+	return nil
+}
+
+// checkQuantityUpdate returns why the quantity of an item cannot be set
+// directly, or nil if it can. The quantity of an item with variants is the
+// sum of theirs, and neither can be set. The quantity set is the total, so
+// the reserved stock and the stock at other locations than the default one
+// must fit in it.
+// CheckItemUpdate makes the checks of UpdateItem on the stored item
+// existing that do not involve other items, without writing anything: that
+// paths can be written and that a quantity written covers the reserved
+// stock and the stock at other locations.
+func CheckItemUpdate(existing *pb.InventoryItem, item *pb.InventoryItem, paths []string) error {
+	paths, err := updatePaths(paths)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	if len(res) == 0 {
-		return nil, status.Errorf(codes.NotFound, "Could not find item with id "+itemId)
+	if containsPath(paths, "quantity") {
+		return checkQuantityUpdate(existing, item.Quantity)
 	}
-	return res[0], nil
+	return nil
 }
 
-func FindByName(name string) ([]*pb.InventoryItem, error) {
-	log.Println("Finding item by name:", name)
-	filter := bson.D{{Key: "name", Value: bson.D{{Key: "$regex", Value: name}}}}
-	return find(filter)
+func checkQuantityUpdate(item *pb.InventoryItem, quantity int32) error {
+	if len(item.Variants) > 0 {
+		return variantQuantityError(item.Id)
+	}
+	if quantity < item.Reserved {
+		return status.Errorf(codes.FailedPrecondition, "item %s has %d reserved", item.Id, item.Reserved)
+	}
+	if located := item.Quantity - locationStock(item.Quantity, item.Stock, DefaultLocationId); quantity < located {
+		return status.Errorf(codes.FailedPrecondition, "item %s has %d at other locations than the default one", item.Id, located)
+	}
+	return nil
 }
 
-func UpdateItem(item *pb.InventoryItem) (int64, error) {
-	log.Println("Updating item:", item)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	var newItem = &InventoryItem{}
-	copier.Copy(newItem, item)
-	update := bson.D{
-		{Key: "$set", Value: newItem},
+// addStock adds delta to the stock of an item at a location, and to that of
+// one of its variants when variantId is set.
+func addStock(item *pb.InventoryItem, variantId string, locationId string, delta int32) {
+	item.Quantity += delta
+	item.Stock = addLocated(item.Stock, locationId, delta)
+	if variant := findVariant(item, variantId); variant != nil {
+		variant.Quantity += delta
+		variant.Stock = addLocated(variant.Stock, locationId, delta)
 	}
-	objId, err := primitive.ObjectIDFromHex(item.Id)
-	res, err := collection.UpdateByID(ctx, objId, update)
-	if err != nil {
-		return 0, err
+}
+
+// addLocated adds delta to the stored stock at a location. The stock at the
+// default location is not stored, it follows from the total quantity.
+func addLocated(stock map[string]int32, locationId string, delta int32) map[string]int32 {
+	if locationId == DefaultLocationId {
+		return stock
 	}
-	return res.ModifiedCount, err
+	if stock == nil {
+		stock = make(map[string]int32)
+	}
+	stock[locationId] += delta
+	if stock[locationId] == 0 {
+		delete(stock, locationId)
+	}
+	return stock
 }
 
-func DeleteItem(itemId string) (int64, error) {
-	log.Println("Deleting item:", itemId)
-	objId, err := primitive.ObjectIDFromHex(itemId)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	filter := bson.D{{Key: "_id", Value: objId}}
-	res, err := collection.DeleteOne(ctx, filter)
-	return res.DeletedCount, err
+// clearStock takes all stock of an item, or of one of its variants, out of
+// the locations holding it and returns the ledger entries recording that.
+func clearStock(item *pb.InventoryItem, variantId string, change StockChange) []*pb.StockMovement {
+	quantity, stock := stockOf(item, variantId)
+	breakdown := stockBreakdown(quantity, stock)
+	locationIds := make([]string, 0, len(breakdown))
+	for locationId := range breakdown {
+		locationIds = append(locationIds, locationId)
+	}
+	sort.Strings(locationIds)
+	var movements []*pb.StockMovement
+	for _, locationId := range locationIds {
+		addStock(item, variantId, locationId, -breakdown[locationId])
+		movements = append(movements, movement(item, variantId, locationId, -breakdown[locationId], change))
+	}
+	return movements
 }
 
-func IncrementItemQuantity(itemId string, quantity int32) (int64, error) {
-	log.Println("Incrementing item quantity:", itemId, quantity)
-	objId, err := primitive.ObjectIDFromHex(itemId)
-	if err != nil {
-		return 0, err
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	filter := bson.D{{Key: "_id", Value: objId}}
-	update := bson.D{
-		{Key: "$inc", Value: bson.D{
-			{Key: "quantity", Value: quantity},
-		}},
-	}
-	res, err := collection.UpdateOne(ctx, filter, update)
-	return res.ModifiedCount, err
+// movement builds the ledger entry for a change of amount to the stock of
+// an item, or of one of its variants, at a location. item must already
+// reflect the change.
+func movement(item *pb.InventoryItem, variantId string, locationId string, amount int32, change StockChange) *pb.StockMovement {
+	balance, _ := stockOf(item, variantId)
+	return &pb.StockMovement{
+		ItemId:     item.Id,
+		VariantId:  variantId,
+		LocationId: locationId,
+		Reason:     change.Reason,
+		Actor:      change.Actor,
+		Amount:     amount,
+		Balance:    balance,
+		CreatedAt:  now(),
+	}
+}
+
+// sortLocations orders locations by id, the default location first.
+func sortLocations(locations []*pb.Location) {
+	sort.Slice(locations, func(i, j int) bool {
+		if locations[i].Id == DefaultLocationId || locations[j].Id == DefaultLocationId {
+			return locations[i].Id == DefaultLocationId && locations[j].Id != DefaultLocationId
+		}
+		return locations[i].Id < locations[j].Id
+	})
+}
+
+func findVariant(item *pb.InventoryItem, variantId string) *pb.Variant {
+	for _, variant := range item.Variants {
+		if variant.Id == variantId {
+			return variant
+		}
+	}
+	return nil
+}
+
+// checkStock returns why need units of an item, or of one of its variants,
+// cannot be taken from stock, or nil if they can. A need of zero only
+// checks that the stock exists.
+func checkStock(item *pb.InventoryItem, variantId string, need int32, allowBackorder bool) error {
+	available := item.Quantity - item.Reserved
+	if variantId == "" && len(item.Variants) > 0 {
+		return status.Errorf(codes.FailedPrecondition, "item %s has variants, a variant id is required", item.Id)
+	}
+	if variantId != "" {
+		variant := findVariant(item, variantId)
+		if variant == nil {
+			return variantNotFoundError(item.Id, variantId)
+		}
+		available = variant.Quantity - variant.Reserved
+	}
+	if need > 0 && available < need && !(allowBackorder && item.Backorderable) {
+		return insufficientStockError(item.Id, variantId, available)
+	}
+	return nil
+}
+
+func variantNotFoundError(itemId string, variantId string) error {
+	return status.Errorf(codes.NotFound, "Could not find variant %s of item %s", variantId, itemId)
+}
+
+func concurrentUpdateError(itemId string) error {
+	return status.Errorf(codes.Aborted, "item %s was changed concurrently, try again", itemId)
+}
+
+func reservedItemError(itemId string) error {
+	return status.Errorf(codes.FailedPrecondition, "item %s has reserved stock", itemId)
+}
+
+func reservedVariantError(itemId string, variantId string) error {
+	return status.Errorf(codes.FailedPrecondition, "variant %s of item %s has reserved stock", variantId, itemId)
+}
+
+// arrangeImages returns the images of an item reordered and with the alt
+// text given by images, which may only name images the item has, and the
+// images left out. The rest of each image is kept as it was uploaded.
+func arrangeImages(item *pb.InventoryItem, images []*pb.ItemImage) ([]*pb.ItemImage, []*pb.ItemImage, error) {
+	existing := make(map[string]*pb.ItemImage)
+	for _, image := range item.Images {
+		existing[image.Id] = image
+	}
+	var arranged []*pb.ItemImage
+	for _, image := range images {
+		current, ok := existing[image.Id]
+		if !ok {
+			return nil, nil, status.Errorf(codes.InvalidArgument, "item %s has no image %q", item.Id, image.Id)
+		}
+		delete(existing, image.Id)
+		updated := proto.Clone(current).(*pb.ItemImage)
+		updated.Alt = image.Alt
+		arranged = append(arranged, updated)
+	}
+	var removed []*pb.ItemImage
+	for _, image := range item.Images {
+		if _, ok := existing[image.Id]; ok {
+			removed = append(removed, image)
+		}
+	}
+	return arranged, removed, nil
+}
+
+func archivedItemError(itemId string) error {
+	return status.Errorf(codes.FailedPrecondition, "item %s is archived", itemId)
+}
+
+func notArchivedError(itemId string) error {
+	return status.Errorf(codes.FailedPrecondition, "item %s is not archived", itemId)
+}
+
+// matchArchived reports whether an item that is archived or not passes the
+// archive filter.
+func matchArchived(filter pb.ArchiveFilter, archived bool) bool {
+	switch filter {
+	case pb.ArchiveFilter_ARCHIVED_INCLUDE:
+		return true
+	case pb.ArchiveFilter_ARCHIVED_ONLY:
+		return archived
+	}
+	return !archived
+}
+
+func variantQuantityError(itemId string) error {
+	return status.Errorf(codes.FailedPrecondition, "item %s has variants, its quantity is the sum of theirs", itemId)
+}
+
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// updatePaths validates the field mask of an update and returns the fields
+// it writes, expanding an empty mask to every writable field.
+func updatePaths(paths []string) ([]string, error) {
+	fields := (&pb.InventoryItem{}).ProtoReflect().Descriptor().Fields()
+	if len(paths) == 0 {
+		for i := 0; i < fields.Len(); i++ {
+			if name := string(fields.Get(i).Name()); !readOnlyFields[name] {
+				paths = append(paths, name)
+			}
+		}
+		return paths, nil
+	}
+	for _, path := range paths {
+		if fields.ByName(protoreflect.Name(path)) == nil || readOnlyFields[path] {
+			return nil, status.Errorf(codes.InvalidArgument, "cannot update item field %q", path)
+		}
+	}
+	return paths, nil
+}
+
+// applyPaths writes the fields named by paths from src to dst, clearing
+// those src does not have.
+func applyPaths(dst *pb.InventoryItem, src *pb.InventoryItem, paths []string) {
+	from := proto.Clone(src).ProtoReflect()
+	to := dst.ProtoReflect()
+	for _, path := range paths {
+		field := to.Descriptor().Fields().ByName(protoreflect.Name(path))
+		if from.Has(field) {
+			to.Set(field, from.Get(field))
+		} else {
+			to.Clear(field)
+		}
+	}
+}
+
+// checkVersion returns an Aborted error when expectedVersion is set and does
+// not match the version of item.
+func checkVersion(item *pb.InventoryItem, expectedVersion int64) error {
+	if expectedVersion == 0 || item.Version == expectedVersion {
+		return nil
+	}
+	return status.Errorf(codes.Aborted, "item %s is at version %d, expected %d", item.Id, item.Version, expectedVersion)
+}
+
+func insufficientStockError(itemId string, variantId string, available int32) error {
+	if variantId != "" {
+		return status.Errorf(codes.FailedPrecondition, "only %d of variant %s of item %s available", available, variantId, itemId)
+	}
+	return status.Errorf(codes.FailedPrecondition, "only %d of item %s available", available, itemId)
+}
+
+// uniqueFields are the item fields no two items may share a non-empty value
+// of.
+var uniqueFields = []string{"sku", "barcode"}
+
+// uniqueValue returns the value of a unique field of item.
+func uniqueValue(item *pb.InventoryItem, field string) string {
+	message := item.ProtoReflect()
+	return message.Get(message.Descriptor().Fields().ByName(protoreflect.Name(field))).String()
+}
+
+func duplicateError(field string, value string) error {
+	return status.Errorf(codes.AlreadyExists, "an item with %s %s already exists", field, value)
+}
+
+func duplicateVariantError(sku string) error {
+	return status.Errorf(codes.AlreadyExists, "a variant with sku %s already exists", sku)
+}
+
+func categoryNotFoundError(categoryId string) error {
+	return status.Errorf(codes.NotFound, "Could not find category with id "+categoryId)
+}
+
+func locationNotFoundError(locationId string) error {
+	return status.Errorf(codes.NotFound, "Could not find location with id "+locationId)
+}
+
+func defaultLocationError() error {
+	return status.Errorf(codes.FailedPrecondition, "the default location cannot be deleted")
+}
+
+func locationInUseError(locationId string) error {
+	return status.Errorf(codes.FailedPrecondition, "location %s holds stock", locationId)
+}
+
+// pricePending reports whether a scheduled price is yet to end.
+func pricePending(price *pb.ScheduledPrice) bool {
+	return price.Status == pb.ScheduledPriceStatus_PRICE_SCHEDULED || price.Status == pb.ScheduledPriceStatus_PRICE_ACTIVE
+}
+
+// pricesOverlap reports whether the windows of two prices overlap. Windows
+// include their start and exclude their end.
+func pricesOverlap(a *pb.ScheduledPrice, b *pb.ScheduledPrice) bool {
+	startsBeforeEnd := func(start *pb.ScheduledPrice, end *pb.ScheduledPrice) bool {
+		return end.EffectiveTo == nil || start.EffectiveFrom.AsTime().Before(end.EffectiveTo.AsTime())
+	}
+	return startsBeforeEnd(a, b) && startsBeforeEnd(b, a)
+}
+
+func priceOverlapError(price *pb.ScheduledPrice, other *pb.ScheduledPrice) error {
+	return status.Errorf(codes.FailedPrecondition, "price overlaps scheduled price %s of item %s", other.Id, price.ItemId)
+}
+
+func priceEndedError(price *pb.ScheduledPrice) error {
+	return status.Errorf(codes.FailedPrecondition, "scheduled price %s is %s", price.Id, price.Status)
+}
+
+func priceNotFoundError(priceId string) error {
+	return status.Errorf(codes.NotFound, "Could not find scheduled price with id "+priceId)
+}
+
+// sortPrices orders prices by the start of their window, then by id.
+func sortPrices(prices []*pb.ScheduledPrice) {
+	sort.Slice(prices, func(i, j int) bool {
+		a, b := prices[i].EffectiveFrom.AsTime(), prices[j].EffectiveFrom.AsTime()
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return prices[i].Id < prices[j].Id
+	})
+}
+
+func reservationNotPendingError(reservation *pb.Reservation) error {
+	return status.Errorf(codes.FailedPrecondition, "reservation %s is %s", reservation.Id, reservation.Status)
 }
@@ -0,0 +1,1159 @@
+package database
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// MemoryStore is an ItemStore that keeps all items in process memory. It is
+// safe for concurrent use and intended for local development and tests.
+type MemoryStore struct {
+	mu           sync.RWMutex
+	items        map[string]*pb.InventoryItem
+	reservations map[string]*pb.Reservation
+	categories   map[string]*pb.Category
+	locations    map[string]*pb.Location
+	movements    []*pb.StockMovement
+	calls        map[string]*IdempotentCall
+	prices       map[string]*pb.ScheduledPrice
+	lowStockNotifier
+	changes *broadcaster
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		items:        make(map[string]*pb.InventoryItem),
+		reservations: make(map[string]*pb.Reservation),
+		categories:   make(map[string]*pb.Category),
+		calls:        make(map[string]*IdempotentCall),
+		prices:       make(map[string]*pb.ScheduledPrice),
+		locations: map[string]*pb.Location{
+			DefaultLocationId: {Id: DefaultLocationId, Name: defaultLocationName},
+		},
+		changes: newBroadcaster(),
+	}
+}
+
+// readItem returns a copy of a stored item with its computed fields set.
+func readItem(item *pb.InventoryItem) *pb.InventoryItem {
+	result := proto.Clone(item).(*pb.InventoryItem)
+	setAvailable(result)
+	return result
+}
+
+// find returns copies of all items matching the predicate, ordered by id so
+// that results come back in insertion order like the Mongo backend.
+func (s *MemoryStore) find(match func(*pb.InventoryItem) bool) []*pb.InventoryItem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var result []*pb.InventoryItem
+	for _, item := range s.items {
+		if match(item) {
+			result = append(result, readItem(item))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+	return result
+}
+
+func (s *MemoryStore) ListItems(query ItemQuery, send func(*pb.InventoryItem) error) (*PageInfo, error) {
+	log.Println("Listing items:", query.Filter)
+	cursor, key, err := decodePageToken(query)
+	if err != nil {
+		return nil, err
+	}
+	items := s.find(itemMatcher(query.Filter))
+	sort.SliceStable(items, func(i, j int) bool {
+		return itemOrder(query, items[i], sortKey(items[j], query.SortBy), items[j].Id) < 0
+	})
+	info := &PageInfo{TotalCount: int64(len(items))}
+	if key != nil {
+		start := sort.Search(len(items), func(i int) bool {
+			return itemOrder(query, items[i], key, cursor.After) > 0
+		})
+		items = items[start:]
+	}
+	if query.Page.Size > 0 && len(items) > int(query.Page.Size) {
+		items = items[:query.Page.Size]
+		info.NextPageToken = nextPageToken(query, items[len(items)-1])
+	}
+	for _, item := range items {
+		if err := send(item); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// itemOrder compares item with the listing position given by a sort key and
+// id, returning a negative number when item comes first in query's order.
+func itemOrder(query ItemQuery, item *pb.InventoryItem, key interface{}, id string) int {
+	order := compareKeys(sortKey(item, query.SortBy), key)
+	if order == 0 {
+		order = strings.Compare(item.Id, id)
+	}
+	if query.Direction == pb.SortDirection_SORT_DESCENDING {
+		order = -order
+	}
+	return order
+}
+
+func compareKeys(a, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case int32:
+		b := b.(int32)
+		if a < b {
+			return -1
+		}
+		if a > b {
+			return 1
+		}
+	case time.Time:
+		b := b.(time.Time)
+		if a.Before(b) {
+			return -1
+		}
+		if a.After(b) {
+			return 1
+		}
+	}
+	return 0
+}
+
+// itemMatcher turns a filter into a predicate over stored items.
+func itemMatcher(filter *pb.ItemFilter) func(*pb.InventoryItem) bool {
+	return func(item *pb.InventoryItem) bool {
+		if !matchArchived(filter.GetArchived(), item.ArchivedAt != nil) {
+			return false
+		}
+		if name := filter.GetName(); name != nil && !matchString(name, item.Name) {
+			return false
+		}
+		if quantity := filter.GetQuantity(); quantity != nil {
+			if quantity.Min != nil && item.Quantity < *quantity.Min {
+				return false
+			}
+			if quantity.Max != nil && item.Quantity > *quantity.Max {
+				return false
+			}
+		}
+		if filter.GetInStockOnly() && item.Quantity-item.Reserved <= 0 {
+			return false
+		}
+		if categoryIds := filter.GetCategoryIds(); len(categoryIds) > 0 && !inAnyCategory(item, categoryIds) {
+			return false
+		}
+		return inTimeRange(filter.GetCreatedAt(), item.CreatedAt) && inTimeRange(filter.GetUpdatedAt(), item.UpdatedAt)
+	}
+}
+
+func inAnyCategory(item *pb.InventoryItem, categoryIds []string) bool {
+	for _, assigned := range item.CategoryIds {
+		for _, categoryId := range categoryIds {
+			if assigned == categoryId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchString(match *pb.StringMatch, value string) bool {
+	want := match.Value
+	if match.CaseInsensitive {
+		want = strings.ToLower(want)
+		value = strings.ToLower(value)
+	}
+	switch match.Mode {
+	case pb.StringMatchMode_MATCH_PREFIX:
+		return strings.HasPrefix(value, want)
+	case pb.StringMatchMode_MATCH_EXACT:
+		return value == want
+	}
+	return strings.Contains(value, want)
+}
+
+func inTimeRange(timeRange *pb.TimeRange, at *timestamppb.Timestamp) bool {
+	if timeRange == nil {
+		return true
+	}
+	if timeRange.From != nil && at.AsTime().Before(timeRange.From.AsTime()) {
+		return false
+	}
+	if timeRange.To != nil && !at.AsTime().Before(timeRange.To.AsTime()) {
+		return false
+	}
+	return true
+}
+
+// now returns the current time at the millisecond precision MongoDB stores,
+// so both backends hand out the same timestamps.
+func now() *timestamppb.Timestamp {
+	return timestamppb.New(time.Now().Truncate(time.Millisecond))
+}
+
+// touch records a write to a stored item.
+func touch(item *pb.InventoryItem) {
+	item.Version++
+	item.UpdatedAt = now()
+}
+
+func (s *MemoryStore) InsertItem(item *pb.InventoryItem, change StockChange) (string, error) {
+	log.Println("Inserting item:", item)
+	newItem := proto.Clone(item).(*pb.InventoryItem)
+	newItem.Id = primitive.NewObjectID().Hex()
+	newItem.Reserved = 0
+	newItem.Available = 0
+	newItem.Version = 1
+	newItem.Stock = nil
+	newItem.ArchivedAt = nil
+	newItem.ArchivedBy = ""
+	newItem.Images = nil
+	for _, variant := range newItem.Variants {
+		variant.Id = primitive.NewObjectID().Hex()
+		variant.Reserved = 0
+		variant.Available = 0
+		variant.Stock = nil
+	}
+	if len(newItem.Variants) > 0 {
+		newItem.Quantity = 0
+		for _, variant := range newItem.Variants {
+			newItem.Quantity += variant.Quantity
+		}
+	}
+	newItem.CreatedAt = now()
+	newItem.UpdatedAt = newItem.CreatedAt
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkUnique(newItem, uniqueFields); err != nil {
+		return "", err
+	}
+	for _, variant := range newItem.Variants {
+		if err := s.checkVariantSku(newItem.Id, variant.Sku); err != nil {
+			return "", err
+		}
+	}
+	s.items[newItem.Id] = newItem
+	if len(newItem.Variants) == 0 {
+		s.record(movement(newItem, "", DefaultLocationId, newItem.Quantity, change))
+	}
+	for _, variant := range newItem.Variants {
+		s.record(movement(newItem, variant.Id, DefaultLocationId, variant.Quantity, change))
+	}
+	s.changed(pb.ItemChangeType_ITEM_CREATED, newItem)
+	return newItem.Id, nil
+}
+
+// changed hands a change to a stored item to the watchers. The caller must
+// hold the write lock, so that changes are published in the order they are
+// made.
+func (s *MemoryStore) changed(changeType pb.ItemChangeType, item *pb.InventoryItem) {
+	change := &pb.ItemChange{Type: changeType, ItemId: item.Id, OccurredAt: now()}
+	if changeType != pb.ItemChangeType_ITEM_DELETED {
+		change.Item = readItem(item)
+	}
+	s.changes.publish(change)
+}
+
+// record appends entries to the stock ledger, leaving out those that do not
+// change any stock. The caller must hold the write lock.
+func (s *MemoryStore) record(movements ...*pb.StockMovement) {
+	for _, movement := range movements {
+		if movement.Amount == 0 {
+			continue
+		}
+		movement.Id = primitive.NewObjectID().Hex()
+		s.movements = append(s.movements, movement)
+	}
+}
+
+func (s *MemoryStore) FindById(itemId string) (*pb.InventoryItem, error) {
+	log.Println("Finding item by id:", itemId)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, ok := s.items[itemId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Could not find item with id "+itemId)
+	}
+	return readItem(item), nil
+}
+
+func (s *MemoryStore) FindBySku(sku string) (*pb.InventoryItem, error) {
+	log.Println("Finding item by sku:", sku)
+	item, err := s.findUnique("sku", sku)
+	if status.Code(err) != codes.NotFound || sku == "" {
+		return item, err
+	}
+	items := s.find(func(item *pb.InventoryItem) bool {
+		for _, variant := range item.Variants {
+			if variant.Sku == sku {
+				return true
+			}
+		}
+		return false
+	})
+	if len(items) == 0 {
+		return nil, err
+	}
+	return items[0], nil
+}
+
+func (s *MemoryStore) FindByBarcode(barcode string) (*pb.InventoryItem, error) {
+	log.Println("Finding item by barcode:", barcode)
+	return s.findUnique("barcode", barcode)
+}
+
+// findUnique returns the item whose unique field has value.
+func (s *MemoryStore) findUnique(field string, value string) (*pb.InventoryItem, error) {
+	items := s.find(func(item *pb.InventoryItem) bool {
+		return value != "" && uniqueValue(item, field) == value
+	})
+	if len(items) == 0 {
+		return nil, status.Errorf(codes.NotFound, "Could not find item with %s %s", field, value)
+	}
+	return items[0], nil
+}
+
+// checkUnique fails when another item already has the value item has for
+// one of the unique fields among fields. The caller must hold the lock.
+func (s *MemoryStore) checkUnique(item *pb.InventoryItem, fields []string) error {
+	for _, field := range uniqueFields {
+		value := uniqueValue(item, field)
+		if value == "" || !containsPath(fields, field) {
+			continue
+		}
+		for _, other := range s.items {
+			if other.Id != item.Id && uniqueValue(other, field) == value {
+				return duplicateError(field, value)
+			}
+		}
+	}
+	return nil
+}
+
+// checkVariantSku fails when a variant of another item than itemId already
+// has sku. The caller must hold the lock.
+func (s *MemoryStore) checkVariantSku(itemId string, sku string) error {
+	for _, other := range s.items {
+		if other.Id == itemId {
+			continue
+		}
+		for _, variant := range other.Variants {
+			if variant.Sku == sku {
+				return duplicateVariantError(sku)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) UpdateItem(item *pb.InventoryItem, paths []string, expectedVersion int64, change StockChange) (int64, error) {
+	log.Println("Updating item:", item)
+	paths, err := updatePaths(paths)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.items[item.Id]
+	if !ok {
+		return 0, nil
+	}
+	if err := checkVersion(existing, expectedVersion); err != nil {
+		return 0, err
+	}
+	if containsPath(paths, "quantity") {
+		if err := checkQuantityUpdate(existing, item.Quantity); err != nil {
+			return 0, err
+		}
+	}
+	if err := s.checkUnique(item, paths); err != nil {
+		return 0, err
+	}
+	quantity, reorderPoint := existing.Quantity, existing.ReorderPoint
+	applyPaths(existing, item, paths)
+	touch(existing)
+	s.changed(pb.ItemChangeType_ITEM_UPDATED, existing)
+	s.record(movement(existing, "", DefaultLocationId, existing.Quantity-quantity, change))
+	s.checkLowStock(quantity, reorderPoint, readItem(existing))
+	return 1, nil
+}
+
+func (s *MemoryStore) ArchiveItem(itemId string, expectedVersion int64, actor string) (int64, error) {
+	log.Println("Archiving item:", itemId)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.items[itemId]
+	if !ok {
+		return 0, nil
+	}
+	if err := checkVersion(existing, expectedVersion); err != nil {
+		return 0, err
+	}
+	if existing.ArchivedAt != nil {
+		return 0, nil
+	}
+	if existing.Reserved > 0 {
+		return 0, reservedItemError(itemId)
+	}
+	touch(existing)
+	existing.ArchivedAt = existing.UpdatedAt
+	existing.ArchivedBy = actor
+	s.changed(pb.ItemChangeType_ITEM_ARCHIVED, existing)
+	return 1, nil
+}
+
+func (s *MemoryStore) RestoreItem(itemId string, expectedVersion int64) (*pb.InventoryItem, error) {
+	log.Println("Restoring item:", itemId)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.items[itemId]
+	if !ok {
+		return nil, itemNotFoundError(itemId)
+	}
+	if err := checkVersion(existing, expectedVersion); err != nil {
+		return nil, err
+	}
+	if existing.ArchivedAt == nil {
+		return nil, notArchivedError(itemId)
+	}
+	existing.ArchivedAt = nil
+	existing.ArchivedBy = ""
+	touch(existing)
+	s.changed(pb.ItemChangeType_ITEM_RESTORED, existing)
+	return readItem(existing), nil
+}
+
+func (s *MemoryStore) PurgeItem(itemId string, expectedVersion int64) (int64, error) {
+	log.Println("Purging item:", itemId)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.items[itemId]
+	if !ok {
+		return 0, nil
+	}
+	if err := checkVersion(existing, expectedVersion); err != nil {
+		return 0, err
+	}
+	if existing.ArchivedAt == nil {
+		return 0, notArchivedError(itemId)
+	}
+	delete(s.items, itemId)
+	s.changed(pb.ItemChangeType_ITEM_DELETED, existing)
+	return 1, nil
+}
+
+func (s *MemoryStore) PurgeArchivedItems(archivedBefore time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var purged []string
+	for itemId, item := range s.items {
+		if item.ArchivedAt != nil && item.ArchivedAt.AsTime().Before(archivedBefore) {
+			delete(s.items, itemId)
+			s.changed(pb.ItemChangeType_ITEM_DELETED, item)
+			purged = append(purged, itemId)
+		}
+	}
+	return purged, nil
+}
+
+func (s *MemoryStore) AddItemImage(itemId string, image *pb.ItemImage, expectedVersion int64) (*pb.InventoryItem, error) {
+	log.Println("Adding item image:", itemId, image)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[itemId]
+	if !ok {
+		return nil, itemNotFoundError(itemId)
+	}
+	if err := checkVersion(item, expectedVersion); err != nil {
+		return nil, err
+	}
+	item.Images = append(item.Images, proto.Clone(image).(*pb.ItemImage))
+	touch(item)
+	s.changed(pb.ItemChangeType_ITEM_UPDATED, item)
+	return readItem(item), nil
+}
+
+func (s *MemoryStore) SetItemImages(itemId string, images []*pb.ItemImage, expectedVersion int64) (*pb.InventoryItem, []*pb.ItemImage, error) {
+	log.Println("Setting item images:", itemId, images)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[itemId]
+	if !ok {
+		return nil, nil, itemNotFoundError(itemId)
+	}
+	if err := checkVersion(item, expectedVersion); err != nil {
+		return nil, nil, err
+	}
+	arranged, removed, err := arrangeImages(item, images)
+	if err != nil {
+		return nil, nil, err
+	}
+	item.Images = arranged
+	touch(item)
+	s.changed(pb.ItemChangeType_ITEM_UPDATED, item)
+	return readItem(item), removed, nil
+}
+
+func (s *MemoryStore) IncrementItemQuantity(itemId string, variantId string, locationId string, quantity int32, allowBackorder bool, expectedVersion int64, change StockChange) (int64, error) {
+	log.Println("Incrementing item quantity:", itemId, locationId, quantity)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[itemId]
+	if !ok {
+		return 0, status.Errorf(codes.NotFound, "Could not find item with id "+itemId)
+	}
+	if err := checkVersion(item, expectedVersion); err != nil {
+		return 0, err
+	}
+	if err := checkStock(item, variantId, -quantity, allowBackorder); err != nil {
+		return 0, err
+	}
+	if locationId == "" {
+		locationId = DefaultLocationId
+		if quantity < 0 {
+			locationId = pickLocation(item, variantId)
+		}
+	}
+	if err := checkLocationStock(item, variantId, locationId, -quantity, allowBackorder); err != nil {
+		return 0, err
+	}
+	addStock(item, variantId, locationId, quantity)
+	touch(item)
+	s.changed(pb.ItemChangeType_ITEM_QUANTITY_CHANGED, item)
+	s.record(movement(item, variantId, locationId, quantity, change))
+	s.checkLowStock(item.Quantity-quantity, item.ReorderPoint, readItem(item))
+	return 1, nil
+}
+
+func (s *MemoryStore) FindByIds(itemIds []string) ([]*pb.InventoryItem, error) {
+	log.Println("Finding items by id:", itemIds)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	items := make([]*pb.InventoryItem, len(itemIds))
+	for i, itemId := range itemIds {
+		item, ok := s.items[itemId]
+		if !ok {
+			return nil, itemNotFoundError(itemId)
+		}
+		items[i] = readItem(item)
+	}
+	return items, nil
+}
+
+func (s *MemoryStore) BatchUpdateItems(updates []ItemUpdate, change StockChange) ([]*pb.InventoryItem, error) {
+	log.Println("Updating items in a batch:", len(updates))
+	return s.runBatch(func(b *batch) error {
+		for _, update := range updates {
+			if err := b.update(update, change); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *MemoryStore) BatchAdjustQuantities(adjustments []QuantityAdjustment) ([]*pb.InventoryItem, error) {
+	log.Println("Adjusting quantities in a batch:", len(adjustments))
+	return s.runBatch(func(b *batch) error {
+		for _, adjustment := range adjustments {
+			if err := b.adjust(adjustment); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// runBatch applies the writes of a batch under the write lock, so that no
+// other write sees some of them but not all.
+func (s *MemoryStore) runBatch(write func(b *batch) error) ([]*pb.InventoryItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := newBatch(func(itemId string) (*pb.InventoryItem, error) {
+		item, ok := s.items[itemId]
+		if !ok {
+			return nil, itemNotFoundError(itemId)
+		}
+		return item, nil
+	})
+	if err := write(b); err != nil {
+		return nil, err
+	}
+	// Uniqueness is checked with all working copies in place, as updates
+	// in the batch may swap identifiers between items.
+	for _, itemId := range b.order {
+		s.items[itemId] = b.items[itemId]
+	}
+	for _, itemId := range b.order {
+		if err := s.checkUnique(b.items[itemId], b.paths[itemId]); err != nil {
+			for _, itemId := range b.order {
+				s.items[itemId] = b.before[itemId]
+			}
+			return nil, err
+		}
+	}
+	for _, itemId := range b.order {
+		s.changed(b.changeType(itemId), b.items[itemId])
+	}
+	s.record(b.movements...)
+	b.checkLowStock(&s.lowStockNotifier)
+	return b.result(), nil
+}
+
+func (s *MemoryStore) TransferStock(itemId string, variantId string, fromLocationId string, toLocationId string, quantity int32, expectedVersion int64, change StockChange) (*pb.InventoryItem, error) {
+	log.Println("Transferring stock:", itemId, fromLocationId, toLocationId, quantity)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[itemId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Could not find item with id "+itemId)
+	}
+	if err := checkVersion(item, expectedVersion); err != nil {
+		return nil, err
+	}
+	if err := checkStock(item, variantId, 0, false); err != nil {
+		return nil, err
+	}
+	if err := checkLocationStock(item, variantId, fromLocationId, quantity, false); err != nil {
+		return nil, err
+	}
+	addStock(item, variantId, fromLocationId, -quantity)
+	addStock(item, variantId, toLocationId, quantity)
+	touch(item)
+	s.changed(pb.ItemChangeType_ITEM_QUANTITY_CHANGED, item)
+	s.record(
+		movement(item, variantId, fromLocationId, -quantity, change),
+		movement(item, variantId, toLocationId, quantity, change),
+	)
+	return readItem(item), nil
+}
+
+func (s *MemoryStore) ReserveStock(itemId string, variantId string, quantity int32, expiresAt time.Time) (*pb.Reservation, error) {
+	log.Println("Reserving stock:", itemId, quantity)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[itemId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Could not find item with id "+itemId)
+	}
+	if item.ArchivedAt != nil {
+		return nil, archivedItemError(itemId)
+	}
+	if err := checkStock(item, variantId, quantity, false); err != nil {
+		return nil, err
+	}
+	item.Reserved += quantity
+	if variant := findVariant(item, variantId); variant != nil {
+		variant.Reserved += quantity
+	}
+	touch(item)
+	s.changed(pb.ItemChangeType_ITEM_QUANTITY_CHANGED, item)
+	reservation := &pb.Reservation{
+		Id:        primitive.NewObjectID().Hex(),
+		ItemId:    itemId,
+		VariantId: variantId,
+		Quantity:  quantity,
+		Status:    pb.ReservationStatus_RESERVATION_PENDING,
+		ExpiresAt: timestamppb.New(expiresAt),
+	}
+	s.reservations[reservation.Id] = reservation
+	return proto.Clone(reservation).(*pb.Reservation), nil
+}
+
+func (s *MemoryStore) CommitReservation(reservationId string, locationId string, change StockChange) (*pb.Reservation, error) {
+	log.Println("Committing reservation:", reservationId, locationId)
+	return s.finishReservation(reservationId, pb.ReservationStatus_RESERVATION_COMMITTED, locationId, change)
+}
+
+func (s *MemoryStore) ReleaseReservation(reservationId string) (*pb.Reservation, error) {
+	log.Println("Releasing reservation:", reservationId)
+	return s.finishReservation(reservationId, pb.ReservationStatus_RESERVATION_RELEASED, "", StockChange{})
+}
+
+func (s *MemoryStore) finishReservation(reservationId string, newStatus pb.ReservationStatus, locationId string, change StockChange) (*pb.Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reservation, ok := s.reservations[reservationId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Could not find reservation with id "+reservationId)
+	}
+	if reservation.Status == pb.ReservationStatus_RESERVATION_PENDING && !reservation.ExpiresAt.AsTime().After(time.Now()) {
+		s.settle(reservation, pb.ReservationStatus_RESERVATION_EXPIRED, "", StockChange{})
+	}
+	if reservation.Status != pb.ReservationStatus_RESERVATION_PENDING {
+		return nil, reservationNotPendingError(reservation)
+	}
+	if item, ok := s.items[reservation.ItemId]; ok && newStatus == pb.ReservationStatus_RESERVATION_COMMITTED {
+		if locationId == "" {
+			locationId = pickLocation(item, reservation.VariantId)
+		}
+		if err := checkLocationStock(item, reservation.VariantId, locationId, reservation.Quantity, false); err != nil {
+			return nil, err
+		}
+	}
+	s.settle(reservation, newStatus, locationId, change)
+	return proto.Clone(reservation).(*pb.Reservation), nil
+}
+
+// settle moves a pending reservation to newStatus and adjusts the item it
+// holds stock of. Committed stock is taken from locationId, or from the
+// location with the most stock when it is empty, and recorded as change;
+// the caller checks that the location holds it. The caller must hold the
+// write lock.
+func (s *MemoryStore) settle(reservation *pb.Reservation, newStatus pb.ReservationStatus, locationId string, change StockChange) {
+	reservation.Status = newStatus
+	item, ok := s.items[reservation.ItemId]
+	if !ok {
+		return
+	}
+	variant := findVariant(item, reservation.VariantId)
+	item.Reserved -= reservation.Quantity
+	if variant != nil {
+		variant.Reserved -= reservation.Quantity
+	}
+	if newStatus == pb.ReservationStatus_RESERVATION_COMMITTED {
+		if locationId == "" {
+			locationId = pickLocation(item, reservation.VariantId)
+		}
+		reservation.LocationId = locationId
+		addStock(item, reservation.VariantId, locationId, -reservation.Quantity)
+		s.record(movement(item, reservation.VariantId, locationId, -reservation.Quantity, change))
+	}
+	touch(item)
+	s.changed(pb.ItemChangeType_ITEM_QUANTITY_CHANGED, item)
+}
+
+func (s *MemoryStore) ExpireReservations(now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var count int64
+	for _, reservation := range s.reservations {
+		if reservation.Status == pb.ReservationStatus_RESERVATION_PENDING && !reservation.ExpiresAt.AsTime().After(now) {
+			s.settle(reservation, pb.ReservationStatus_RESERVATION_EXPIRED, "", StockChange{})
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemoryStore) AddVariant(itemId string, variant *pb.Variant, expectedVersion int64, change StockChange) (*pb.Variant, error) {
+	log.Println("Adding variant:", itemId, variant)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[itemId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Could not find item with id "+itemId)
+	}
+	if err := checkVersion(item, expectedVersion); err != nil {
+		return nil, err
+	}
+	if len(item.Variants) == 0 && item.Reserved > 0 {
+		return nil, reservedItemError(itemId)
+	}
+	if err := s.checkVariantSku(itemId, variant.Sku); err != nil {
+		return nil, err
+	}
+	newVariant := proto.Clone(variant).(*pb.Variant)
+	newVariant.Id = primitive.NewObjectID().Hex()
+	newVariant.Reserved = 0
+	newVariant.Available = 0
+	newVariant.Stock = nil
+	if len(item.Variants) == 0 {
+		// The item's own stock is replaced by that of its variants.
+		s.record(clearStock(item, "", StockChange{Reason: pb.MovementReason_MOVEMENT_ADJUSTMENT, Actor: change.Actor})...)
+	}
+	item.Variants = append(item.Variants, newVariant)
+	item.Quantity += newVariant.Quantity
+	touch(item)
+	s.changed(pb.ItemChangeType_ITEM_UPDATED, item)
+	s.record(movement(item, newVariant.Id, DefaultLocationId, newVariant.Quantity, change))
+	return readVariant(newVariant), nil
+}
+
+func (s *MemoryStore) UpdateVariant(itemId string, variant *pb.Variant, paths []string, expectedVersion int64) (*pb.Variant, error) {
+	log.Println("Updating variant:", itemId, variant)
+	paths, err := variantPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[itemId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Could not find item with id "+itemId)
+	}
+	if err := checkVersion(item, expectedVersion); err != nil {
+		return nil, err
+	}
+	existing := findVariant(item, variant.Id)
+	if existing == nil {
+		return nil, variantNotFoundError(itemId, variant.Id)
+	}
+	if containsPath(paths, "sku") {
+		if err := s.checkVariantSku(itemId, variant.Sku); err != nil {
+			return nil, err
+		}
+	}
+	src := proto.Clone(variant).ProtoReflect()
+	dst := existing.ProtoReflect()
+	for _, path := range paths {
+		field := dst.Descriptor().Fields().ByName(protoreflect.Name(path))
+		if src.Has(field) {
+			dst.Set(field, src.Get(field))
+		} else {
+			dst.Clear(field)
+		}
+	}
+	touch(item)
+	s.changed(pb.ItemChangeType_ITEM_UPDATED, item)
+	return readVariant(existing), nil
+}
+
+func (s *MemoryStore) RemoveVariant(itemId string, variantId string, expectedVersion int64, change StockChange) (int64, error) {
+	log.Println("Removing variant:", itemId, variantId)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[itemId]
+	if !ok {
+		return 0, nil
+	}
+	if err := checkVersion(item, expectedVersion); err != nil {
+		return 0, err
+	}
+	for i, variant := range item.Variants {
+		if variant.Id != variantId {
+			continue
+		}
+		if variant.Reserved > 0 {
+			return 0, reservedVariantError(itemId, variantId)
+		}
+		s.record(clearStock(item, variantId, change)...)
+		item.Variants = append(item.Variants[:i], item.Variants[i+1:]...)
+		touch(item)
+		s.changed(pb.ItemChangeType_ITEM_UPDATED, item)
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// readVariant returns a copy of a stored variant with its computed fields
+// set.
+func readVariant(variant *pb.Variant) *pb.Variant {
+	result := proto.Clone(variant).(*pb.Variant)
+	setVariantAvailable(result)
+	return result
+}
+
+func (s *MemoryStore) ListCategories() ([]*pb.Category, error) {
+	log.Println("Listing categories")
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]*pb.Category, 0, len(s.categories))
+	for _, category := range s.categories {
+		result = append(result, proto.Clone(category).(*pb.Category))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+	return result, nil
+}
+
+func (s *MemoryStore) FindCategory(categoryId string) (*pb.Category, error) {
+	log.Println("Finding category by id:", categoryId)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	category, ok := s.categories[categoryId]
+	if !ok {
+		return nil, categoryNotFoundError(categoryId)
+	}
+	return proto.Clone(category).(*pb.Category), nil
+}
+
+func (s *MemoryStore) InsertCategory(category *pb.Category) (*pb.Category, error) {
+	log.Println("Inserting category:", category)
+	newCategory := proto.Clone(category).(*pb.Category)
+	newCategory.Id = primitive.NewObjectID().Hex()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.categories[newCategory.Id] = newCategory
+	return proto.Clone(newCategory).(*pb.Category), nil
+}
+
+func (s *MemoryStore) UpdateCategory(category *pb.Category) (*pb.Category, error) {
+	log.Println("Updating category:", category)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.categories[category.Id]
+	if !ok {
+		return nil, categoryNotFoundError(category.Id)
+	}
+	existing.Name = category.Name
+	existing.ParentId = category.ParentId
+	return proto.Clone(existing).(*pb.Category), nil
+}
+
+func (s *MemoryStore) DeleteCategory(categoryId string) (int64, error) {
+	log.Println("Deleting category:", categoryId)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.categories[categoryId]; !ok {
+		return 0, nil
+	}
+	delete(s.categories, categoryId)
+	for _, item := range s.items {
+		kept := item.CategoryIds[:0]
+		for _, assigned := range item.CategoryIds {
+			if assigned != categoryId {
+				kept = append(kept, assigned)
+			}
+		}
+		if len(kept) != len(item.CategoryIds) {
+			item.CategoryIds = kept
+			touch(item)
+			s.changed(pb.ItemChangeType_ITEM_UPDATED, item)
+		}
+	}
+	return 1, nil
+}
+
+func (s *MemoryStore) ListLocations() ([]*pb.Location, error) {
+	log.Println("Listing locations")
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]*pb.Location, 0, len(s.locations))
+	for _, location := range s.locations {
+		result = append(result, proto.Clone(location).(*pb.Location))
+	}
+	sortLocations(result)
+	return result, nil
+}
+
+func (s *MemoryStore) FindLocation(locationId string) (*pb.Location, error) {
+	log.Println("Finding location by id:", locationId)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	location, ok := s.locations[locationId]
+	if !ok {
+		return nil, locationNotFoundError(locationId)
+	}
+	return proto.Clone(location).(*pb.Location), nil
+}
+
+func (s *MemoryStore) InsertLocation(location *pb.Location) (*pb.Location, error) {
+	log.Println("Inserting location:", location)
+	newLocation := proto.Clone(location).(*pb.Location)
+	newLocation.Id = primitive.NewObjectID().Hex()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locations[newLocation.Id] = newLocation
+	return proto.Clone(newLocation).(*pb.Location), nil
+}
+
+func (s *MemoryStore) UpdateLocation(location *pb.Location) (*pb.Location, error) {
+	log.Println("Updating location:", location)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.locations[location.Id]
+	if !ok {
+		return nil, locationNotFoundError(location.Id)
+	}
+	existing.Name = location.Name
+	return proto.Clone(existing).(*pb.Location), nil
+}
+
+func (s *MemoryStore) DeleteLocation(locationId string) (int64, error) {
+	log.Println("Deleting location:", locationId)
+	if locationId == DefaultLocationId {
+		return 0, defaultLocationError()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.locations[locationId]; !ok {
+		return 0, nil
+	}
+	for _, item := range s.items {
+		if item.Stock[locationId] != 0 {
+			return 0, locationInUseError(locationId)
+		}
+	}
+	delete(s.locations, locationId)
+	return 1, nil
+}
+
+func (s *MemoryStore) ListStockMovements(itemId string, send func(*pb.StockMovement) error) error {
+	log.Println("Listing stock movements:", itemId)
+	s.mu.RLock()
+	var movements []*pb.StockMovement
+	for _, movement := range s.movements {
+		if movement.ItemId == itemId {
+			movements = append(movements, proto.Clone(movement).(*pb.StockMovement))
+		}
+	}
+	s.mu.RUnlock()
+	for _, movement := range movements {
+		if err := send(movement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) ListLowStockItems(send func(*pb.InventoryItem) error) error {
+	log.Println("Listing low stock items")
+	items := s.find(func(item *pb.InventoryItem) bool {
+		return item.ArchivedAt == nil && isLowStock(item.Quantity, item.ReorderPoint)
+	})
+	for _, item := range items {
+		if err := send(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) WatchItems(ctx context.Context, query WatchQuery, send func(*pb.ItemChange) error) error {
+	log.Println("Watching items:", query.ItemIds, query.ResumeToken)
+	return s.changes.watch(ctx, query, send)
+}
+
+func (s *MemoryStore) InsertScheduledPrice(price *pb.ScheduledPrice) (*pb.ScheduledPrice, error) {
+	log.Println("Scheduling price:", price.ItemId, price)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[price.ItemId]; !ok {
+		return nil, itemNotFoundError(price.ItemId)
+	}
+	for _, other := range s.prices {
+		if other.ItemId == price.ItemId && pricePending(other) && pricesOverlap(price, other) {
+			return nil, priceOverlapError(price, other)
+		}
+	}
+	newPrice := proto.Clone(price).(*pb.ScheduledPrice)
+	newPrice.Id = primitive.NewObjectID().Hex()
+	newPrice.Status = pb.ScheduledPriceStatus_PRICE_SCHEDULED
+	s.prices[newPrice.Id] = newPrice
+	return proto.Clone(newPrice).(*pb.ScheduledPrice), nil
+}
+
+func (s *MemoryStore) CancelScheduledPrice(priceId string, now time.Time) (*pb.ScheduledPrice, error) {
+	log.Println("Cancelling scheduled price:", priceId)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	price, ok := s.prices[priceId]
+	if !ok {
+		return nil, priceNotFoundError(priceId)
+	}
+	if !pricePending(price) {
+		return nil, priceEndedError(price)
+	}
+	price.Status = pb.ScheduledPriceStatus_PRICE_CANCELLED
+	price.EndedAt = timestamppb.New(now)
+	return proto.Clone(price).(*pb.ScheduledPrice), nil
+}
+
+func (s *MemoryStore) ListScheduledPrices(itemId string) ([]*pb.ScheduledPrice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var prices []*pb.ScheduledPrice
+	for _, price := range s.prices {
+		if price.ItemId == itemId {
+			prices = append(prices, proto.Clone(price).(*pb.ScheduledPrice))
+		}
+	}
+	sortPrices(prices)
+	return prices, nil
+}
+
+func (s *MemoryStore) ActivateScheduledPrices(now time.Time) ([]*pb.ScheduledPrice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var activated []*pb.ScheduledPrice
+	for _, price := range s.prices {
+		started := !price.EffectiveFrom.AsTime().After(now)
+		ended := price.EffectiveTo != nil && !price.EffectiveTo.AsTime().After(now)
+		if price.Status == pb.ScheduledPriceStatus_PRICE_SCHEDULED && started && !ended {
+			price.Status = pb.ScheduledPriceStatus_PRICE_ACTIVE
+			price.ActivatedAt = timestamppb.New(now)
+			activated = append(activated, proto.Clone(price).(*pb.ScheduledPrice))
+		}
+	}
+	sortPrices(activated)
+	return activated, nil
+}
+
+func (s *MemoryStore) ExpireScheduledPrices(now time.Time) ([]*pb.ScheduledPrice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var expired []*pb.ScheduledPrice
+	for _, price := range s.prices {
+		if pricePending(price) && price.EffectiveTo != nil && !price.EffectiveTo.AsTime().After(now) {
+			price.Status = pb.ScheduledPriceStatus_PRICE_EXPIRED
+			price.EndedAt = timestamppb.New(now)
+			expired = append(expired, proto.Clone(price).(*pb.ScheduledPrice))
+		}
+	}
+	sortPrices(expired)
+	return expired, nil
+}
+
+func (s *MemoryStore) ClaimIdempotencyKey(key string, requestHash string, expiresAt time.Time) (*IdempotentCall, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if call, ok := s.calls[key]; ok && call.ExpiresAt.After(time.Now()) {
+		result := *call
+		return &result, nil
+	}
+	s.calls[key] = &IdempotentCall{Key: key, RequestHash: requestHash, ExpiresAt: expiresAt}
+	return nil, nil
+}
+
+func (s *MemoryStore) CompleteIdempotencyKey(key string, responseType string, response []byte, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	call, ok := s.calls[key]
+	if !ok {
+		return status.Errorf(codes.NotFound, "Could not find call with idempotency key "+key)
+	}
+	call.Done = true
+	call.ResponseType = responseType
+	call.Response = response
+	call.ExpiresAt = expiresAt
+	return nil
+}
+
+func (s *MemoryStore) ReleaseIdempotencyKey(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.calls, key)
+	return nil
+}
+
+func (s *MemoryStore) ExpireIdempotencyKeys(now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var count int64
+	for key, call := range s.calls {
+		if !call.ExpiresAt.After(now) {
+			delete(s.calls, key)
+			count++
+		}
+	}
+	return count, nil
+}
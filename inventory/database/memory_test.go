@@ -0,0 +1,405 @@
+package database
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+var receive = StockChange{Reason: pb.MovementReason_MOVEMENT_RECEIVE, Actor: "tester"}
+
+func insertItem(t *testing.T, store *MemoryStore, item *pb.InventoryItem) *pb.InventoryItem {
+	t.Helper()
+	id, err := store.InsertItem(item, receive)
+	if err != nil {
+		t.Fatalf("InsertItem(%v): %v", item, err)
+	}
+	inserted, err := store.FindById(id)
+	if err != nil {
+		t.Fatalf("FindById(%s): %v", id, err)
+	}
+	return inserted
+}
+
+func checkCode(t *testing.T, err error, want codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Fatalf("got error %v, want code %v", err, want)
+	}
+}
+
+func TestMemoryStoreInsertItem(t *testing.T) {
+	store := NewMemoryStore()
+	first := insertItem(t, store, &pb.InventoryItem{Name: "Mug", Quantity: 3, Sku: "MUG-1", Reserved: 7, Version: 9})
+	if first.Id == "" {
+		t.Fatal("inserted item has no id")
+	}
+	if first.Version != 1 || first.Reserved != 0 || first.Available != 3 {
+		t.Errorf("inserted item has version %d, reserved %d, available %d; want 1, 0, 3", first.Version, first.Reserved, first.Available)
+	}
+	tests := []struct {
+		name string
+		item *pb.InventoryItem
+		want codes.Code
+	}{
+		{"new sku", &pb.InventoryItem{Name: "Cup", Sku: "CUP-1"}, codes.OK},
+		{"without sku", &pb.InventoryItem{Name: "Plate"}, codes.OK},
+		{"another without sku", &pb.InventoryItem{Name: "Bowl"}, codes.OK},
+		{"duplicate sku", &pb.InventoryItem{Name: "Mug", Sku: "MUG-1"}, codes.AlreadyExists},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := store.InsertItem(test.item, receive)
+			checkCode(t, err, test.want)
+		})
+	}
+}
+
+func TestMemoryStoreUpdateItem(t *testing.T) {
+	tests := []struct {
+		name     string
+		quantity int32
+		paths    []string
+		// version is added to the version of the item to get the expected
+		// version, unless it is zero, which skips the check.
+		version      int64
+		unknown      bool
+		want         codes.Code
+		wantCount    int64
+		wantQuantity int32
+	}{
+		{name: "without version", quantity: 12, paths: []string{"quantity"}, wantCount: 1, wantQuantity: 12},
+		{name: "current version", quantity: 12, paths: []string{"quantity"}, version: 1, wantCount: 1, wantQuantity: 12},
+		{name: "stale version", quantity: 12, paths: []string{"quantity"}, version: 2, want: codes.Aborted, wantQuantity: 10},
+		{name: "down to reserved", quantity: 4, paths: []string{"quantity"}, wantCount: 1, wantQuantity: 4},
+		{name: "below reserved", quantity: 3, paths: []string{"quantity"}, want: codes.FailedPrecondition, wantQuantity: 10},
+		{name: "other field", quantity: 3, paths: []string{"name"}, wantCount: 1, wantQuantity: 10},
+		{name: "read-only field", paths: []string{"reserved"}, want: codes.InvalidArgument, wantQuantity: 10},
+		{name: "unknown item", quantity: 12, paths: []string{"quantity"}, unknown: true, wantQuantity: 10},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			item := insertItem(t, store, &pb.InventoryItem{Name: "Mug", Quantity: 10})
+			if _, err := store.ReserveStock(item.Id, "", 4, time.Now().Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			item, _ = store.FindById(item.Id)
+			var expectedVersion int64
+			if test.version != 0 {
+				expectedVersion = item.Version + test.version - 1
+			}
+			update := &pb.InventoryItem{Id: item.Id, Name: "Renamed", Quantity: test.quantity}
+			if test.unknown {
+				update.Id = "000000000000000000000000"
+			}
+			count, err := store.UpdateItem(update, test.paths, expectedVersion, receive)
+			checkCode(t, err, test.want)
+			if count != test.wantCount {
+				t.Errorf("updated %d items, want %d", count, test.wantCount)
+			}
+			after, _ := store.FindById(item.Id)
+			if after.Quantity != test.wantQuantity {
+				t.Errorf("quantity is %d, want %d", after.Quantity, test.wantQuantity)
+			}
+			if wantVersion := item.Version + test.wantCount; after.Version != wantVersion {
+				t.Errorf("version is %d, want %d", after.Version, wantVersion)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreIncrementItemQuantity(t *testing.T) {
+	tests := []struct {
+		name           string
+		backorderable  bool
+		quantity       int32
+		allowBackorder bool
+		staleVersion   bool
+		unknown        bool
+		want           codes.Code
+		wantQuantity   int32
+	}{
+		{name: "increment", quantity: 3, wantQuantity: 8},
+		{name: "decrement", quantity: -2, wantQuantity: 3},
+		{name: "decrement of available", quantity: -3, wantQuantity: 2},
+		{name: "decrement of reserved", quantity: -4, want: codes.FailedPrecondition, wantQuantity: 5},
+		{name: "backorder of item that is not backorderable", quantity: -7, allowBackorder: true, want: codes.FailedPrecondition, wantQuantity: 5},
+		{name: "backorder without asking", backorderable: true, quantity: -7, want: codes.FailedPrecondition, wantQuantity: 5},
+		{name: "backorder", backorderable: true, quantity: -7, allowBackorder: true, wantQuantity: -2},
+		{name: "stale version", quantity: 3, staleVersion: true, want: codes.Aborted, wantQuantity: 5},
+		{name: "unknown item", quantity: 3, unknown: true, want: codes.NotFound, wantQuantity: 5},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			item := insertItem(t, store, &pb.InventoryItem{Name: "Mug", Quantity: 5, Backorderable: test.backorderable})
+			if _, err := store.ReserveStock(item.Id, "", 2, time.Now().Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			item, _ = store.FindById(item.Id)
+			itemId := item.Id
+			if test.unknown {
+				itemId = "000000000000000000000000"
+			}
+			var expectedVersion int64
+			if test.staleVersion {
+				expectedVersion = item.Version - 1
+			}
+			_, err := store.IncrementItemQuantity(itemId, "", "", test.quantity, test.allowBackorder, expectedVersion, receive)
+			checkCode(t, err, test.want)
+			after, _ := store.FindById(item.Id)
+			if after.Quantity != test.wantQuantity {
+				t.Errorf("quantity is %d, want %d", after.Quantity, test.wantQuantity)
+			}
+			if after.Available != test.wantQuantity-2 {
+				t.Errorf("available is %d, want %d", after.Available, test.wantQuantity-2)
+			}
+		})
+	}
+}
+
+// listAll pages through a listing and returns the names of the items in
+// the order they were listed.
+func listAll(t *testing.T, store *MemoryStore, query ItemQuery) []string {
+	t.Helper()
+	var names []string
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatal("listing does not end")
+		}
+		var page []string
+		info, err := store.ListItems(query, func(item *pb.InventoryItem) error {
+			page = append(page, item.Name)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ListItems(%+v): %v", query, err)
+		}
+		if len(page) > int(query.Page.Size) {
+			t.Fatalf("page has %d items, want at most %d", len(page), query.Page.Size)
+		}
+		if info.TotalCount != 5 {
+			t.Errorf("total count is %d, want 5", info.TotalCount)
+		}
+		names = append(names, page...)
+		if info.NextPageToken == "" {
+			return names
+		}
+		query.Page.Token = info.NextPageToken
+	}
+}
+
+func TestMemoryStoreListItemsPages(t *testing.T) {
+	store := NewMemoryStore()
+	for _, item := range []*pb.InventoryItem{
+		{Name: "c", Quantity: 2},
+		{Name: "a", Quantity: 7},
+		{Name: "e", Quantity: 2},
+		{Name: "b", Quantity: 1},
+		{Name: "d", Quantity: 5},
+	} {
+		insertItem(t, store, item)
+	}
+	tests := []struct {
+		name  string
+		query ItemQuery
+		want  []string
+	}{
+		{"by id", ItemQuery{Page: Page{Size: 2}}, []string{"c", "a", "e", "b", "d"}},
+		{"by name", ItemQuery{SortBy: pb.ItemSortField_ITEM_SORT_NAME, Page: Page{Size: 2}}, []string{"a", "b", "c", "d", "e"}},
+		{"by name descending", ItemQuery{SortBy: pb.ItemSortField_ITEM_SORT_NAME, Direction: pb.SortDirection_SORT_DESCENDING, Page: Page{Size: 3}}, []string{"e", "d", "c", "b", "a"}},
+		// Ties are broken by id, which follows the order of insertion.
+		{"by quantity", ItemQuery{SortBy: pb.ItemSortField_ITEM_SORT_QUANTITY, Page: Page{Size: 1}}, []string{"b", "c", "e", "d", "a"}},
+		{"one page", ItemQuery{SortBy: pb.ItemSortField_ITEM_SORT_NAME, Page: Page{Size: 5}}, []string{"a", "b", "c", "d", "e"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := listAll(t, store, test.query)
+			if len(got) != len(test.want) {
+				t.Fatalf("listed %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Fatalf("listed %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreListItemsInvalidPageTokens(t *testing.T) {
+	store := NewMemoryStore()
+	for _, name := range []string{"a", "b", "c"} {
+		insertItem(t, store, &pb.InventoryItem{Name: name})
+	}
+	byName := ItemQuery{SortBy: pb.ItemSortField_ITEM_SORT_NAME, Page: Page{Size: 1}}
+	info, err := store.ListItems(byName, func(*pb.InventoryItem) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		query ItemQuery
+	}{
+		{"not base64", ItemQuery{SortBy: pb.ItemSortField_ITEM_SORT_NAME, Page: Page{Size: 1, Token: "!"}}},
+		{"not json", ItemQuery{SortBy: pb.ItemSortField_ITEM_SORT_NAME, Page: Page{Size: 1, Token: "bm90IGpzb24"}}},
+		{"other sort field", ItemQuery{SortBy: pb.ItemSortField_ITEM_SORT_QUANTITY, Page: Page{Size: 1, Token: info.NextPageToken}}},
+		{"other direction", ItemQuery{SortBy: pb.ItemSortField_ITEM_SORT_NAME, Direction: pb.SortDirection_SORT_DESCENDING, Page: Page{Size: 1, Token: info.NextPageToken}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := store.ListItems(test.query, func(*pb.InventoryItem) error { return nil })
+			checkCode(t, err, codes.InvalidArgument)
+		})
+	}
+}
+
+func TestMemoryStoreBatchRollback(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(store *MemoryStore, mug, cup *pb.InventoryItem) error
+		want  codes.Code
+	}{
+		{"adjustments", func(store *MemoryStore, mug, cup *pb.InventoryItem) error {
+			_, err := store.BatchAdjustQuantities([]QuantityAdjustment{
+				{ItemId: mug.Id, Quantity: 5, Change: receive},
+				{ItemId: cup.Id, Quantity: -4, Change: receive},
+			})
+			return err
+		}, codes.FailedPrecondition},
+		{"adjustment after adjustment of the same item", func(store *MemoryStore, mug, cup *pb.InventoryItem) error {
+			_, err := store.BatchAdjustQuantities([]QuantityAdjustment{
+				{ItemId: mug.Id, Quantity: -8, Change: receive},
+				{ItemId: mug.Id, Quantity: -3, Change: receive},
+			})
+			return err
+		}, codes.FailedPrecondition},
+		{"adjustment with stale version", func(store *MemoryStore, mug, cup *pb.InventoryItem) error {
+			_, err := store.BatchAdjustQuantities([]QuantityAdjustment{
+				{ItemId: mug.Id, Quantity: 1, ExpectedVersion: mug.Version, Change: receive},
+				{ItemId: mug.Id, Quantity: 1, ExpectedVersion: mug.Version, Change: receive},
+			})
+			return err
+		}, codes.Aborted},
+		{"updates", func(store *MemoryStore, mug, cup *pb.InventoryItem) error {
+			_, err := store.BatchUpdateItems([]ItemUpdate{
+				{Item: &pb.InventoryItem{Id: mug.Id, Name: "Renamed", Quantity: 20}},
+				{Item: &pb.InventoryItem{Id: "000000000000000000000000", Name: "Missing"}},
+			}, receive)
+			return err
+		}, codes.NotFound},
+		{"duplicate sku after update", func(store *MemoryStore, mug, cup *pb.InventoryItem) error {
+			_, err := store.BatchUpdateItems([]ItemUpdate{
+				{Item: &pb.InventoryItem{Id: cup.Id, Name: "Renamed"}, Paths: []string{"name"}},
+				{Item: &pb.InventoryItem{Id: mug.Id, Sku: "CUP-1"}, Paths: []string{"sku"}},
+			}, receive)
+			return err
+		}, codes.AlreadyExists},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			mug := insertItem(t, store, &pb.InventoryItem{Name: "Mug", Quantity: 10, Sku: "MUG-1"})
+			cup := insertItem(t, store, &pb.InventoryItem{Name: "Cup", Quantity: 3, Sku: "CUP-1"})
+			checkCode(t, test.write(store, mug, cup), test.want)
+			for _, before := range []*pb.InventoryItem{mug, cup} {
+				after, _ := store.FindById(before.Id)
+				if after.Name != before.Name || after.Quantity != before.Quantity || after.Sku != before.Sku || after.Version != before.Version {
+					t.Errorf("item changed by failed batch: %v, was %v", after, before)
+				}
+				movements := 0
+				store.ListStockMovements(before.Id, func(*pb.StockMovement) error {
+					movements++
+					return nil
+				})
+				if movements != 1 {
+					t.Errorf("item %s has %d stock movements, want the 1 of its insert", before.Name, movements)
+				}
+			}
+		})
+	}
+}
+
+// Later writes in a batch see the earlier ones, so a decrement may take the
+// stock an earlier increment added.
+func TestMemoryStoreBatchAppliesInOrder(t *testing.T) {
+	store := NewMemoryStore()
+	mug := insertItem(t, store, &pb.InventoryItem{Name: "Mug", Quantity: 10})
+	items, err := store.BatchAdjustQuantities([]QuantityAdjustment{
+		{ItemId: mug.Id, Quantity: -8, Change: receive},
+		{ItemId: mug.Id, Quantity: 5, ExpectedVersion: mug.Version + 1, Change: receive},
+		{ItemId: mug.Id, Quantity: -7, Change: receive},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("batch returned %d items, want one per write", len(items))
+	}
+	for _, item := range items {
+		if item.Quantity != 0 || item.Version != mug.Version+3 {
+			t.Errorf("batch returned quantity %d and version %d, want 0 and %d", item.Quantity, item.Version, mug.Version+3)
+		}
+	}
+	movements := 0
+	store.ListStockMovements(mug.Id, func(*pb.StockMovement) error {
+		movements++
+		return nil
+	})
+	if movements != 4 {
+		t.Errorf("item has %d stock movements, want 4", movements)
+	}
+}
+
+func TestMemoryStoreVariantSkus(t *testing.T) {
+	store := NewMemoryStore()
+	shirt := insertItem(t, store, &pb.InventoryItem{Name: "Shirt", Variants: []*pb.Variant{{Sku: "SHIRT-S"}, {Sku: "SHIRT-M"}}})
+	hat := insertItem(t, store, &pb.InventoryItem{Name: "Hat", Variants: []*pb.Variant{{Sku: "HAT-S"}}})
+	tests := []struct {
+		name  string
+		write func() error
+		want  codes.Code
+	}{
+		{"insert with a variant sku of another item", func() error {
+			_, err := store.InsertItem(&pb.InventoryItem{Name: "Scarf", Variants: []*pb.Variant{{Sku: "SHIRT-S"}}}, receive)
+			return err
+		}, codes.AlreadyExists},
+		{"add a variant sku of another item", func() error {
+			_, err := store.AddVariant(hat.Id, &pb.Variant{Sku: "SHIRT-M"}, 0, receive)
+			return err
+		}, codes.AlreadyExists},
+		{"rename to a variant sku of another item", func() error {
+			_, err := store.UpdateVariant(hat.Id, &pb.Variant{Id: hat.Variants[0].Id, Sku: "SHIRT-S"}, []string{"sku"}, 0)
+			return err
+		}, codes.AlreadyExists},
+		{"update other fields", func() error {
+			_, err := store.UpdateVariant(hat.Id, &pb.Variant{Id: hat.Variants[0].Id, Sku: "SHIRT-S"}, []string{"options"}, 0)
+			return err
+		}, codes.OK},
+		{"keep the sku", func() error {
+			_, err := store.UpdateVariant(shirt.Id, &pb.Variant{Id: shirt.Variants[0].Id, Sku: "SHIRT-S"}, []string{"sku"}, 0)
+			return err
+		}, codes.OK},
+		{"add a new sku", func() error {
+			_, err := store.AddVariant(hat.Id, &pb.Variant{Sku: "HAT-M"}, 0, receive)
+			return err
+		}, codes.OK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkCode(t, test.write(), test.want)
+		})
+	}
+}
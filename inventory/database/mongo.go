@@ -0,0 +1,2317 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/copier"
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type InventoryItem struct {
+	Id               primitive.ObjectID `bson:"_id,omitempty"`
+	pb.InventoryItem `bson:",inline"`
+}
+
+type Reservation struct {
+	Id         primitive.ObjectID   `bson:"_id,omitempty"`
+	ItemId     primitive.ObjectID   `bson:"itemId"`
+	Quantity   int32                `bson:"quantity"`
+	Status     pb.ReservationStatus `bson:"status"`
+	ExpiresAt  time.Time            `bson:"expiresAt"`
+	VariantId  string               `bson:"variantId,omitempty"`
+	LocationId string               `bson:"locationId,omitempty"`
+}
+
+func (r *Reservation) proto() *pb.Reservation {
+	return &pb.Reservation{
+		Id:         r.Id.Hex(),
+		ItemId:     r.ItemId.Hex(),
+		Quantity:   r.Quantity,
+		Status:     r.Status,
+		ExpiresAt:  timestamppb.New(r.ExpiresAt),
+		VariantId:  r.VariantId,
+		LocationId: r.LocationId,
+	}
+}
+
+type StockMovement struct {
+	Id               primitive.ObjectID `bson:"_id,omitempty"`
+	pb.StockMovement `bson:",inline"`
+}
+
+func (m *StockMovement) proto() *pb.StockMovement {
+	movement := &pb.StockMovement{}
+	copier.Copy(movement, &m.StockMovement)
+	movement.Id = m.Id.Hex()
+	return movement
+}
+
+type ScheduledPrice struct {
+	Id                primitive.ObjectID `bson:"_id,omitempty"`
+	pb.ScheduledPrice `bson:",inline"`
+}
+
+func (p *ScheduledPrice) proto() *pb.ScheduledPrice {
+	price := &pb.ScheduledPrice{}
+	copier.Copy(price, &p.ScheduledPrice)
+	price.Id = p.Id.Hex()
+	return price
+}
+
+type Category struct {
+	Id       primitive.ObjectID `bson:"_id,omitempty"`
+	Name     string             `bson:"name"`
+	ParentId primitive.ObjectID `bson:"parentId,omitempty"`
+}
+
+func (c *Category) proto() *pb.Category {
+	category := &pb.Category{Id: c.Id.Hex(), Name: c.Name}
+	if !c.ParentId.IsZero() {
+		category.ParentId = c.ParentId.Hex()
+	}
+	return category
+}
+
+// categoryDocument converts a category into its stored form.
+func categoryDocument(category *pb.Category) (*Category, error) {
+	doc := &Category{Name: category.Name}
+	if category.ParentId != "" {
+		parentId, err := primitive.ObjectIDFromHex(category.ParentId)
+		if err != nil {
+			return nil, categoryNotFoundError(category.ParentId)
+		}
+		doc.ParentId = parentId
+	}
+	return doc, nil
+}
+
+// Location ids are kept as strings so that the default location can have a
+// fixed, readable id.
+type Location struct {
+	Id   string `bson:"_id"`
+	Name string `bson:"name"`
+}
+
+func (l *Location) proto() *pb.Location {
+	return &pb.Location{Id: l.Id, Name: l.Name}
+}
+
+// registry stores protobuf timestamps as BSON dates so that they can be
+// compared and sorted on in queries.
+var registry = func() *bsoncodec.Registry {
+	timestampType := reflect.TypeOf(&timestamppb.Timestamp{})
+	builder := bson.NewRegistryBuilder()
+	builder.RegisterTypeEncoder(timestampType, bsoncodec.ValueEncoderFunc(
+		func(ec bsoncodec.EncodeContext, vw bsonrw.ValueWriter, val reflect.Value) error {
+			if val.IsNil() {
+				return vw.WriteNull()
+			}
+			at := val.Interface().(*timestamppb.Timestamp).AsTime()
+			return vw.WriteDateTime(at.UnixMilli())
+		}))
+	builder.RegisterTypeDecoder(timestampType, bsoncodec.ValueDecoderFunc(
+		func(dc bsoncodec.DecodeContext, vr bsonrw.ValueReader, val reflect.Value) error {
+			if vr.Type() == bsontype.Null {
+				val.Set(reflect.Zero(timestampType))
+				return vr.ReadNull()
+			}
+			millis, err := vr.ReadDateTime()
+			if err != nil {
+				return err
+			}
+			val.Set(reflect.ValueOf(timestamppb.New(time.UnixMilli(millis))))
+			return nil
+		}))
+	return builder.Build()
+}()
+
+// MongoStore is an ItemStore backed by a MongoDB collection.
+type MongoStore struct {
+	client       *mongo.Client
+	collection   *mongo.Collection
+	reservations *mongo.Collection
+	categories   *mongo.Collection
+	locations    *mongo.Collection
+	movements    *mongo.Collection
+	calls        *mongo.Collection
+	prices       *mongo.Collection
+	priceGuards  *mongo.Collection
+	lowStockNotifier
+}
+
+func NewMongoStore(url string) (*MongoStore, error) {
+	client, err := mongo.NewClient(options.Client().ApplyURI(url).SetRegistry(registry))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = client.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = client.Ping(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to mongodb server on: %s", url)
+	}
+	return &MongoStore{
+		client:       client,
+		collection:   client.Database(databaseName).Collection(collectionName),
+		reservations: client.Database(databaseName).Collection(reservationCollectionName),
+		categories:   client.Database(databaseName).Collection(categoryCollectionName),
+		locations:    client.Database(databaseName).Collection(locationCollectionName),
+		movements:    client.Database(databaseName).Collection(movementCollectionName),
+		calls:        client.Database(databaseName).Collection(idempotencyCollectionName),
+		prices:       client.Database(databaseName).Collection(priceCollectionName),
+		priceGuards:  client.Database(databaseName).Collection(priceGuardCollectionName),
+	}, nil
+}
+
+// createIndexes creates the unique indexes on item identifiers, including
+// the skus of variants. Items without a value for an identifier are left out
+// of its index.
+func (s *MongoStore) createIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var models []mongo.IndexModel
+	for _, field := range uniqueFields {
+		models = append(models, mongo.IndexModel{
+			Keys: bson.D{{Key: fieldKey(field), Value: 1}},
+			Options: options.Index().
+				SetName(fieldKey(field) + "_unique").
+				SetUnique(true).
+				SetPartialFilterExpression(bson.D{{Key: fieldKey(field), Value: bson.D{{Key: "$gt", Value: ""}}}}),
+		})
+	}
+	// A unique index on an array only keeps other documents from sharing a
+	// value; the service keeps the skus of the variants of one item apart.
+	models = append(models, mongo.IndexModel{
+		Keys: bson.D{{Key: "variants.sku", Value: 1}},
+		Options: options.Index().
+			SetName(variantSkuIndex).
+			SetUnique(true).
+			SetPartialFilterExpression(bson.D{{Key: "variants.sku", Value: bson.D{{Key: "$gt", Value: ""}}}}),
+	})
+	if _, err := s.collection.Indexes().CreateMany(ctx, models); err != nil {
+		return err
+	}
+	if _, err := s.movements.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "itemid", Value: 1}, {Key: "_id", Value: 1}},
+	}); err != nil {
+		return err
+	}
+	_, err := s.prices.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "itemid", Value: 1}, {Key: "effectivefrom", Value: 1}},
+	})
+	return err
+}
+
+// createDefaultLocation adds the default location unless it exists.
+func (s *MongoStore) createDefaultLocation() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := s.locations.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: DefaultLocationId}},
+		bson.D{{Key: "$setOnInsert", Value: bson.D{{Key: "name", Value: defaultLocationName}}}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+// variantSkuIndex is the name of the unique index on the skus of variants.
+const variantSkuIndex = "variants_sku_unique"
+
+// uniqueViolation translates a duplicate key error from writing item into
+// an AlreadyExists error naming the field.
+func uniqueViolation(err error, item *pb.InventoryItem) error {
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	// The name of the variant index ends in that of the item sku index, so
+	// it is checked first.
+	if strings.Contains(err.Error(), variantSkuIndex) {
+		return variantSkuViolation(err, item.GetVariants()...)
+	}
+	for _, field := range uniqueFields {
+		if strings.Contains(err.Error(), fieldKey(field)+"_unique") {
+			return duplicateError(field, uniqueValue(item, field))
+		}
+	}
+	return status.Errorf(codes.AlreadyExists, "item already exists")
+}
+
+// variantSkuViolation translates a duplicate key error from writing variants
+// into an AlreadyExists error naming the sku, which the error quotes.
+func variantSkuViolation(err error, variants ...*pb.Variant) error {
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	for _, variant := range variants {
+		if strings.Contains(err.Error(), fmt.Sprintf("%q", variant.Sku)) {
+			return duplicateVariantError(variant.Sku)
+		}
+	}
+	return status.Errorf(codes.AlreadyExists, "a variant with this sku already exists")
+}
+
+// fieldKey returns the document key that an item field is stored under.
+func fieldKey(field string) string {
+	return strings.ToLower(field)
+}
+
+// itemDocument converts an item into the fields a client may write, leaving
+// out the id and everything the store manages itself.
+func itemDocument(item *pb.InventoryItem) (bson.M, error) {
+	var newItem = &InventoryItem{}
+	copier.Copy(newItem, item)
+	data, err := bson.MarshalWithRegistry(registry, newItem)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	if err := bson.UnmarshalWithRegistry(registry, data, &doc); err != nil {
+		return nil, err
+	}
+	delete(doc, "_id")
+	for field := range readOnlyFields {
+		delete(doc, fieldKey(field))
+	}
+	return doc, nil
+}
+
+// storedDocument converts an item read from the store back into the
+// document it is stored as, for writes that replace the whole item.
+func storedDocument(item *pb.InventoryItem) (bson.M, error) {
+	doc, err := itemDocument(item)
+	if err != nil {
+		return nil, err
+	}
+	doc["reserved"] = item.Reserved
+	doc["version"] = item.Version
+	if item.CreatedAt != nil {
+		doc[fieldKey("createdAt")] = item.CreatedAt.AsTime()
+	}
+	doc[fieldKey("updatedAt")] = item.UpdatedAt.AsTime()
+	doc["stock"] = storedStock(item.Stock)
+	if item.ArchivedAt != nil {
+		doc[fieldKey("archivedAt")] = item.ArchivedAt.AsTime()
+		doc[fieldKey("archivedBy")] = item.ArchivedBy
+	}
+	if len(item.Images) > 0 {
+		doc["images"] = item.Images
+	}
+	if len(item.Variants) > 0 {
+		var variants bson.A
+		for _, variant := range item.Variants {
+			variantDoc, err := variantDocument(variant)
+			if err != nil {
+				return nil, err
+			}
+			variantDoc["stock"] = storedStock(variant.Stock)
+			variants = append(variants, variantDoc)
+		}
+		doc["variants"] = variants
+	}
+	return doc, nil
+}
+
+// storedStock returns the stock by location as it is stored, without the
+// stock at the default location.
+func storedStock(stock map[string]int32) map[string]int32 {
+	stored := make(map[string]int32)
+	for locationId, located := range stock {
+		if locationId != DefaultLocationId && located != 0 {
+			stored[locationId] = located
+		}
+	}
+	return stored
+}
+
+// variantDocument converts a variant into its stored form, leaving out the
+// computed available quantity.
+func variantDocument(variant *pb.Variant) (bson.M, error) {
+	data, err := bson.MarshalWithRegistry(registry, variant)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	if err := bson.UnmarshalWithRegistry(registry, data, &doc); err != nil {
+		return nil, err
+	}
+	delete(doc, "available")
+	delete(doc, "stock")
+	return doc, nil
+}
+
+// stockUpdate increments the given stock counters of an item and, when
+// variantId is set, the same counters of that variant. The options return
+// the item as it is after the update.
+func stockUpdate(variantId string, counters bson.D) (bson.D, *options.FindOneAndUpdateOptions) {
+	inc := append(bson.D{}, counters...)
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if variantId != "" {
+		for _, counter := range counters {
+			inc = append(inc, bson.E{Key: "variants.$[v]." + counter.Key, Value: counter.Value})
+		}
+		opts.SetArrayFilters(options.ArrayFilters{Filters: bson.A{bson.D{{Key: "v.id", Value: variantId}}}})
+	}
+	return withTouch(bson.D{{Key: "$inc", Value: inc}}), opts
+}
+
+// stockCounters returns the counters that change when delta is added to the
+// stock at a location. The stock at the default location is not stored, it
+// follows from the quantity.
+func stockCounters(locationId string, delta int32) bson.D {
+	counters := bson.D{{Key: "quantity", Value: delta}}
+	if locationId != DefaultLocationId {
+		counters = append(counters, bson.E{Key: "stock." + locationId, Value: delta})
+	}
+	return counters
+}
+
+// stockFilter narrows filter to items whose stock can be changed at the
+// given level: the variant when variantId is set, otherwise the item, which
+// must then not have variants.
+func stockFilter(filter primitive.D, variantId string) primitive.D {
+	if variantId == "" {
+		return append(filter, bson.E{Key: "variants.0", Value: bson.D{{Key: "$exists", Value: false}}})
+	}
+	return append(filter, bson.E{Key: "variants.id", Value: variantId})
+}
+
+// decodeItem converts the document under cursor into an item.
+func decodeItem(cursor *mongo.Cursor) (*pb.InventoryItem, error) {
+	var item InventoryItem
+	err := cursor.Decode(&item)
+	if err != nil {
+		return nil, err
+	}
+	return item.proto(), nil
+}
+
+func (i *InventoryItem) proto() *pb.InventoryItem {
+	resultItem := &pb.InventoryItem{}
+	copier.Copy(resultItem, &i.InventoryItem)
+	resultItem.Id = i.Id.Hex()
+	setAvailable(resultItem)
+	return resultItem
+}
+
+// updateStock applies a stock update to the item matched by filter and
+// returns the item it returned, or nil when nothing matched.
+func (s *MongoStore) updateStock(ctx context.Context, filter primitive.D, update bson.D, opts *options.FindOneAndUpdateOptions) (*pb.InventoryItem, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	var item InventoryItem
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&item)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item.proto(), nil
+}
+
+// record appends entries to the stock ledger, leaving out those that do not
+// change any stock. It is called with the session of the transaction that
+// writes the stock, so that the two are committed together.
+func (s *MongoStore) record(ctx context.Context, movements ...*pb.StockMovement) error {
+	docs := movementDocuments(movements)
+	if len(docs) == 0 {
+		return nil
+	}
+	_, err := s.movements.InsertMany(ctx, docs)
+	return err
+}
+
+// transact runs write in a transaction, which needs MongoDB to run as a
+// replica set. The transaction is retried on conflicts, so write may run
+// more than once.
+func (s *MongoStore) transact(write func(sc mongo.SessionContext) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	session, err := s.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, write(sc)
+	})
+	return transactionError(err)
+}
+
+// movementDocuments converts ledger entries into documents, leaving out
+// those that do not change any stock.
+func movementDocuments(movements []*pb.StockMovement) []interface{} {
+	var docs []interface{}
+	for _, movement := range movements {
+		if movement.Amount != 0 {
+			doc := &StockMovement{Id: primitive.NewObjectID()}
+			copier.Copy(&doc.StockMovement, movement)
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+func (s *MongoStore) find(filter primitive.D) ([]*pb.InventoryItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var result []*pb.InventoryItem
+	cursor, err := s.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		resultItem, err := decodeItem(cursor)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, resultItem)
+	}
+	return result, nil
+}
+
+// itemQuery turns a filter into a MongoDB query document.
+func itemQuery(filter *pb.ItemFilter) primitive.D {
+	query := bson.D{}
+	switch filter.GetArchived() {
+	case pb.ArchiveFilter_ARCHIVED_EXCLUDE:
+		query = append(query, bson.E{Key: fieldKey("archivedAt"), Value: nil})
+	case pb.ArchiveFilter_ARCHIVED_ONLY:
+		query = append(query, bson.E{Key: fieldKey("archivedAt"), Value: bson.D{{Key: "$ne", Value: nil}}})
+	}
+	if name := filter.GetName(); name != nil {
+		query = append(query, bson.E{Key: "name", Value: stringQuery(name)})
+	}
+	if quantity := filter.GetQuantity(); quantity != nil {
+		bounds := bson.D{}
+		if quantity.Min != nil {
+			bounds = append(bounds, bson.E{Key: "$gte", Value: *quantity.Min})
+		}
+		if quantity.Max != nil {
+			bounds = append(bounds, bson.E{Key: "$lte", Value: *quantity.Max})
+		}
+		if len(bounds) > 0 {
+			query = append(query, bson.E{Key: "quantity", Value: bounds})
+		}
+	}
+	if filter.GetInStockOnly() {
+		query = append(query, bson.E{Key: "$expr", Value: availableAtLeast("", 1)})
+	}
+	if categoryIds := filter.GetCategoryIds(); len(categoryIds) > 0 {
+		query = append(query, bson.E{Key: fieldKey("categoryIds"), Value: bson.D{{Key: "$in", Value: categoryIds}}})
+	}
+	for field, timeRange := range map[string]*pb.TimeRange{
+		"createdAt": filter.GetCreatedAt(),
+		"updatedAt": filter.GetUpdatedAt(),
+	} {
+		bounds := bson.D{}
+		if timeRange.GetFrom() != nil {
+			bounds = append(bounds, bson.E{Key: "$gte", Value: timeRange.From.AsTime()})
+		}
+		if timeRange.GetTo() != nil {
+			bounds = append(bounds, bson.E{Key: "$lt", Value: timeRange.To.AsTime()})
+		}
+		if len(bounds) > 0 {
+			query = append(query, bson.E{Key: fieldKey(field), Value: bounds})
+		}
+	}
+	return query
+}
+
+// stringQuery matches a string field literally; the value is escaped before
+// it is used in a regular expression.
+func stringQuery(match *pb.StringMatch) interface{} {
+	if match.Mode == pb.StringMatchMode_MATCH_EXACT && !match.CaseInsensitive {
+		return match.Value
+	}
+	pattern := regexp.QuoteMeta(match.Value)
+	switch match.Mode {
+	case pb.StringMatchMode_MATCH_PREFIX:
+		pattern = "^" + pattern
+	case pb.StringMatchMode_MATCH_EXACT:
+		pattern = "^" + pattern + "$"
+	}
+	var options string
+	if match.CaseInsensitive {
+		options = "i"
+	}
+	return primitive.Regex{Pattern: pattern, Options: options}
+}
+
+var sortKeys = map[pb.ItemSortField]string{
+	pb.ItemSortField_ITEM_SORT_ID:         "_id",
+	pb.ItemSortField_ITEM_SORT_NAME:       fieldKey("name"),
+	pb.ItemSortField_ITEM_SORT_QUANTITY:   fieldKey("quantity"),
+	pb.ItemSortField_ITEM_SORT_CREATED_AT: fieldKey("createdAt"),
+	pb.ItemSortField_ITEM_SORT_UPDATED_AT: fieldKey("updatedAt"),
+}
+
+func (s *MongoStore) ListItems(query ItemQuery, send func(*pb.InventoryItem) error) (*PageInfo, error) {
+	log.Println("Listing items:", query.Filter)
+	after, key, err := decodePageToken(query)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	filter := itemQuery(query.Filter)
+	total, err := s.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	sortKey := sortKeys[query.SortBy]
+	direction, compare := 1, "$gt"
+	if query.Direction == pb.SortDirection_SORT_DESCENDING {
+		direction, compare = -1, "$lt"
+	}
+	if key != nil {
+		afterId, err := primitive.ObjectIDFromHex(after.After)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid page token")
+		}
+		keyset := bson.D{{Key: "_id", Value: bson.D{{Key: compare, Value: afterId}}}}
+		if sortKey != "_id" {
+			keyset = bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: sortKey, Value: bson.D{{Key: compare, Value: key}}}},
+				bson.D{{Key: sortKey, Value: key}, {Key: "_id", Value: bson.D{{Key: compare, Value: afterId}}}},
+			}}}
+		}
+		filter = bson.D{{Key: "$and", Value: bson.A{filter, keyset}}}
+	}
+	sort := bson.D{{Key: sortKey, Value: direction}}
+	if sortKey != "_id" {
+		sort = append(sort, bson.E{Key: "_id", Value: direction})
+	}
+	opts := options.Find().SetSort(sort)
+	if query.Page.Size > 0 {
+		// Fetch one extra item to learn whether another page follows.
+		opts.SetLimit(int64(query.Page.Size) + 1)
+	}
+	cursor, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	info := &PageInfo{TotalCount: total}
+	var (
+		sent int32
+		last *pb.InventoryItem
+	)
+	for cursor.Next(ctx) {
+		if query.Page.Size > 0 && sent == query.Page.Size {
+			info.NextPageToken = nextPageToken(query, last)
+			break
+		}
+		item, err := decodeItem(cursor)
+		if err != nil {
+			return nil, err
+		}
+		if err := send(item); err != nil {
+			return nil, err
+		}
+		sent++
+		last = item
+	}
+	return info, cursor.Err()
+}
+
+// withTouch adds the bookkeeping of every item write to update: the version
+// is bumped and the update time set.
+func withTouch(update bson.D) bson.D {
+	var hasInc, hasSet bool
+	for i, op := range update {
+		switch op.Key {
+		case "$inc":
+			update[i].Value = append(op.Value.(bson.D), bson.E{Key: "version", Value: 1})
+			hasInc = true
+		case "$set":
+			update[i].Value = append(op.Value.(bson.D), bson.E{Key: fieldKey("updatedAt"), Value: time.Now()})
+			hasSet = true
+		}
+	}
+	if !hasInc {
+		update = append(update, bson.E{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}})
+	}
+	if !hasSet {
+		update = append(update, bson.E{Key: "$set", Value: bson.D{{Key: fieldKey("updatedAt"), Value: time.Now()}}})
+	}
+	return update
+}
+
+func (s *MongoStore) InsertItem(item *pb.InventoryItem, change StockChange) (string, error) {
+	log.Println("Inserting item:", item)
+	item = proto.Clone(item).(*pb.InventoryItem)
+	newItem, err := itemDocument(item)
+	if err != nil {
+		return "", err
+	}
+	createdAt := time.Now()
+	newItem["reserved"] = 0
+	newItem["version"] = 1
+	newItem[fieldKey("createdAt")] = createdAt
+	newItem[fieldKey("updatedAt")] = createdAt
+	if len(item.GetVariants()) > 0 {
+		var (
+			variants bson.A
+			quantity int32
+		)
+		for _, variant := range item.Variants {
+			variant.Id = primitive.NewObjectID().Hex()
+			doc, err := variantDocument(variant)
+			if err != nil {
+				return "", err
+			}
+			doc["id"] = variant.Id
+			doc["reserved"] = 0
+			variants = append(variants, doc)
+			quantity += variant.Quantity
+		}
+		newItem["variants"] = variants
+		newItem["quantity"] = quantity
+		item.Quantity = quantity
+	}
+	newItem["_id"] = primitive.NewObjectID()
+	item.Id = newItem["_id"].(primitive.ObjectID).Hex()
+	item.Stock = nil
+	var movements []*pb.StockMovement
+	if len(item.Variants) == 0 {
+		movements = append(movements, movement(item, "", DefaultLocationId, item.Quantity, change))
+	}
+	for _, variant := range item.Variants {
+		variant.Stock = nil
+		movements = append(movements, movement(item, variant.Id, DefaultLocationId, variant.Quantity, change))
+	}
+	err = s.transact(func(sc mongo.SessionContext) error {
+		if _, err := s.collection.InsertOne(sc, newItem); err != nil {
+			return uniqueViolation(err, item)
+		}
+		return s.record(sc, movements...)
+	})
+	if err != nil {
+		return "", err
+	}
+	return item.Id, nil
+}
+
+func (s *MongoStore) FindById(itemId string) (*pb.InventoryItem, error) {
+	log.Println("Finding item by id:", itemId)
+	objId, err := primitive.ObjectIDFromHex(itemId)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.D{{Key: "_id", Value: objId}}
+	res, err := s.find(filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, status.Errorf(codes.NotFound, "Could not find item with id "+itemId)
+	}
+	return res[0], nil
+}
+
+func (s *MongoStore) FindBySku(sku string) (*pb.InventoryItem, error) {
+	log.Println("Finding item by sku:", sku)
+	item, err := s.findUnique("sku", sku)
+	if status.Code(err) != codes.NotFound || sku == "" {
+		return item, err
+	}
+	res, findErr := s.find(bson.D{{Key: "variants.sku", Value: sku}})
+	if findErr != nil {
+		return nil, findErr
+	}
+	if len(res) == 0 {
+		return nil, err
+	}
+	return res[0], nil
+}
+
+func (s *MongoStore) FindByBarcode(barcode string) (*pb.InventoryItem, error) {
+	log.Println("Finding item by barcode:", barcode)
+	return s.findUnique("barcode", barcode)
+}
+
+// findUnique returns the item whose unique field has value.
+func (s *MongoStore) findUnique(field string, value string) (*pb.InventoryItem, error) {
+	res, err := s.find(bson.D{{Key: fieldKey(field), Value: value}})
+	if err != nil {
+		return nil, err
+	}
+	if value == "" || len(res) == 0 {
+		return nil, status.Errorf(codes.NotFound, "Could not find item with %s %s", field, value)
+	}
+	return res[0], nil
+}
+
+func (s *MongoStore) UpdateItem(item *pb.InventoryItem, paths []string, expectedVersion int64, change StockChange) (int64, error) {
+	log.Println("Updating item:", item)
+	paths, err := updatePaths(paths)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	newItem, err := itemDocument(item)
+	if err != nil {
+		return 0, err
+	}
+	set := bson.D{}
+	for _, path := range paths {
+		set = append(set, bson.E{Key: fieldKey(path), Value: newItem[fieldKey(path)]})
+	}
+	update := withTouch(bson.D{
+		{Key: "$set", Value: set},
+	})
+	objId, err := primitive.ObjectIDFromHex(item.Id)
+	if err != nil {
+		return 0, err
+	}
+	filter := withVersion(bson.D{{Key: "_id", Value: objId}}, expectedVersion)
+	writesQuantity := containsPath(paths, "quantity")
+	if writesQuantity {
+		filter = stockFilter(filter, "")
+		filter = append(filter,
+			bson.E{Key: "reserved", Value: bson.D{{Key: "$lte", Value: item.Quantity}}},
+			bson.E{Key: "$expr", Value: bson.D{{Key: "$gte", Value: bson.A{item.Quantity, locatedExpr("$")}}}})
+	}
+	var matched, modified int64
+	if writesQuantity || containsPath(paths, "reorderPoint") {
+		// Read the stock level it had to record the change in the ledger and
+		// to tell whether it dropped to the reorder point.
+		var (
+			updated                *pb.InventoryItem
+			quantity, reorderPoint int32
+		)
+		err := s.transact(func(sc mongo.SessionContext) error {
+			var before InventoryItem
+			err := s.collection.FindOneAndUpdate(sc, filter, update).Decode(&before)
+			if err == mongo.ErrNoDocuments {
+				updated = nil
+				return nil
+			}
+			if err != nil {
+				return uniqueViolation(err, item)
+			}
+			updated = before.proto()
+			quantity, reorderPoint = updated.Quantity, updated.ReorderPoint
+			applyPaths(updated, item, paths)
+			return s.record(sc, movement(updated, "", DefaultLocationId, updated.Quantity-quantity, change))
+		})
+		if err != nil {
+			return 0, err
+		}
+		if updated != nil {
+			matched, modified = 1, 1
+			s.checkLowStock(quantity, reorderPoint, updated)
+		}
+	} else {
+		res, err := s.collection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			return 0, uniqueViolation(err, item)
+		}
+		matched, modified = res.MatchedCount, res.ModifiedCount
+	}
+	if matched == 0 && (expectedVersion != 0 || writesQuantity) {
+		existing, err := s.FindById(item.Id)
+		if status.Code(err) == codes.NotFound {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if err := checkVersion(existing, expectedVersion); err != nil {
+			return 0, err
+		}
+		if err := checkQuantityUpdate(existing, item.Quantity); err != nil {
+			return 0, err
+		}
+		return 0, concurrentUpdateError(item.Id)
+	}
+	return modified, nil
+}
+
+func (s *MongoStore) ArchiveItem(itemId string, expectedVersion int64, actor string) (int64, error) {
+	log.Println("Archiving item:", itemId)
+	objId, err := primitive.ObjectIDFromHex(itemId)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	filter := withVersion(bson.D{
+		{Key: "_id", Value: objId},
+		{Key: fieldKey("archivedAt"), Value: nil},
+		{Key: "reserved", Value: 0},
+	}, expectedVersion)
+	archivedAt := time.Now()
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: fieldKey("archivedAt"), Value: archivedAt},
+			{Key: fieldKey("archivedBy"), Value: actor},
+			{Key: fieldKey("updatedAt"), Value: archivedAt},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}},
+	}
+	res, err := s.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	if res.MatchedCount == 0 {
+		item, err := s.FindById(itemId)
+		if status.Code(err) == codes.NotFound {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if err := checkVersion(item, expectedVersion); err != nil {
+			return 0, err
+		}
+		if item.ArchivedAt != nil {
+			return 0, nil
+		}
+		if item.Reserved > 0 {
+			return 0, reservedItemError(itemId)
+		}
+		return 0, concurrentUpdateError(itemId)
+	}
+	return res.ModifiedCount, nil
+}
+
+func (s *MongoStore) RestoreItem(itemId string, expectedVersion int64) (*pb.InventoryItem, error) {
+	log.Println("Restoring item:", itemId)
+	objId, err := primitive.ObjectIDFromHex(itemId)
+	if err != nil {
+		return nil, err
+	}
+	filter := withVersion(bson.D{
+		{Key: "_id", Value: objId},
+		{Key: fieldKey("archivedAt"), Value: bson.D{{Key: "$ne", Value: nil}}},
+	}, expectedVersion)
+	update := withTouch(bson.D{
+		{Key: "$unset", Value: bson.D{
+			{Key: fieldKey("archivedAt"), Value: ""},
+			{Key: fieldKey("archivedBy"), Value: ""},
+		}},
+	})
+	restored, err := s.updateStock(context.Background(), filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if err != nil {
+		return nil, err
+	}
+	if restored == nil {
+		item, err := s.FindById(itemId)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkVersion(item, expectedVersion); err != nil {
+			return nil, err
+		}
+		if item.ArchivedAt == nil {
+			return nil, notArchivedError(itemId)
+		}
+		return nil, concurrentUpdateError(itemId)
+	}
+	return restored, nil
+}
+
+func (s *MongoStore) PurgeItem(itemId string, expectedVersion int64) (int64, error) {
+	log.Println("Purging item:", itemId)
+	objId, err := primitive.ObjectIDFromHex(itemId)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	filter := withVersion(bson.D{
+		{Key: "_id", Value: objId},
+		{Key: fieldKey("archivedAt"), Value: bson.D{{Key: "$ne", Value: nil}}},
+	}, expectedVersion)
+	res, err := s.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	if res.DeletedCount == 0 {
+		item, err := s.FindById(itemId)
+		if status.Code(err) == codes.NotFound {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if err := checkVersion(item, expectedVersion); err != nil {
+			return 0, err
+		}
+		if item.ArchivedAt == nil {
+			return 0, notArchivedError(itemId)
+		}
+		return 0, concurrentUpdateError(itemId)
+	}
+	return res.DeletedCount, nil
+}
+
+// PurgeArchivedItems deletes the items one by one, each guarded by the
+// archive time, so that items restored in the meantime are kept and left
+// out of the ids returned.
+func (s *MongoStore) PurgeArchivedItems(archivedBefore time.Time) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	archived := bson.D{{Key: fieldKey("archivedAt"), Value: bson.D{{Key: "$lt", Value: archivedBefore}}}}
+	cursor, err := s.collection.Find(ctx, archived, options.Find().SetProjection(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []struct {
+		Id primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	var purged []string
+	for _, doc := range docs {
+		res, err := s.collection.DeleteOne(ctx, append(bson.D{{Key: "_id", Value: doc.Id}}, archived...))
+		if err != nil {
+			return purged, err
+		}
+		if res.DeletedCount > 0 {
+			purged = append(purged, doc.Id.Hex())
+		}
+	}
+	return purged, nil
+}
+
+func (s *MongoStore) AddItemImage(itemId string, image *pb.ItemImage, expectedVersion int64) (*pb.InventoryItem, error) {
+	log.Println("Adding item image:", itemId, image)
+	objId, err := primitive.ObjectIDFromHex(itemId)
+	if err != nil {
+		return nil, err
+	}
+	filter := withVersion(bson.D{{Key: "_id", Value: objId}}, expectedVersion)
+	update := withTouch(bson.D{{Key: "$push", Value: bson.D{{Key: "images", Value: image}}}})
+	item, err := s.updateStock(context.Background(), filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		existing, err := s.FindById(itemId)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkVersion(existing, expectedVersion); err != nil {
+			return nil, err
+		}
+		return nil, concurrentUpdateError(itemId)
+	}
+	return item, nil
+}
+
+func (s *MongoStore) SetItemImages(itemId string, images []*pb.ItemImage, expectedVersion int64) (*pb.InventoryItem, []*pb.ItemImage, error) {
+	log.Println("Setting item images:", itemId, images)
+	item, err := s.findForUpdate(itemId, expectedVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	arranged, removed, err := arrangeImages(item, images)
+	if err != nil {
+		return nil, nil, err
+	}
+	filter, err := versionGuard(item)
+	if err != nil {
+		return nil, nil, err
+	}
+	update := withTouch(bson.D{{Key: "$set", Value: bson.D{{Key: "images", Value: arranged}}}})
+	updated, err := s.updateStock(context.Background(), filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if err != nil {
+		return nil, nil, err
+	}
+	if updated == nil {
+		return nil, nil, concurrentUpdateError(itemId)
+	}
+	return updated, removed, nil
+}
+
+// withVersion narrows filter to documents at expectedVersion, if one is set.
+func withVersion(filter primitive.D, expectedVersion int64) primitive.D {
+	if expectedVersion == 0 {
+		return filter
+	}
+	return append(filter, bson.E{Key: "version", Value: expectedVersion})
+}
+
+func (s *MongoStore) IncrementItemQuantity(itemId string, variantId string, locationId string, quantity int32, allowBackorder bool, expectedVersion int64, change StockChange) (int64, error) {
+	log.Println("Incrementing item quantity:", itemId, locationId, quantity)
+	objId, err := primitive.ObjectIDFromHex(itemId)
+	if err != nil {
+		return 0, err
+	}
+	if locationId == "" {
+		locationId = DefaultLocationId
+		if quantity < 0 {
+			item, err := s.FindById(itemId)
+			if err != nil {
+				return 0, err
+			}
+			locationId = pickLocation(item, variantId)
+		}
+	}
+	filter := stockFilter(withVersion(bson.D{{Key: "_id", Value: objId}}, expectedVersion), variantId)
+	if quantity < 0 {
+		inStock := bson.D{{Key: "$expr", Value: bson.D{{Key: "$and", Value: bson.A{
+			availableAtLeast(variantId, -quantity),
+			locatedAtLeast(variantId, locationId, -quantity),
+		}}}}}
+		if allowBackorder {
+			filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+				inStock,
+				bson.D{{Key: "backorderable", Value: true}},
+			}})
+		} else {
+			filter = append(filter, inStock...)
+		}
+	}
+	update, opts := stockUpdate(variantId, stockCounters(locationId, quantity))
+	var updated *pb.InventoryItem
+	err = s.transact(func(sc mongo.SessionContext) error {
+		updated, err = s.updateStock(sc, filter, update, opts)
+		if err != nil || updated == nil {
+			return err
+		}
+		return s.record(sc, movement(updated, variantId, locationId, quantity, change))
+	})
+	if err != nil {
+		return 0, err
+	}
+	if updated == nil {
+		item, err := s.FindById(itemId)
+		if err != nil {
+			return 0, err
+		}
+		if err := checkVersion(item, expectedVersion); err != nil {
+			return 0, err
+		}
+		if err := checkStock(item, variantId, -quantity, allowBackorder); err != nil {
+			return 0, err
+		}
+		if err := checkLocationStock(item, variantId, locationId, -quantity, allowBackorder); err != nil {
+			return 0, err
+		}
+		return 0, concurrentUpdateError(itemId)
+	}
+	s.checkLowStock(updated.Quantity-quantity, updated.ReorderPoint, updated)
+	return 1, nil
+}
+
+func (s *MongoStore) FindByIds(itemIds []string) ([]*pb.InventoryItem, error) {
+	log.Println("Finding items by id:", itemIds)
+	objIds := make(bson.A, len(itemIds))
+	for i, itemId := range itemIds {
+		objId, err := primitive.ObjectIDFromHex(itemId)
+		if err != nil {
+			return nil, err
+		}
+		objIds[i] = objId
+	}
+	res, err := s.find(bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: objIds}}}})
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[string]*pb.InventoryItem, len(res))
+	for _, item := range res {
+		byId[item.Id] = item
+	}
+	items := make([]*pb.InventoryItem, len(itemIds))
+	for i, itemId := range itemIds {
+		item, ok := byId[itemId]
+		if !ok {
+			return nil, itemNotFoundError(itemId)
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+func (s *MongoStore) BatchUpdateItems(updates []ItemUpdate, change StockChange) ([]*pb.InventoryItem, error) {
+	log.Println("Updating items in a batch:", len(updates))
+	return s.runBatch(func(b *batch) error {
+		for _, update := range updates {
+			if err := b.update(update, change); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *MongoStore) BatchAdjustQuantities(adjustments []QuantityAdjustment) ([]*pb.InventoryItem, error) {
+	log.Println("Adjusting quantities in a batch:", len(adjustments))
+	return s.runBatch(func(b *batch) error {
+		for _, adjustment := range adjustments {
+			if err := b.adjust(adjustment); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// runBatch applies the writes of a batch and their ledger entries in a
+// transaction, which needs MongoDB to run as a replica set.
+func (s *MongoStore) runBatch(write func(b *batch) error) ([]*pb.InventoryItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	session, err := s.client.StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+	var b *batch
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		// The transaction is retried on conflicts, so every attempt reads
+		// the items afresh.
+		b = newBatch(func(itemId string) (*pb.InventoryItem, error) {
+			objId, err := primitive.ObjectIDFromHex(itemId)
+			if err != nil {
+				return nil, itemNotFoundError(itemId)
+			}
+			var item InventoryItem
+			err = s.collection.FindOne(sc, bson.D{{Key: "_id", Value: objId}}).Decode(&item)
+			if err == mongo.ErrNoDocuments {
+				return nil, itemNotFoundError(itemId)
+			}
+			if err != nil {
+				return nil, err
+			}
+			return item.proto(), nil
+		})
+		if err := write(b); err != nil {
+			return nil, err
+		}
+		for _, itemId := range b.order {
+			if err := s.replaceItem(sc, b.before[itemId].Version, b.items[itemId]); err != nil {
+				return nil, err
+			}
+		}
+		if docs := movementDocuments(b.movements); len(docs) > 0 {
+			if _, err := s.movements.InsertMany(sc, docs); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return nil, transactionError(err)
+	}
+	b.checkLowStock(&s.lowStockNotifier)
+	return b.result(), nil
+}
+
+// replaceItem writes an item over the stored document, provided that is
+// still at the version the item was read at.
+func (s *MongoStore) replaceItem(ctx context.Context, version int64, item *pb.InventoryItem) error {
+	objId, err := primitive.ObjectIDFromHex(item.Id)
+	if err != nil {
+		return err
+	}
+	doc, err := storedDocument(item)
+	if err != nil {
+		return err
+	}
+	filter := bson.D{{Key: "_id", Value: objId}, {Key: "version", Value: version}}
+	res, err := s.collection.ReplaceOne(ctx, filter, doc)
+	if err != nil {
+		return uniqueViolation(err, item)
+	}
+	if res.MatchedCount == 0 {
+		return concurrentUpdateError(item.Id)
+	}
+	return nil
+}
+
+func (s *MongoStore) TransferStock(itemId string, variantId string, fromLocationId string, toLocationId string, quantity int32, expectedVersion int64, change StockChange) (*pb.InventoryItem, error) {
+	log.Println("Transferring stock:", itemId, fromLocationId, toLocationId, quantity)
+	objId, err := primitive.ObjectIDFromHex(itemId)
+	if err != nil {
+		return nil, err
+	}
+	filter := stockFilter(withVersion(bson.D{
+		{Key: "_id", Value: objId},
+		{Key: "$expr", Value: locatedAtLeast(variantId, fromLocationId, quantity)},
+	}, expectedVersion), variantId)
+	// The total quantity stays the same, only the stored stock by location
+	// changes.
+	counters := bson.D{}
+	if fromLocationId != DefaultLocationId {
+		counters = append(counters, bson.E{Key: "stock." + fromLocationId, Value: -quantity})
+	}
+	if toLocationId != DefaultLocationId {
+		counters = append(counters, bson.E{Key: "stock." + toLocationId, Value: quantity})
+	}
+	update, opts := stockUpdate(variantId, counters)
+	var updated *pb.InventoryItem
+	err = s.transact(func(sc mongo.SessionContext) error {
+		updated, err = s.updateStock(sc, filter, update, opts)
+		if err != nil || updated == nil {
+			return err
+		}
+		return s.record(sc,
+			movement(updated, variantId, fromLocationId, -quantity, change),
+			movement(updated, variantId, toLocationId, quantity, change),
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		item, err := s.FindById(itemId)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkVersion(item, expectedVersion); err != nil {
+			return nil, err
+		}
+		if err := checkStock(item, variantId, 0, false); err != nil {
+			return nil, err
+		}
+		if err := checkLocationStock(item, variantId, fromLocationId, quantity, false); err != nil {
+			return nil, err
+		}
+		return nil, concurrentUpdateError(itemId)
+	}
+	return updated, nil
+}
+
+// stockExpression applies condition to the stock fields of an item, or of
+// its variant when variantId is set. condition is given the prefix of the
+// field paths to compare.
+func stockExpression(variantId string, condition func(prefix string) bson.D) bson.D {
+	if variantId == "" {
+		return condition("$")
+	}
+	return bson.D{{Key: "$anyElementTrue", Value: bson.A{bson.D{{Key: "$map", Value: bson.D{
+		{Key: "input", Value: "$variants"},
+		{Key: "as", Value: "v"},
+		{Key: "in", Value: bson.D{{Key: "$and", Value: bson.A{
+			bson.D{{Key: "$eq", Value: bson.A{"$$v.id", variantId}}},
+			condition("$$v."),
+		}}}},
+	}}}}}}
+}
+
+// availableAtLeast is an aggregation expression that holds when an item, or
+// its variant when variantId is set, has at least quantity units that are
+// not reserved.
+func availableAtLeast(variantId string, quantity int32) bson.D {
+	return stockExpression(variantId, func(prefix string) bson.D {
+		return bson.D{{Key: "$gte", Value: bson.A{
+			bson.D{{Key: "$subtract", Value: bson.A{
+				prefix + "quantity",
+				bson.D{{Key: "$ifNull", Value: bson.A{prefix + "reserved", 0}}},
+			}}},
+			quantity,
+		}}}
+	})
+}
+
+// locatedAtLeast is an aggregation expression that holds when an item, or
+// its variant when variantId is set, has at least quantity units at a
+// location.
+func locatedAtLeast(variantId string, locationId string, quantity int32) bson.D {
+	return stockExpression(variantId, func(prefix string) bson.D {
+		located := bson.D{{Key: "$ifNull", Value: bson.A{prefix + "stock." + locationId, 0}}}
+		if locationId == DefaultLocationId {
+			located = bson.D{{Key: "$subtract", Value: bson.A{prefix + "quantity", locatedExpr(prefix)}}}
+		}
+		return bson.D{{Key: "$gte", Value: bson.A{located, quantity}}}
+	})
+}
+
+// locatedExpr is an aggregation expression summing the stored stock at the
+// locations other than the default one.
+func locatedExpr(prefix string) bson.D {
+	return bson.D{{Key: "$sum", Value: bson.D{{Key: "$map", Value: bson.D{
+		{Key: "input", Value: bson.D{{Key: "$objectToArray", Value: bson.D{{Key: "$ifNull", Value: bson.A{prefix + "stock", bson.D{}}}}}}},
+		{Key: "in", Value: "$$this.v"},
+	}}}}}
+}
+
+func (s *MongoStore) ReserveStock(itemId string, variantId string, quantity int32, expiresAt time.Time) (*pb.Reservation, error) {
+	log.Println("Reserving stock:", itemId, quantity)
+	objId, err := primitive.ObjectIDFromHex(itemId)
+	if err != nil {
+		return nil, err
+	}
+	filter := stockFilter(bson.D{
+		{Key: "_id", Value: objId},
+		{Key: fieldKey("archivedAt"), Value: nil},
+		{Key: "$expr", Value: availableAtLeast(variantId, quantity)},
+	}, variantId)
+	update, opts := stockUpdate(variantId, bson.D{{Key: "reserved", Value: quantity}})
+	reservation := &Reservation{
+		Id:        primitive.NewObjectID(),
+		ItemId:    objId,
+		Quantity:  quantity,
+		Status:    pb.ReservationStatus_RESERVATION_PENDING,
+		ExpiresAt: expiresAt,
+		VariantId: variantId,
+	}
+	// The stock is held and the reservation holding it inserted together, so
+	// that neither is kept without the other.
+	var updated *pb.InventoryItem
+	err = s.transact(func(sc mongo.SessionContext) error {
+		updated, err = s.updateStock(sc, filter, update, opts)
+		if err != nil || updated == nil {
+			return err
+		}
+		_, err := s.reservations.InsertOne(sc, reservation)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		item, err := s.FindById(itemId)
+		if err != nil {
+			return nil, err
+		}
+		if item.ArchivedAt != nil {
+			return nil, archivedItemError(itemId)
+		}
+		if err := checkStock(item, variantId, quantity, false); err != nil {
+			return nil, err
+		}
+		return nil, concurrentUpdateError(itemId)
+	}
+	return reservation.proto(), nil
+}
+
+func (s *MongoStore) CommitReservation(reservationId string, locationId string, change StockChange) (*pb.Reservation, error) {
+	log.Println("Committing reservation:", reservationId, locationId)
+	return s.finishReservation(reservationId, pb.ReservationStatus_RESERVATION_COMMITTED, locationId, change)
+}
+
+func (s *MongoStore) ReleaseReservation(reservationId string) (*pb.Reservation, error) {
+	log.Println("Releasing reservation:", reservationId)
+	return s.finishReservation(reservationId, pb.ReservationStatus_RESERVATION_RELEASED, "", StockChange{})
+}
+
+func (s *MongoStore) finishReservation(reservationId string, newStatus pb.ReservationStatus, locationId string, change StockChange) (*pb.Reservation, error) {
+	objId, err := primitive.ObjectIDFromHex(reservationId)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.D{
+		{Key: "_id", Value: objId},
+		{Key: "status", Value: pb.ReservationStatus_RESERVATION_PENDING},
+		{Key: "expiresAt", Value: bson.D{{Key: "$gt", Value: time.Now()}}},
+	}
+	reservation, err := s.settle(filter, newStatus, locationId, change)
+	if err == nil {
+		return reservation.proto(), nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	// The reservation is either unknown, already settled or expired but not
+	// yet swept up; expire it now so the error reports its final status.
+	expired := bson.D{
+		{Key: "_id", Value: objId},
+		{Key: "status", Value: pb.ReservationStatus_RESERVATION_PENDING},
+	}
+	if _, err := s.settle(expired, pb.ReservationStatus_RESERVATION_EXPIRED, "", StockChange{}); err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var existing Reservation
+	err = s.reservations.FindOne(ctx, bson.D{{Key: "_id", Value: objId}}).Decode(&existing)
+	if err == mongo.ErrNoDocuments {
+		return nil, status.Errorf(codes.NotFound, "Could not find reservation with id "+reservationId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, reservationNotPendingError(existing.proto())
+}
+
+// settle moves the pending reservation matched by filter to newStatus and
+// adjusts the item it holds stock of, in a transaction, which needs MongoDB
+// to run as a replica set. Committed stock is taken from locationId, or from
+// the location with the most stock when it is empty, which must hold it, and
+// recorded as change. It returns mongo.ErrNoDocuments when nothing matched.
+func (s *MongoStore) settle(filter primitive.D, newStatus pb.ReservationStatus, locationId string, change StockChange) (*Reservation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	session, err := s.client.StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+	var reservation Reservation
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		update := bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: newStatus},
+			}},
+		}
+		reservation = Reservation{}
+		err := s.reservations.FindOneAndUpdate(sc, filter, update).Decode(&reservation)
+		if err != nil {
+			return nil, err
+		}
+		reservation.Status = newStatus
+		counters := stockCounters(DefaultLocationId, 0)
+		takeFrom := locationId
+		if newStatus == pb.ReservationStatus_RESERVATION_COMMITTED {
+			var item InventoryItem
+			err := s.collection.FindOne(sc, bson.D{{Key: "_id", Value: reservation.ItemId}}).Decode(&item)
+			if err != nil && err != mongo.ErrNoDocuments {
+				return nil, err
+			}
+			if err == nil {
+				if takeFrom == "" {
+					takeFrom = pickLocation(item.proto(), reservation.VariantId)
+				}
+				if err := checkLocationStock(item.proto(), reservation.VariantId, takeFrom, reservation.Quantity, false); err != nil {
+					return nil, err
+				}
+			} else if takeFrom == "" {
+				takeFrom = DefaultLocationId
+			}
+			reservation.LocationId = takeFrom
+			counters = stockCounters(takeFrom, -reservation.Quantity)
+			update := bson.D{{Key: "$set", Value: bson.D{{Key: "locationId", Value: takeFrom}}}}
+			if _, err := s.reservations.UpdateOne(sc, bson.D{{Key: "_id", Value: reservation.Id}}, update); err != nil {
+				return nil, err
+			}
+		}
+		item, err := s.adjustReserved(sc, reservation.ItemId, reservation.VariantId, -reservation.Quantity, counters)
+		if err != nil {
+			return nil, err
+		}
+		if item != nil && newStatus == pb.ReservationStatus_RESERVATION_COMMITTED {
+			if err := s.record(sc, movement(item, reservation.VariantId, takeFrom, -reservation.Quantity, change)); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return nil, transactionError(err)
+	}
+	return &reservation, nil
+}
+
+// adjustReserved adds reserved to the reserved stock of an item, together
+// with the given stock counters, and returns the item after the update.
+func (s *MongoStore) adjustReserved(ctx context.Context, itemId primitive.ObjectID, variantId string, reserved int32, counters bson.D) (*pb.InventoryItem, error) {
+	filter := bson.D{{Key: "_id", Value: itemId}}
+	update, opts := stockUpdate(variantId, append(bson.D{{Key: "reserved", Value: reserved}}, counters...))
+	var item InventoryItem
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&item)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item.proto(), nil
+}
+
+func (s *MongoStore) ExpireReservations(now time.Time) (int64, error) {
+	filter := bson.D{
+		{Key: "status", Value: pb.ReservationStatus_RESERVATION_PENDING},
+		{Key: "expiresAt", Value: bson.D{{Key: "$lte", Value: now}}},
+	}
+	var count int64
+	for {
+		_, err := s.settle(filter, pb.ReservationStatus_RESERVATION_EXPIRED, "", StockChange{})
+		if err == mongo.ErrNoDocuments {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+// findForUpdate reads an item that is about to be rewritten based on its
+// current state, checking expectedVersion. The write must then be guarded
+// by versionGuard.
+func (s *MongoStore) findForUpdate(itemId string, expectedVersion int64) (*pb.InventoryItem, error) {
+	item, err := s.FindById(itemId)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(item, expectedVersion); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// versionGuard matches item only while it is at the version it was read at.
+func versionGuard(item *pb.InventoryItem) (primitive.D, error) {
+	objId, err := primitive.ObjectIDFromHex(item.Id)
+	if err != nil {
+		return nil, err
+	}
+	return bson.D{{Key: "_id", Value: objId}, {Key: "version", Value: item.Version}}, nil
+}
+
+func (s *MongoStore) AddVariant(itemId string, variant *pb.Variant, expectedVersion int64, change StockChange) (*pb.Variant, error) {
+	log.Println("Adding variant:", itemId, variant)
+	item, err := s.findForUpdate(itemId, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	if len(item.Variants) == 0 && item.Reserved > 0 {
+		return nil, reservedItemError(itemId)
+	}
+	newVariant := proto.Clone(variant).(*pb.Variant)
+	newVariant.Id = primitive.NewObjectID().Hex()
+	newVariant.Reserved = 0
+	doc, err := variantDocument(newVariant)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := versionGuard(item)
+	if err != nil {
+		return nil, err
+	}
+	update := bson.D{{Key: "$push", Value: bson.D{{Key: "variants", Value: doc}}}}
+	if len(item.Variants) == 0 {
+		// The item's own stock is replaced by that of its variants.
+		update = append(update,
+			bson.E{Key: "$set", Value: bson.D{{Key: "quantity", Value: newVariant.Quantity}}},
+			bson.E{Key: "$unset", Value: bson.D{{Key: "stock", Value: ""}}})
+	} else {
+		update = append(update, bson.E{Key: "$inc", Value: bson.D{{Key: "quantity", Value: newVariant.Quantity}}})
+	}
+	var movements []*pb.StockMovement
+	if len(item.Variants) == 0 {
+		movements = clearStock(item, "", StockChange{Reason: pb.MovementReason_MOVEMENT_ADJUSTMENT, Actor: change.Actor})
+	}
+	item.Variants = append(item.Variants, newVariant)
+	item.Quantity += newVariant.Quantity
+	movements = append(movements, movement(item, newVariant.Id, DefaultLocationId, newVariant.Quantity, change))
+	err = s.transact(func(sc mongo.SessionContext) error {
+		res, err := s.collection.UpdateOne(sc, filter, withTouch(update))
+		if err != nil {
+			return variantSkuViolation(err, newVariant)
+		}
+		if res.MatchedCount == 0 {
+			return concurrentUpdateError(itemId)
+		}
+		return s.record(sc, movements...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	setVariantAvailable(newVariant)
+	return newVariant, nil
+}
+
+func (s *MongoStore) UpdateVariant(itemId string, variant *pb.Variant, paths []string, expectedVersion int64) (*pb.Variant, error) {
+	log.Println("Updating variant:", itemId, variant)
+	paths, err := variantPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := variantDocument(variant)
+	if err != nil {
+		return nil, err
+	}
+	objId, err := primitive.ObjectIDFromHex(itemId)
+	if err != nil {
+		return nil, err
+	}
+	set := bson.D{}
+	for _, path := range paths {
+		set = append(set, bson.E{Key: "variants.$[v]." + fieldKey(path), Value: doc[fieldKey(path)]})
+	}
+	filter := withVersion(bson.D{{Key: "_id", Value: objId}, {Key: "variants.id", Value: variant.Id}}, expectedVersion)
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{Filters: bson.A{bson.D{{Key: "v.id", Value: variant.Id}}}})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res, err := s.collection.UpdateOne(ctx, filter, withTouch(bson.D{{Key: "$set", Value: set}}), opts)
+	if err != nil {
+		return nil, variantSkuViolation(err, variant)
+	}
+	item, err := s.FindById(itemId)
+	if err != nil {
+		return nil, err
+	}
+	if res.MatchedCount == 0 {
+		if err := checkVersion(item, expectedVersion); err != nil {
+			return nil, err
+		}
+	}
+	updated := findVariant(item, variant.Id)
+	if updated == nil {
+		return nil, variantNotFoundError(itemId, variant.Id)
+	}
+	return updated, nil
+}
+
+func (s *MongoStore) RemoveVariant(itemId string, variantId string, expectedVersion int64, change StockChange) (int64, error) {
+	log.Println("Removing variant:", itemId, variantId)
+	item, err := s.findForUpdate(itemId, expectedVersion)
+	if status.Code(err) == codes.NotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	variant := findVariant(item, variantId)
+	if variant == nil {
+		return 0, nil
+	}
+	if variant.Reserved > 0 {
+		return 0, reservedVariantError(itemId, variantId)
+	}
+	filter, err := versionGuard(item)
+	if err != nil {
+		return 0, err
+	}
+	inc := bson.D{{Key: "quantity", Value: -variant.Quantity}}
+	for locationId, located := range variant.Stock {
+		if locationId != DefaultLocationId {
+			inc = append(inc, bson.E{Key: "stock." + locationId, Value: -located})
+		}
+	}
+	update := withTouch(bson.D{
+		{Key: "$pull", Value: bson.D{{Key: "variants", Value: bson.D{{Key: "id", Value: variantId}}}}},
+		{Key: "$inc", Value: inc},
+	})
+	err = s.transact(func(sc mongo.SessionContext) error {
+		res, err := s.collection.UpdateOne(sc, filter, update)
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount == 0 {
+			return concurrentUpdateError(itemId)
+		}
+		return s.record(sc, clearStock(item, variantId, change)...)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+func (s *MongoStore) ListCategories() ([]*pb.Category, error) {
+	log.Println("Listing categories")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cursor, err := s.categories.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var result []*pb.Category
+	for cursor.Next(ctx) {
+		var category Category
+		if err := cursor.Decode(&category); err != nil {
+			return nil, err
+		}
+		result = append(result, category.proto())
+	}
+	return result, cursor.Err()
+}
+
+func (s *MongoStore) FindCategory(categoryId string) (*pb.Category, error) {
+	log.Println("Finding category by id:", categoryId)
+	objId, err := primitive.ObjectIDFromHex(categoryId)
+	if err != nil {
+		return nil, categoryNotFoundError(categoryId)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var category Category
+	err = s.categories.FindOne(ctx, bson.D{{Key: "_id", Value: objId}}).Decode(&category)
+	if err == mongo.ErrNoDocuments {
+		return nil, categoryNotFoundError(categoryId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return category.proto(), nil
+}
+
+func (s *MongoStore) InsertCategory(category *pb.Category) (*pb.Category, error) {
+	log.Println("Inserting category:", category)
+	doc, err := categoryDocument(category)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res, err := s.categories.InsertOne(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	doc.Id = res.InsertedID.(primitive.ObjectID)
+	return doc.proto(), nil
+}
+
+func (s *MongoStore) UpdateCategory(category *pb.Category) (*pb.Category, error) {
+	log.Println("Updating category:", category)
+	objId, err := primitive.ObjectIDFromHex(category.Id)
+	if err != nil {
+		return nil, categoryNotFoundError(category.Id)
+	}
+	doc, err := categoryDocument(category)
+	if err != nil {
+		return nil, err
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: doc.Name}}}}
+	if doc.ParentId.IsZero() {
+		update = append(update, bson.E{Key: "$unset", Value: bson.D{{Key: "parentId", Value: ""}}})
+	} else {
+		update[0].Value = append(update[0].Value.(bson.D), bson.E{Key: "parentId", Value: doc.ParentId})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var updated Category
+	err = s.categories.FindOneAndUpdate(ctx, bson.D{{Key: "_id", Value: objId}}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return nil, categoryNotFoundError(category.Id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return updated.proto(), nil
+}
+
+func (s *MongoStore) DeleteCategory(categoryId string) (int64, error) {
+	log.Println("Deleting category:", categoryId)
+	objId, err := primitive.ObjectIDFromHex(categoryId)
+	if err != nil {
+		return 0, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res, err := s.categories.DeleteOne(ctx, bson.D{{Key: "_id", Value: objId}})
+	if err != nil {
+		return 0, err
+	}
+	filter := bson.D{{Key: fieldKey("categoryIds"), Value: categoryId}}
+	update := withTouch(bson.D{
+		{Key: "$pull", Value: bson.D{{Key: fieldKey("categoryIds"), Value: categoryId}}},
+	})
+	if _, err := s.collection.UpdateMany(ctx, filter, update); err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+func (s *MongoStore) ListLocations() ([]*pb.Location, error) {
+	log.Println("Listing locations")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cursor, err := s.locations.Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var result []*pb.Location
+	for cursor.Next(ctx) {
+		var location Location
+		if err := cursor.Decode(&location); err != nil {
+			return nil, err
+		}
+		result = append(result, location.proto())
+	}
+	sortLocations(result)
+	return result, cursor.Err()
+}
+
+func (s *MongoStore) FindLocation(locationId string) (*pb.Location, error) {
+	log.Println("Finding location by id:", locationId)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var location Location
+	err := s.locations.FindOne(ctx, bson.D{{Key: "_id", Value: locationId}}).Decode(&location)
+	if err == mongo.ErrNoDocuments {
+		return nil, locationNotFoundError(locationId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return location.proto(), nil
+}
+
+func (s *MongoStore) InsertLocation(location *pb.Location) (*pb.Location, error) {
+	log.Println("Inserting location:", location)
+	doc := &Location{Id: primitive.NewObjectID().Hex(), Name: location.Name}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := s.locations.InsertOne(ctx, doc); err != nil {
+		return nil, err
+	}
+	return doc.proto(), nil
+}
+
+func (s *MongoStore) UpdateLocation(location *pb.Location) (*pb.Location, error) {
+	log.Println("Updating location:", location)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: location.Name}}}}
+	var updated Location
+	err := s.locations.FindOneAndUpdate(ctx, bson.D{{Key: "_id", Value: location.Id}}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return nil, locationNotFoundError(location.Id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return updated.proto(), nil
+}
+
+func (s *MongoStore) DeleteLocation(locationId string) (int64, error) {
+	log.Println("Deleting location:", locationId)
+	if locationId == DefaultLocationId {
+		return 0, defaultLocationError()
+	}
+	if _, err := s.FindLocation(locationId); status.Code(err) == codes.NotFound {
+		return 0, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	key := "stock." + locationId
+	holding, err := s.collection.CountDocuments(ctx, bson.D{{Key: key, Value: bson.D{
+		{Key: "$exists", Value: true},
+		{Key: "$ne", Value: 0},
+	}}})
+	if err != nil {
+		return 0, err
+	}
+	if holding > 0 {
+		return 0, locationInUseError(locationId)
+	}
+	res, err := s.locations.DeleteOne(ctx, bson.D{{Key: "_id", Value: locationId}})
+	if err != nil {
+		return 0, err
+	}
+	// Drop the emptied counters left behind on items and their variants.
+	for _, path := range []string{key, "variants.$[]." + key} {
+		filter := bson.D{{Key: strings.Replace(path, ".$[]", "", 1), Value: bson.D{{Key: "$exists", Value: true}}}}
+		unset := bson.D{{Key: "$unset", Value: bson.D{{Key: path, Value: ""}}}}
+		if _, err := s.collection.UpdateMany(ctx, filter, unset); err != nil {
+			return 0, err
+		}
+	}
+	return res.DeletedCount, nil
+}
+
+func (s *MongoStore) ListStockMovements(itemId string, send func(*pb.StockMovement) error) error {
+	log.Println("Listing stock movements:", itemId)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := s.movements.Find(ctx, bson.D{{Key: "itemid", Value: itemId}}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var movement StockMovement
+		if err := cursor.Decode(&movement); err != nil {
+			return err
+		}
+		if err := send(movement.proto()); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func (s *MongoStore) ListLowStockItems(send func(*pb.InventoryItem) error) error {
+	log.Println("Listing low stock items")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	filter := bson.D{
+		{Key: fieldKey("archivedAt"), Value: nil},
+		{Key: fieldKey("reorderPoint"), Value: bson.D{{Key: "$gt", Value: 0}}},
+		{Key: "$expr", Value: bson.D{{Key: "$lte", Value: bson.A{"$quantity", "$" + fieldKey("reorderPoint")}}}},
+	}
+	cursor, err := s.collection.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		item, err := decodeItem(cursor)
+		if err != nil {
+			return err
+		}
+		if err := send(item); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// changeEvent is the part of a change stream event WatchItems reads.
+type changeEvent struct {
+	OperationType string              `bson:"operationType"`
+	ClusterTime   primitive.Timestamp `bson:"clusterTime"`
+	FullDocument  *InventoryItem      `bson:"fullDocument"`
+	DocumentKey   struct {
+		Id primitive.ObjectID `bson:"_id"`
+	} `bson:"documentKey"`
+	UpdateDescription struct {
+		UpdatedFields bson.M   `bson:"updatedFields"`
+		RemovedFields []string `bson:"removedFields"`
+	} `bson:"updateDescription"`
+}
+
+// stockFieldKeys are the item and variant fields that change with stock.
+var stockFieldKeys = map[string]bool{
+	"quantity":            true,
+	"reserved":            true,
+	"stock":               true,
+	"version":             true,
+	fieldKey("updatedAt"): true,
+}
+
+var variantFieldPrefix = regexp.MustCompile(`^variants\.\d+\.`)
+
+// changeType tells what kind of change to an item a change stream event is.
+func (e *changeEvent) changeType() pb.ItemChangeType {
+	switch e.OperationType {
+	case "insert":
+		return pb.ItemChangeType_ITEM_CREATED
+	case "delete":
+		return pb.ItemChangeType_ITEM_DELETED
+	case "update":
+		if _, ok := e.UpdateDescription.UpdatedFields[fieldKey("archivedAt")]; ok {
+			return pb.ItemChangeType_ITEM_ARCHIVED
+		}
+		for _, key := range e.UpdateDescription.RemovedFields {
+			if key == fieldKey("archivedAt") {
+				return pb.ItemChangeType_ITEM_RESTORED
+			}
+		}
+		keys := e.UpdateDescription.RemovedFields
+		for key := range e.UpdateDescription.UpdatedFields {
+			keys = append(keys, key)
+		}
+		for _, key := range keys {
+			key = variantFieldPrefix.ReplaceAllString(key, "")
+			if !stockFieldKeys[strings.SplitN(key, ".", 2)[0]] {
+				return pb.ItemChangeType_ITEM_UPDATED
+			}
+		}
+		return pb.ItemChangeType_ITEM_QUANTITY_CHANGED
+	}
+	return pb.ItemChangeType_ITEM_UPDATED
+}
+
+// WatchItems follows a change stream on the item collection, which needs
+// MongoDB to run as a replica set. Resume tokens are the change stream's
+// own, so they last as long as the oplog holds the change.
+func (s *MongoStore) WatchItems(ctx context.Context, query WatchQuery, send func(*pb.ItemChange) error) error {
+	log.Println("Watching items:", query.ItemIds, query.ResumeToken)
+	pipeline := mongo.Pipeline{bson.D{{Key: "$match", Value: bson.D{
+		{Key: "operationType", Value: bson.D{{Key: "$in", Value: bson.A{"insert", "update", "replace", "delete"}}}},
+	}}}}
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if query.ResumeToken != "" {
+		token, err := base64.RawURLEncoding.DecodeString(query.ResumeToken)
+		if err != nil || bson.Raw(token).Validate() != nil {
+			return invalidResumeTokenError()
+		}
+		opts.SetResumeAfter(bson.Raw(token))
+	}
+	stream, err := s.collection.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return changeStreamError(err)
+	}
+	defer stream.Close(context.Background())
+	match := watchMatcher(query)
+	for stream.Next(ctx) {
+		var event changeEvent
+		if err := stream.Decode(&event); err != nil {
+			return err
+		}
+		change := &pb.ItemChange{
+			ResumeToken: base64.RawURLEncoding.EncodeToString(stream.ResumeToken()),
+			Type:        event.changeType(),
+			ItemId:      event.DocumentKey.Id.Hex(),
+			OccurredAt:  timestamppb.New(time.Unix(int64(event.ClusterTime.T), 0)),
+		}
+		if event.FullDocument != nil && change.Type != pb.ItemChangeType_ITEM_DELETED {
+			change.Item = event.FullDocument.proto()
+		}
+		if match(change) {
+			if err := send(change); err != nil {
+				return err
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return changeStreamError(stream.Err())
+}
+
+// changeStreamHistoryLost is the server error for resuming a change stream
+// after a change the oplog no longer holds.
+const changeStreamHistoryLost = 286
+
+func changeStreamError(err error) error {
+	if serverErr, ok := err.(mongo.ServerError); ok && serverErr.HasErrorCode(changeStreamHistoryLost) {
+		return resumeTokenExpiredError()
+	}
+	return err
+}
+
+// illegalOperation is the error code of servers that do not support an
+// operation, such as transactions on a standalone server.
+const illegalOperation = 20
+
+func transactionError(err error) error {
+	if serverErr, ok := err.(mongo.ServerError); ok && serverErr.HasErrorCode(illegalOperation) {
+		return status.Errorf(codes.FailedPrecondition, "this write needs MongoDB to run as a replica set")
+	}
+	return err
+}
+
+// ClaimIdempotencyKey drops an expired call with key before claiming it, as
+// expired calls are only swept away periodically. The unique _id decides
+// between concurrent claims.
+func (s *MongoStore) ClaimIdempotencyKey(key string, requestHash string, expiresAt time.Time) (*IdempotentCall, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := s.calls.DeleteOne(ctx, bson.D{
+		{Key: "_id", Value: key},
+		{Key: "expiresat", Value: bson.D{{Key: "$lte", Value: time.Now()}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.calls.InsertOne(ctx, IdempotentCall{Key: key, RequestHash: requestHash, ExpiresAt: expiresAt})
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+	var call IdempotentCall
+	err = s.calls.FindOne(ctx, bson.D{{Key: "_id", Value: key}}).Decode(&call)
+	if err == mongo.ErrNoDocuments {
+		return nil, status.Errorf(codes.Aborted, "call with idempotency key %s was released concurrently", key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &call, nil
+}
+
+func (s *MongoStore) CompleteIdempotencyKey(key string, responseType string, response []byte, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	result, err := s.calls.UpdateOne(ctx, bson.D{{Key: "_id", Value: key}}, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "done", Value: true},
+		{Key: "responsetype", Value: responseType},
+		{Key: "response", Value: response},
+		{Key: "expiresat", Value: expiresAt},
+	}}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return status.Errorf(codes.NotFound, "Could not find call with idempotency key "+key)
+	}
+	return nil
+}
+
+func (s *MongoStore) ReleaseIdempotencyKey(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := s.calls.DeleteOne(ctx, bson.D{{Key: "_id", Value: key}})
+	return err
+}
+
+func (s *MongoStore) ExpireIdempotencyKeys(now time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	result, err := s.calls.DeleteMany(ctx, bson.D{{Key: "expiresat", Value: bson.D{{Key: "$lte", Value: now}}}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// pendingPrices matches the scheduled prices that are yet to end.
+var pendingPrices = bson.D{{Key: "$in", Value: bson.A{pb.ScheduledPriceStatus_PRICE_SCHEDULED, pb.ScheduledPriceStatus_PRICE_ACTIVE}}}
+
+// InsertScheduledPrice checks for overlaps and inserts in a transaction,
+// which needs MongoDB to run as a replica set. Transactions that only read
+// the same prices do not conflict, so each also bumps a guard document of
+// the item: of two prices scheduled for an item at the same moment, one
+// transaction is retried and then sees the other price.
+func (s *MongoStore) InsertScheduledPrice(price *pb.ScheduledPrice) (*pb.ScheduledPrice, error) {
+	log.Println("Scheduling price:", price.ItemId, price)
+	if _, err := s.FindById(price.ItemId); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	session, err := s.client.StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+	doc := &ScheduledPrice{Id: primitive.NewObjectID()}
+	copier.Copy(&doc.ScheduledPrice, price)
+	doc.ScheduledPrice.Id = ""
+	doc.Status = pb.ScheduledPriceStatus_PRICE_SCHEDULED
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		guard := bson.D{{Key: "$inc", Value: bson.D{{Key: "writes", Value: 1}}}}
+		_, err := s.priceGuards.UpdateOne(sc, bson.D{{Key: "_id", Value: price.ItemId}}, guard, options.Update().SetUpsert(true))
+		if err != nil {
+			return nil, err
+		}
+		cursor, err := s.prices.Find(sc, bson.D{{Key: "itemid", Value: price.ItemId}, {Key: "status", Value: pendingPrices}})
+		if err != nil {
+			return nil, err
+		}
+		var pending []ScheduledPrice
+		if err := cursor.All(sc, &pending); err != nil {
+			return nil, err
+		}
+		for i := range pending {
+			if other := pending[i].proto(); pricesOverlap(price, other) {
+				return nil, priceOverlapError(price, other)
+			}
+		}
+		_, err = s.prices.InsertOne(sc, doc)
+		return nil, err
+	})
+	if err != nil {
+		return nil, transactionError(err)
+	}
+	return doc.proto(), nil
+}
+
+func (s *MongoStore) CancelScheduledPrice(priceId string, now time.Time) (*pb.ScheduledPrice, error) {
+	log.Println("Cancelling scheduled price:", priceId)
+	objId, err := primitive.ObjectIDFromHex(priceId)
+	if err != nil {
+		return nil, priceNotFoundError(priceId)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	filter := bson.D{{Key: "_id", Value: objId}, {Key: "status", Value: pendingPrices}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: pb.ScheduledPriceStatus_PRICE_CANCELLED},
+		{Key: "endedat", Value: now},
+	}}}
+	var price ScheduledPrice
+	err = s.prices.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&price)
+	if err == mongo.ErrNoDocuments {
+		if err := s.prices.FindOne(ctx, bson.D{{Key: "_id", Value: objId}}).Decode(&price); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, priceNotFoundError(priceId)
+			}
+			return nil, err
+		}
+		return nil, priceEndedError(price.proto())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return price.proto(), nil
+}
+
+func (s *MongoStore) ListScheduledPrices(itemId string) ([]*pb.ScheduledPrice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	opts := options.Find().SetSort(bson.D{{Key: "effectivefrom", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := s.prices.Find(ctx, bson.D{{Key: "itemid", Value: itemId}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []ScheduledPrice
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	prices := make([]*pb.ScheduledPrice, 0, len(docs))
+	for i := range docs {
+		prices = append(prices, docs[i].proto())
+	}
+	sortPrices(prices)
+	return prices, nil
+}
+
+func (s *MongoStore) ActivateScheduledPrices(now time.Time) ([]*pb.ScheduledPrice, error) {
+	filter := bson.D{
+		{Key: "status", Value: pb.ScheduledPriceStatus_PRICE_SCHEDULED},
+		{Key: "effectivefrom", Value: bson.D{{Key: "$lte", Value: now}}},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "effectiveto", Value: nil}},
+			bson.D{{Key: "effectiveto", Value: bson.D{{Key: "$gt", Value: now}}}},
+		}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: pb.ScheduledPriceStatus_PRICE_ACTIVE},
+		{Key: "activatedat", Value: now},
+	}}}
+	return s.settlePrices(filter, update)
+}
+
+func (s *MongoStore) ExpireScheduledPrices(now time.Time) ([]*pb.ScheduledPrice, error) {
+	filter := bson.D{
+		{Key: "status", Value: pendingPrices},
+		{Key: "effectiveto", Value: bson.D{{Key: "$lte", Value: now}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: pb.ScheduledPriceStatus_PRICE_EXPIRED},
+		{Key: "endedat", Value: now},
+	}}}
+	return s.settlePrices(filter, update)
+}
+
+// settlePrices applies update to the scheduled prices matching filter one
+// at a time, so that each price is handed out by one caller only, and
+// returns them.
+func (s *MongoStore) settlePrices(filter primitive.D, update primitive.D) ([]*pb.ScheduledPrice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var settled []*pb.ScheduledPrice
+	for {
+		var price ScheduledPrice
+		err := s.prices.FindOneAndUpdate(ctx, filter, update, opts).Decode(&price)
+		if err == mongo.ErrNoDocuments {
+			sortPrices(settled)
+			return settled, nil
+		}
+		if err != nil {
+			return settled, err
+		}
+		settled = append(settled, price.proto())
+	}
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Page selects a window of a listing. A zero Size means no limit.
+type Page struct {
+	Size  int32
+	Token string
+}
+
+// PageInfo describes where a listing stopped. NextPageToken is empty on the
+// last page.
+type PageInfo struct {
+	NextPageToken string
+	TotalCount    int64
+}
+
+// pageCursor is the decoded form of a page token. It records the sort order
+// of the listing and the sort key and id of the last item handed out.
+type pageCursor struct {
+	SortBy    pb.ItemSortField `json:"sort,omitempty"`
+	Direction pb.SortDirection `json:"dir,omitempty"`
+	After     string           `json:"after"`
+	Key       json.RawMessage  `json:"key,omitempty"`
+}
+
+func encodePageToken(cursor pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// nextPageToken returns the token that continues query after item.
+func nextPageToken(query ItemQuery, item *pb.InventoryItem) string {
+	cursor := pageCursor{SortBy: query.SortBy, Direction: query.Direction, After: item.Id}
+	if query.SortBy != pb.ItemSortField_ITEM_SORT_ID {
+		cursor.Key, _ = json.Marshal(sortKey(item, query.SortBy))
+	}
+	return encodePageToken(cursor)
+}
+
+// decodePageToken parses the page token of query. The sort key is returned
+// in the type sortKey produces for the sort field, or nil on the first page.
+func decodePageToken(query ItemQuery) (pageCursor, interface{}, error) {
+	var cursor pageCursor
+	if query.Page.Token == "" {
+		return cursor, nil, nil
+	}
+	invalid := status.Errorf(codes.InvalidArgument, "invalid page token")
+	data, err := base64.RawURLEncoding.DecodeString(query.Page.Token)
+	if err != nil {
+		return cursor, nil, invalid
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, nil, invalid
+	}
+	if cursor.SortBy != query.SortBy || cursor.Direction != query.Direction {
+		return cursor, nil, status.Errorf(codes.InvalidArgument, "page token does not match the sort order")
+	}
+	var key interface{}
+	switch query.SortBy {
+	case pb.ItemSortField_ITEM_SORT_ID:
+		key = cursor.After
+	case pb.ItemSortField_ITEM_SORT_NAME:
+		var name string
+		err = json.Unmarshal(cursor.Key, &name)
+		key = name
+	case pb.ItemSortField_ITEM_SORT_QUANTITY:
+		var quantity int32
+		err = json.Unmarshal(cursor.Key, &quantity)
+		key = quantity
+	case pb.ItemSortField_ITEM_SORT_CREATED_AT, pb.ItemSortField_ITEM_SORT_UPDATED_AT:
+		var at time.Time
+		err = json.Unmarshal(cursor.Key, &at)
+		key = at
+	}
+	if err != nil {
+		return cursor, nil, invalid
+	}
+	return cursor, key, nil
+}
+
+// sortKey returns the value item is ordered by under field. Times are
+// truncated to milliseconds, the precision MongoDB stores them with.
+func sortKey(item *pb.InventoryItem, field pb.ItemSortField) interface{} {
+	switch field {
+	case pb.ItemSortField_ITEM_SORT_NAME:
+		return item.Name
+	case pb.ItemSortField_ITEM_SORT_QUANTITY:
+		return item.Quantity
+	case pb.ItemSortField_ITEM_SORT_CREATED_AT:
+		return item.CreatedAt.AsTime().Truncate(time.Millisecond)
+	case pb.ItemSortField_ITEM_SORT_UPDATED_AT:
+		return item.UpdatedAt.AsTime().Truncate(time.Millisecond)
+	}
+	return item.Id
+}
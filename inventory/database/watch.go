@@ -0,0 +1,146 @@
+package database
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// changeHistorySize is how many of the latest changes the memory store
+// keeps at least for watchers resuming from a token.
+const changeHistorySize = 1000
+
+// WatchQuery selects the changes a watch sends. Empty fields select
+// everything.
+type WatchQuery struct {
+	ItemIds []string
+	// Filter matches the item after the change. Deletions and archivals
+	// are not matched against it.
+	Filter      *pb.ItemFilter
+	Types       []pb.ItemChangeType
+	ResumeToken string
+}
+
+// watchMatcher turns a watch query into a predicate over changes.
+func watchMatcher(query WatchQuery) func(*pb.ItemChange) bool {
+	var matchItem func(*pb.InventoryItem) bool
+	if query.Filter != nil {
+		matchItem = itemMatcher(query.Filter)
+	}
+	return func(change *pb.ItemChange) bool {
+		if len(query.ItemIds) > 0 && !containsPath(query.ItemIds, change.ItemId) {
+			return false
+		}
+		if len(query.Types) > 0 && !containsType(query.Types, change.Type) {
+			return false
+		}
+		if matchItem == nil || change.Type == pb.ItemChangeType_ITEM_DELETED || change.Type == pb.ItemChangeType_ITEM_ARCHIVED {
+			return true
+		}
+		return change.Item != nil && matchItem(change.Item)
+	}
+}
+
+func containsType(types []pb.ItemChangeType, changeType pb.ItemChangeType) bool {
+	for _, t := range types {
+		if t == changeType {
+			return true
+		}
+	}
+	return false
+}
+
+// broadcaster hands out the changes to the items of the memory store to
+// its watchers. Changes are numbered in the order they are published and
+// their number is their resume token. The latest changes are kept so that
+// watchers can catch up after falling behind or reconnecting; watchers read
+// from that history at their own pace rather than being pushed to.
+type broadcaster struct {
+	mu sync.Mutex
+	// seq is the number of the latest change.
+	seq uint64
+	// history holds the latest changes, oldest first, up to seq.
+	history []*pb.ItemChange
+	// next is closed when the next change is published.
+	next chan struct{}
+}
+
+func newBroadcaster() *broadcaster {
+	return &broadcaster{next: make(chan struct{})}
+}
+
+// publish numbers change and hands it to the watchers. It must not be
+// modified afterwards.
+func (b *broadcaster) publish(change *pb.ItemChange) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	change.ResumeToken = strconv.FormatUint(b.seq, 10)
+	b.history = append(b.history, change)
+	if len(b.history) > 2*changeHistorySize {
+		b.history = append([]*pb.ItemChange(nil), b.history[len(b.history)-changeHistorySize:]...)
+	}
+	close(b.next)
+	b.next = make(chan struct{})
+}
+
+// since returns the changes after the one numbered seq, and a channel
+// that is closed when there are more.
+func (b *broadcaster) since(seq uint64) ([]*pb.ItemChange, <-chan struct{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	first := b.seq - uint64(len(b.history)) + 1
+	if seq+1 < first {
+		return nil, nil, resumeTokenExpiredError()
+	}
+	if seq > b.seq {
+		return nil, nil, invalidResumeTokenError()
+	}
+	return b.history[seq+1-first:], b.next, nil
+}
+
+// watch calls send for the changes matching query until ctx is done or
+// send fails.
+func (b *broadcaster) watch(ctx context.Context, query WatchQuery, send func(*pb.ItemChange) error) error {
+	b.mu.Lock()
+	seq := b.seq
+	b.mu.Unlock()
+	if query.ResumeToken != "" {
+		var err error
+		if seq, err = strconv.ParseUint(query.ResumeToken, 10, 64); err != nil {
+			return invalidResumeTokenError()
+		}
+	}
+	match := watchMatcher(query)
+	for {
+		changes, next, err := b.since(seq)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			if match(change) {
+				if err := send(change); err != nil {
+					return err
+				}
+			}
+			seq++
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-next:
+		}
+	}
+}
+
+func invalidResumeTokenError() error {
+	return status.Errorf(codes.InvalidArgument, "invalid resume token")
+}
+
+func resumeTokenExpiredError() error {
+	return status.Errorf(codes.OutOfRange, "resume token has expired, changes after it are no longer available")
+}
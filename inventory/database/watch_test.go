@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/grpc/codes"
+)
+
+// publishChanges publishes a quantity change of each item to b.
+func publishChanges(b *broadcaster, itemIds ...string) {
+	for _, itemId := range itemIds {
+		b.publish(&pb.ItemChange{Type: pb.ItemChangeType_ITEM_QUANTITY_CHANGED, ItemId: itemId})
+	}
+}
+
+// watchFor watches b until want changes arrived or, when it expects none,
+// a moment passed, and returns the ids of the items changed.
+func watchFor(b *broadcaster, query WatchQuery, want int) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	if want == 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	}
+	defer cancel()
+	var itemIds []string
+	err := b.watch(ctx, query, func(change *pb.ItemChange) error {
+		itemIds = append(itemIds, change.ItemId)
+		if len(itemIds) == want {
+			cancel()
+		}
+		return nil
+	})
+	if err == context.Canceled || (want == 0 && err == context.DeadlineExceeded) {
+		err = nil
+	}
+	return itemIds, err
+}
+
+func TestBroadcasterResume(t *testing.T) {
+	tests := []struct {
+		name  string
+		query WatchQuery
+		want  []string
+		code  codes.Code
+	}{
+		{name: "from the start", query: WatchQuery{ResumeToken: "0"}, want: []string{"a", "b", "c", "a"}},
+		{name: "after a change", query: WatchQuery{ResumeToken: "2"}, want: []string{"c", "a"}},
+		{name: "after the latest change", query: WatchQuery{ResumeToken: "4"}},
+		{name: "without token", query: WatchQuery{}},
+		{name: "of some items", query: WatchQuery{ResumeToken: "0", ItemIds: []string{"a"}}, want: []string{"a", "a"}},
+		{name: "of some types", query: WatchQuery{ResumeToken: "0", Types: []pb.ItemChangeType{pb.ItemChangeType_ITEM_DELETED}}},
+		{name: "from the future", query: WatchQuery{ResumeToken: "5"}, code: codes.InvalidArgument},
+		{name: "from a malformed token", query: WatchQuery{ResumeToken: "a"}, code: codes.InvalidArgument},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := newBroadcaster()
+			publishChanges(b, "a", "b", "c", "a")
+			got, err := watchFor(b, test.query, len(test.want))
+			checkCode(t, err, test.code)
+			if len(got) != len(test.want) {
+				t.Fatalf("got changes of %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Fatalf("got changes of %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBroadcasterFollowsNewChanges(t *testing.T) {
+	b := newBroadcaster()
+	publishChanges(b, "a")
+	done := make(chan []string)
+	go func() {
+		got, _ := watchFor(b, WatchQuery{}, 2)
+		done <- got
+	}()
+	// The watch starts with the next change, so wait until it is waiting
+	// for one before publishing.
+	time.Sleep(20 * time.Millisecond)
+	publishChanges(b, "b", "c")
+	select {
+	case got := <-done:
+		if len(got) != 2 || got[0] != "b" || got[1] != "c" {
+			t.Errorf("got changes of %v, want [b c]", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("watch did not receive the new changes")
+	}
+}
+
+func TestBroadcasterResumeTokenExpires(t *testing.T) {
+	b := newBroadcaster()
+	for i := 0; i < 2*changeHistorySize+1; i++ {
+		publishChanges(b, strconv.Itoa(i))
+	}
+	latest := uint64(2*changeHistorySize + 1)
+	tests := []struct {
+		name  string
+		after uint64
+		code  codes.Code
+	}{
+		{"before the history", 0, codes.OutOfRange},
+		{"just before the history", latest - changeHistorySize - 1, codes.OutOfRange},
+		{"at the start of the history", latest - changeHistorySize, codes.OK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query := WatchQuery{ResumeToken: strconv.FormatUint(test.after, 10)}
+			want := 0
+			if test.code == codes.OK {
+				want = int(latest - test.after)
+			}
+			got, err := watchFor(b, query, want)
+			checkCode(t, err, test.code)
+			if len(got) != want {
+				t.Errorf("got %d changes, want %d", len(got), want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreWatchItemsResume(t *testing.T) {
+	store := NewMemoryStore()
+	var tokens []string
+	mug := insertItem(t, store, &pb.InventoryItem{Name: "Mug", Quantity: 1})
+	if _, err := store.IncrementItemQuantity(mug.Id, "", "", 2, false, 0, receive); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var types []pb.ItemChangeType
+	err := store.WatchItems(ctx, WatchQuery{ResumeToken: "0"}, func(change *pb.ItemChange) error {
+		types = append(types, change.Type)
+		tokens = append(tokens, change.ResumeToken)
+		if len(types) == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if err != context.Canceled {
+		t.Fatal(err)
+	}
+	want := []pb.ItemChangeType{pb.ItemChangeType_ITEM_CREATED, pb.ItemChangeType_ITEM_QUANTITY_CHANGED}
+	if len(types) != 2 || types[0] != want[0] || types[1] != want[1] {
+		t.Fatalf("got changes %v, want %v", types, want)
+	}
+	if tokens[0] == "" || tokens[0] == tokens[1] {
+		t.Errorf("changes have resume tokens %q, want distinct ones", tokens)
+	}
+}
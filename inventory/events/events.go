@@ -0,0 +1,85 @@
+// Package events delivers the events published by the inventory service.
+package events
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const webhookQueueSize = 100
+
+// Publisher delivers inventory events. Publish must not block, as events
+// are published while the store is being written.
+type Publisher interface {
+	Publish(event *pb.InventoryEvent)
+}
+
+// Init creates the publisher selected by the EVENT_WEBHOOK_URL environment
+// variable: events are posted to that URL when it is set and only logged
+// otherwise.
+func Init() Publisher {
+	if url, exists := os.LookupEnv("EVENT_WEBHOOK_URL"); exists && url != "" {
+		return NewWebhookPublisher(url)
+	}
+	return LogPublisher{}
+}
+
+// LogPublisher writes events to the log.
+type LogPublisher struct{}
+
+func (LogPublisher) Publish(event *pb.InventoryEvent) {
+	log.Println("Publishing event:", protojson.MarshalOptions{}.Format(event))
+}
+
+// WebhookPublisher posts events as JSON to a URL, one request per event, in
+// the order they were published. Events that cannot be delivered are
+// logged and dropped.
+type WebhookPublisher struct {
+	url    string
+	client *http.Client
+	queue  chan *pb.InventoryEvent
+}
+
+func NewWebhookPublisher(url string) *WebhookPublisher {
+	p := &WebhookPublisher{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		queue:  make(chan *pb.InventoryEvent, webhookQueueSize),
+	}
+	go p.deliver()
+	return p
+}
+
+func (p *WebhookPublisher) Publish(event *pb.InventoryEvent) {
+	log.Println("Publishing event:", protojson.MarshalOptions{}.Format(event))
+	select {
+	case p.queue <- event:
+	default:
+		log.Println("Dropping event, webhook queue is full")
+	}
+}
+
+func (p *WebhookPublisher) deliver() {
+	for event := range p.queue {
+		body, err := protojson.Marshal(event)
+		if err != nil {
+			log.Println("Could not encode event:", err)
+			continue
+		}
+		res, err := p.client.Post(p.url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Println("Could not deliver event:", err)
+			continue
+		}
+		res.Body.Close()
+		if res.StatusCode >= 300 {
+			log.Println("Could not deliver event:", res.Status)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package itemio
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+)
+
+// csvReader reads a CSV document whose first record names the columns.
+// Columns may come in any order and any of them but sku may be left out.
+type csvReader struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+func newCSVReader(r io.Reader) *csvReader {
+	reader := csv.NewReader(r)
+	// Rows with a wrong number of cells are reported by Read.
+	reader.FieldsPerRecord = -1
+	return &csvReader{reader: reader}
+}
+
+func (r *csvReader) readHeader() error {
+	header, err := r.reader.Read()
+	if err == io.EOF {
+		return fmt.Errorf("missing header")
+	}
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+		if i == 0 {
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+		if !isField(column) {
+			return fmt.Errorf("unknown column %q", column)
+		}
+		if seen[column] {
+			return fmt.Errorf("duplicate column %q", column)
+		}
+		seen[column] = true
+		r.columns = append(r.columns, column)
+	}
+	if !seen["sku"] {
+		return fmt.Errorf("missing sku column")
+	}
+	return nil
+}
+
+func (r *csvReader) Read() (*Row, error) {
+	if r.columns == nil {
+		if err := r.readHeader(); err != nil {
+			return nil, err
+		}
+	}
+	record, err := r.reader.Read()
+	if err != nil {
+		if _, ok := err.(*csv.ParseError); !ok {
+			return nil, err
+		}
+		// Quoting errors leave the reader at the next line, so they only
+		// spoil the row they are in.
+		return &Row{Line: err.(*csv.ParseError).StartLine, Item: &pb.InventoryItem{}, Err: err}, nil
+	}
+	line, _ := r.reader.FieldPos(0)
+	row := &Row{Line: line, Item: &pb.InventoryItem{}}
+	if len(record) != len(r.columns) {
+		for i, column := range r.columns {
+			if column == "sku" && i < len(record) {
+				row.Item.Sku = strings.TrimSpace(record[i])
+			}
+		}
+		row.Err = fmt.Errorf("expected %d cells, got %d", len(r.columns), len(record))
+		return row, nil
+	}
+	for i, column := range r.columns {
+		if strings.TrimSpace(record[i]) == "" {
+			continue
+		}
+		if err := setCell(row.Item, column, record[i]); err != nil && row.Err == nil {
+			row.Err = err
+		}
+		row.Paths = append(row.Paths, column)
+	}
+	return row, nil
+}
+
+// csvWriter writes a header with all of Fields followed by a record per
+// item.
+type csvWriter struct {
+	writer *csv.Writer
+	header bool
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{writer: csv.NewWriter(w)}
+}
+
+// writeHeader writes the header before the first record, or on its own for
+// an empty document.
+func (w *csvWriter) writeHeader() error {
+	if w.header {
+		return nil
+	}
+	w.header = true
+	return w.writer.Write(Fields)
+}
+
+func (w *csvWriter) Write(item *pb.InventoryItem) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	record := make([]string, len(Fields))
+	for i, field := range Fields {
+		if exported(item, field) {
+			record[i] = formatCell(item, field)
+		}
+	}
+	return w.writer.Write(record)
+}
+
+func (w *csvWriter) Flush() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
@@ -0,0 +1,212 @@
+// Package itemio reads and writes inventory items as CSV and as
+// newline-delimited JSON, for bulk import and export.
+//
+// Both formats carry the fields in Fields under their protobuf names. A row
+// only sets the fields it has a value for: an empty CSV cell or a missing
+// JSON key leaves the field of an existing item as it is, so that a document
+// holding just sku and price columns updates prices and nothing else.
+package itemio
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Fields are the item fields that are imported and exported, in the order
+// of the CSV columns. Stock by location, variants and options are managed
+// through their own calls and are not part of the documents.
+var Fields = []string{
+	"sku",
+	"name",
+	"description",
+	"barcode",
+	"quantity",
+	"backorderable",
+	"price",
+	"compareAtPrice",
+	"taxClass",
+	"categoryIds",
+	"reorderPoint",
+	"reorderQuantity",
+}
+
+// listSeparator separates the values of list fields in a CSV cell.
+const listSeparator = "|"
+
+// enumPrefixes are the prefixes of enum value names left out of CSV cells,
+// so that a tax class is written as REDUCED rather than TAX_CLASS_REDUCED.
+var enumPrefixes = map[string]string{
+	"taxClass": "TAX_CLASS_",
+}
+
+// Row is an item read from a document.
+type Row struct {
+	// Line is the line of the document the row starts on.
+	Line int
+	Item *pb.InventoryItem
+	// Paths are the fields the row sets.
+	Paths []string
+	// Err is set when the row could not be read. Item then holds whatever
+	// was read before the error, at least the SKU when there is one.
+	Err error
+}
+
+// Reader reads the rows of a document. Read returns io.EOF after the last
+// row. Other errors from Read mean the document as a whole is unreadable;
+// a single bad row is reported through Row.Err instead.
+type Reader interface {
+	Read() (*Row, error)
+}
+
+// Writer writes items to a document. Flush must be called after the last
+// item.
+type Writer interface {
+	Write(item *pb.InventoryItem) error
+	Flush() error
+}
+
+func NewReader(format pb.ItemFormat, r io.Reader) (Reader, error) {
+	switch format {
+	case pb.ItemFormat_FORMAT_CSV:
+		return newCSVReader(r), nil
+	case pb.ItemFormat_FORMAT_NDJSON:
+		return newJSONReader(r), nil
+	}
+	return nil, fmt.Errorf("unknown format %v", format)
+}
+
+func NewWriter(format pb.ItemFormat, w io.Writer) (Writer, error) {
+	switch format {
+	case pb.ItemFormat_FORMAT_CSV:
+		return newCSVWriter(w), nil
+	case pb.ItemFormat_FORMAT_NDJSON:
+		return newJSONWriter(w), nil
+	}
+	return nil, fmt.Errorf("unknown format %v", format)
+}
+
+// ParseFormat returns the format with the given name, csv or ndjson.
+func ParseFormat(name string) (pb.ItemFormat, error) {
+	switch strings.ToLower(name) {
+	case "csv":
+		return pb.ItemFormat_FORMAT_CSV, nil
+	case "ndjson", "jsonl":
+		return pb.ItemFormat_FORMAT_NDJSON, nil
+	}
+	return 0, fmt.Errorf("unknown format %q, expected csv or ndjson", name)
+}
+
+func isField(name string) bool {
+	for _, field := range Fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// exported reports whether field of item is written on export. The
+// quantity of an item with variants is the sum over them and cannot be
+// set, so it is left out.
+func exported(item *pb.InventoryItem, field string) bool {
+	return field != "quantity" || len(item.Variants) == 0
+}
+
+func fieldDescriptor(name string) protoreflect.FieldDescriptor {
+	return (&pb.InventoryItem{}).ProtoReflect().Descriptor().Fields().ByName(protoreflect.Name(name))
+}
+
+// setCell sets the field of item named by a CSV column from the text of
+// a cell.
+func setCell(item *pb.InventoryItem, name, text string) error {
+	message := item.ProtoReflect()
+	field := fieldDescriptor(name)
+	text = strings.TrimSpace(text)
+	if field.IsList() {
+		list := message.Mutable(field).List()
+		for _, value := range strings.Split(text, listSeparator) {
+			if value = strings.TrimSpace(value); value != "" {
+				list.Append(protoreflect.ValueOfString(value))
+			}
+		}
+		return nil
+	}
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		message.Set(field, protoreflect.ValueOfString(text))
+	case protoreflect.Int32Kind:
+		value, err := strconv.ParseInt(text, 10, 32)
+		if err != nil {
+			return fmt.Errorf("%s must be a whole number", name)
+		}
+		message.Set(field, protoreflect.ValueOfInt32(int32(value)))
+	case protoreflect.BoolKind:
+		value, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false", name)
+		}
+		message.Set(field, protoreflect.ValueOfBool(value))
+	case protoreflect.EnumKind:
+		value := field.Enum().Values().ByName(protoreflect.Name(enumPrefixes[name] + strings.ToUpper(text)))
+		if value == nil {
+			return fmt.Errorf("unknown %s %q", name, text)
+		}
+		message.Set(field, protoreflect.ValueOfEnum(value.Number()))
+	case protoreflect.MessageKind:
+		money, err := parseMoney(text)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		message.Set(field, protoreflect.ValueOfMessage(money.ProtoReflect()))
+	}
+	return nil
+}
+
+// formatCell returns the text of the CSV cell for the field of item named
+// by a column. Unset messages are written as empty cells.
+func formatCell(item *pb.InventoryItem, name string) string {
+	message := item.ProtoReflect()
+	field := fieldDescriptor(name)
+	value := message.Get(field)
+	if field.IsList() {
+		values := make([]string, value.List().Len())
+		for i := range values {
+			values[i] = value.List().Get(i).String()
+		}
+		return strings.Join(values, listSeparator)
+	}
+	switch field.Kind() {
+	case protoreflect.EnumKind:
+		valueName := string(field.Enum().Values().ByNumber(value.Enum()).Name())
+		return strings.TrimPrefix(valueName, enumPrefixes[name])
+	case protoreflect.MessageKind:
+		if !message.Has(field) {
+			return ""
+		}
+		return formatMoney(value.Message().Interface().(*pb.Money))
+	}
+	return fmt.Sprint(value.Interface())
+}
+
+// parseMoney reads an amount in the minor unit of a currency followed by
+// the currency code, such as "1250 USD" for $12.50.
+func parseMoney(text string) (*pb.Money, error) {
+	parts := strings.Fields(text)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("expected an amount in minor units and a currency, such as \"1250 USD\"")
+	}
+	amount, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("amount must be a whole number of minor units")
+	}
+	return &pb.Money{Amount: amount, Currency: strings.ToUpper(parts[1])}, nil
+}
+
+func formatMoney(money *pb.Money) string {
+	return strconv.FormatInt(money.Amount, 10) + " " + money.Currency
+}
@@ -0,0 +1,260 @@
+package itemio
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/protobuf/proto"
+)
+
+// readAll returns the rows of a document, failing the test if the document
+// as a whole is unreadable.
+func readAll(t *testing.T, format pb.ItemFormat, document string) []*Row {
+	t.Helper()
+	reader, err := NewReader(format, strings.NewReader(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []*Row
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return rows
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	items := []*pb.InventoryItem{
+		{
+			Sku:             "MUG",
+			Name:            "Mug, enamel",
+			Description:     "Holds \"a lot\"\nof tea",
+			Barcode:         "4006381333931",
+			Quantity:        12,
+			Backorderable:   true,
+			Price:           &pb.Money{Amount: 1250, Currency: "USD"},
+			CompareAtPrice:  &pb.Money{Amount: 1500, Currency: "USD"},
+			TaxClass:        pb.TaxClass_TAX_CLASS_REDUCED,
+			CategoryIds:     []string{"kitchen", "gifts"},
+			ReorderPoint:    4,
+			ReorderQuantity: 20,
+		},
+		{Sku: "CUP", Name: "Cup"},
+	}
+	for _, format := range []pb.ItemFormat{pb.ItemFormat_FORMAT_CSV, pb.ItemFormat_FORMAT_NDJSON} {
+		t.Run(format.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			writer, err := NewWriter(format, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, item := range items {
+				if err := writer.Write(item); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := writer.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			rows := readAll(t, format, buf.String())
+			if len(rows) != len(items) {
+				t.Fatalf("read %d rows, want %d", len(rows), len(items))
+			}
+			for i, row := range rows {
+				if row.Err != nil {
+					t.Errorf("row %d: %v", i, row.Err)
+				}
+				if !proto.Equal(row.Item, items[i]) {
+					t.Errorf("row %d read as %v, want %v", i, row.Item, items[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWriteLeavesOutQuantityOfItemsWithVariants(t *testing.T) {
+	item := &pb.InventoryItem{Sku: "SHIRT", Quantity: 7, Variants: []*pb.Variant{{Sku: "SHIRT-S", Quantity: 7}}}
+	for _, format := range []pb.ItemFormat{pb.ItemFormat_FORMAT_CSV, pb.ItemFormat_FORMAT_NDJSON} {
+		t.Run(format.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			writer, _ := NewWriter(format, &buf)
+			writer.Write(item)
+			writer.Flush()
+			rows := readAll(t, format, buf.String())
+			if len(rows) != 1 {
+				t.Fatalf("read %d rows, want 1", len(rows))
+			}
+			for _, path := range rows[0].Paths {
+				if path == "quantity" {
+					t.Errorf("wrote quantity %d of an item with variants", rows[0].Item.Quantity)
+				}
+			}
+		})
+	}
+}
+
+func TestCSVWritesHeaderOfEmptyDocument(t *testing.T) {
+	var buf bytes.Buffer
+	writer := newCSVWriter(&buf)
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Join(Fields, ",") + "\n"; buf.String() != want {
+		t.Errorf("wrote %q, want %q", buf.String(), want)
+	}
+}
+
+func TestCSVHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		wantErr  string
+	}{
+		{"columns in any order", "name,sku\nMug,MUG\n", ""},
+		{"byte order mark", "\ufeffsku,name\nMUG,Mug\n", ""},
+		{"empty document", "", "missing header"},
+		{"unknown column", "sku,colour\nMUG,red\n", `unknown column "colour"`},
+		{"duplicate column", "sku,name,name\nMUG,Mug,Cup\n", `duplicate column "name"`},
+		{"missing sku column", "name\nMug\n", "missing sku column"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := newCSVReader(strings.NewReader(test.document)).Read()
+			if got := errorString(err); got != test.wantErr {
+				t.Errorf("got error %q, want %q", got, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestCSVRows(t *testing.T) {
+	tests := []struct {
+		name      string
+		record    string
+		wantItem  *pb.InventoryItem
+		wantPaths []string
+		wantErr   string
+	}{
+		{"empty cells are left out", "MUG,,5,,", &pb.InventoryItem{Sku: "MUG", Quantity: 5}, []string{"sku", "quantity"}, ""},
+		{"list cell", "MUG,,,,kitchen | gifts|", &pb.InventoryItem{Sku: "MUG", CategoryIds: []string{"kitchen", "gifts"}}, []string{"sku", "categoryIds"}, ""},
+		{"lower case money and tax class", "MUG,99 eur,,zero,", &pb.InventoryItem{Sku: "MUG", Price: &pb.Money{Amount: 99, Currency: "EUR"}, TaxClass: pb.TaxClass_TAX_CLASS_ZERO}, []string{"sku", "price", "taxClass"}, ""},
+		{"bad number", "MUG,,many,,", &pb.InventoryItem{Sku: "MUG"}, []string{"sku", "quantity"}, "quantity must be a whole number"},
+		{"money without currency", "MUG,1250,,,", &pb.InventoryItem{Sku: "MUG"}, []string{"sku", "price"}, `price: expected an amount in minor units and a currency, such as "1250 USD"`},
+		{"fractional money", "MUG,12.50 USD,,,", &pb.InventoryItem{Sku: "MUG"}, []string{"sku", "price"}, "price: amount must be a whole number of minor units"},
+		{"unknown tax class", "MUG,,,LUXURY,", &pb.InventoryItem{Sku: "MUG"}, []string{"sku", "taxClass"}, `unknown taxClass "LUXURY"`},
+		{"wrong number of cells", "MUG,1250 USD", &pb.InventoryItem{Sku: "MUG"}, nil, "expected 5 cells, got 2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows := readAll(t, pb.ItemFormat_FORMAT_CSV, "sku,price,quantity,taxClass,categoryIds\n"+test.record+"\n")
+			if len(rows) != 1 {
+				t.Fatalf("read %d rows, want 1", len(rows))
+			}
+			row := rows[0]
+			if row.Line != 2 {
+				t.Errorf("row on line %d, want 2", row.Line)
+			}
+			if got := errorString(row.Err); got != test.wantErr {
+				t.Errorf("got error %q, want %q", got, test.wantErr)
+			}
+			if !proto.Equal(row.Item, test.wantItem) {
+				t.Errorf("read item %v, want %v", row.Item, test.wantItem)
+			}
+			if !reflect.DeepEqual(row.Paths, test.wantPaths) {
+				t.Errorf("read paths %v, want %v", row.Paths, test.wantPaths)
+			}
+		})
+	}
+}
+
+func TestCSVQuotingErrorSpoilsOneRow(t *testing.T) {
+	rows := readAll(t, pb.ItemFormat_FORMAT_CSV, "sku,name\nMUG,\"Mug\"x\nCUP,Cup\n")
+	if len(rows) != 2 {
+		t.Fatalf("read %d rows, want 2", len(rows))
+	}
+	if rows[0].Err == nil {
+		t.Error("read a badly quoted row without an error")
+	}
+	if rows[1].Err != nil || rows[1].Item.Sku != "CUP" {
+		t.Errorf("read the next row as %v with error %v", rows[1].Item, rows[1].Err)
+	}
+}
+
+func TestJSONRows(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		wantItem  *pb.InventoryItem
+		wantPaths []string
+		wantErr   string
+	}{
+		{"missing keys are left out", `{"sku":"MUG","quantity":5}`, &pb.InventoryItem{Sku: "MUG", Quantity: 5}, []string{"sku", "quantity"}, ""},
+		{"money", `{"sku":"MUG","price":{"amount":"1250","currency":"USD"}}`, &pb.InventoryItem{Sku: "MUG", Price: &pb.Money{Amount: 1250, Currency: "USD"}}, []string{"sku", "price"}, ""},
+		{"unknown field", `{"sku":"MUG","reserved":3,"colour":"red"}`, &pb.InventoryItem{Sku: "MUG"}, []string{"sku"}, `unknown field "colour"`},
+		{"invalid JSON", `{"sku":`, &pb.InventoryItem{}, nil, "invalid JSON: unexpected end of JSON input"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows := readAll(t, pb.ItemFormat_FORMAT_NDJSON, "\n"+test.line+"\n\n")
+			if len(rows) != 1 {
+				t.Fatalf("read %d rows, want 1", len(rows))
+			}
+			row := rows[0]
+			if row.Line != 2 {
+				t.Errorf("row on line %d, want 2", row.Line)
+			}
+			if got := errorString(row.Err); got != test.wantErr {
+				t.Errorf("got error %q, want %q", got, test.wantErr)
+			}
+			if !proto.Equal(row.Item, test.wantItem) {
+				t.Errorf("read item %v, want %v", row.Item, test.wantItem)
+			}
+			if !reflect.DeepEqual(row.Paths, test.wantPaths) {
+				t.Errorf("read paths %v, want %v", row.Paths, test.wantPaths)
+			}
+		})
+	}
+}
+
+func TestJSONBadValueKeepsSku(t *testing.T) {
+	rows := readAll(t, pb.ItemFormat_FORMAT_NDJSON, `{"sku":"MUG","quantity":"many"}`)
+	if len(rows) != 1 || rows[0].Err == nil || rows[0].Item.Sku != "MUG" {
+		t.Errorf("read %v, want a row for MUG with an error", rows)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    pb.ItemFormat
+		wantErr bool
+	}{
+		{"csv", pb.ItemFormat_FORMAT_CSV, false},
+		{"CSV", pb.ItemFormat_FORMAT_CSV, false},
+		{"ndjson", pb.ItemFormat_FORMAT_NDJSON, false},
+		{"jsonl", pb.ItemFormat_FORMAT_NDJSON, false},
+		{"xml", 0, true},
+	}
+	for _, test := range tests {
+		format, err := ParseFormat(test.name)
+		if format != test.want || (err != nil) != test.wantErr {
+			t.Errorf("ParseFormat(%q) = %v, %v", test.name, format, err)
+		}
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,109 @@
+package itemio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// jsonReader reads a JSON object per line in the protobuf JSON mapping of
+// an item, limited to Fields. Blank lines are skipped.
+type jsonReader struct {
+	reader *bufio.Reader
+	line   int
+}
+
+func newJSONReader(r io.Reader) *jsonReader {
+	return &jsonReader{reader: bufio.NewReader(r)}
+}
+
+func (r *jsonReader) Read() (*Row, error) {
+	for {
+		data, err := r.reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(data) > 0 {
+			r.line++
+		}
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			return r.parse(data), nil
+		}
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+	}
+}
+
+func (r *jsonReader) parse(data []byte) *Row {
+	row := &Row{Line: r.line, Item: &pb.InventoryItem{}}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		row.Err = fmt.Errorf("invalid JSON: %v", err)
+		return row
+	}
+	// The SKU is read on its own first so that it can be reported along
+	// with any error in the rest of the row.
+	json.Unmarshal(object["sku"], &row.Item.Sku)
+	for _, field := range Fields {
+		if _, ok := object[field]; ok {
+			row.Paths = append(row.Paths, field)
+		}
+	}
+	if len(row.Paths) < len(object) {
+		var unknown []string
+		for key := range object {
+			if !isField(key) {
+				unknown = append(unknown, key)
+			}
+		}
+		sort.Strings(unknown)
+		row.Err = fmt.Errorf("unknown field %q", unknown[0])
+		return row
+	}
+	if err := protojson.Unmarshal(data, row.Item); err != nil {
+		row.Err = err
+	}
+	return row
+}
+
+// jsonWriter writes an item per line with the fields in Fields, including
+// those that are unset, so that a document read back sets every field.
+type jsonWriter struct {
+	writer *bufio.Writer
+}
+
+func newJSONWriter(w io.Writer) *jsonWriter {
+	return &jsonWriter{writer: bufio.NewWriter(w)}
+}
+
+func (w *jsonWriter) Write(item *pb.InventoryItem) error {
+	data, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(item)
+	if err != nil {
+		return err
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	for key := range object {
+		if !isField(key) || !exported(item, key) {
+			delete(object, key)
+		}
+	}
+	if data, err = json.Marshal(object); err != nil {
+		return err
+	}
+	w.writer.Write(data)
+	return w.writer.WriteByte('\n')
+}
+
+func (w *jsonWriter) Flush() error {
+	return w.writer.Flush()
+}
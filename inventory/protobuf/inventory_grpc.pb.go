@@ -22,13 +22,106 @@ const _ = grpc.SupportPackageIsVersion7
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type InventoryServiceClient interface {
-	GetInventory(ctx context.Context, in *Empty, opts ...grpc.CallOption) (InventoryService_GetInventoryClient, error)
+	// GetInventory and FindItems stream one page of items, ordered by id unless
+	// FindItems asks otherwise. The token for the next page and the total
+	// number of matching items are sent in the "next-page-token" and
+	// "total-count" trailers.
+	GetInventory(ctx context.Context, in *GetInventoryRequest, opts ...grpc.CallOption) (InventoryService_GetInventoryClient, error)
 	GetItem(ctx context.Context, in *GetItemRequest, opts ...grpc.CallOption) (*GetItemResponse, error)
+	// GetItemBySku finds an item by its sku, or else by the sku of one of its
+	// variants, which the response names.
+	GetItemBySku(ctx context.Context, in *GetItemBySkuRequest, opts ...grpc.CallOption) (*GetItemResponse, error)
+	GetItemByBarcode(ctx context.Context, in *GetItemByBarcodeRequest, opts ...grpc.CallOption) (*GetItemResponse, error)
 	FindItems(ctx context.Context, in *FindItemsRequest, opts ...grpc.CallOption) (InventoryService_FindItemsClient, error)
 	InsertItem(ctx context.Context, in *InsertItemRequest, opts ...grpc.CallOption) (*InsertItemResponse, error)
 	UpdateItem(ctx context.Context, in *UpdateItemRequest, opts ...grpc.CallOption) (*UpdateItemResponse, error)
+	// DeleteItem archives an item. Archived items are left out of listings
+	// unless asked for, and can be restored until they are purged.
 	DeleteItem(ctx context.Context, in *DeleteItemRequest, opts ...grpc.CallOption) (*DeleteItemResponse, error)
+	// RestoreItem brings an archived item back.
+	RestoreItem(ctx context.Context, in *RestoreItemRequest, opts ...grpc.CallOption) (*RestoreItemResponse, error)
+	// PurgeItem permanently removes an archived item. Its entries in the
+	// stock ledger are kept.
+	PurgeItem(ctx context.Context, in *PurgeItemRequest, opts ...grpc.CallOption) (*PurgeItemResponse, error)
 	IncrementItemQuantity(ctx context.Context, in *IncrementItemQuantityRequest, opts ...grpc.CallOption) (*IncrementItemQuantityResponse, error)
+	ReserveStock(ctx context.Context, in *ReserveStockRequest, opts ...grpc.CallOption) (*ReserveStockResponse, error)
+	CommitReservation(ctx context.Context, in *CommitReservationRequest, opts ...grpc.CallOption) (*CommitReservationResponse, error)
+	ReleaseReservation(ctx context.Context, in *ReleaseReservationRequest, opts ...grpc.CallOption) (*ReleaseReservationResponse, error)
+	// SearchItems ranks items with an index each instance keeps in memory. The
+	// index follows the changes of the store, so the writes of other instances
+	// show up shortly after they are made.
+	SearchItems(ctx context.Context, in *SearchItemsRequest, opts ...grpc.CallOption) (InventoryService_SearchItemsClient, error)
+	ListCategories(ctx context.Context, in *ListCategoriesRequest, opts ...grpc.CallOption) (InventoryService_ListCategoriesClient, error)
+	GetCategory(ctx context.Context, in *GetCategoryRequest, opts ...grpc.CallOption) (*GetCategoryResponse, error)
+	CreateCategory(ctx context.Context, in *CreateCategoryRequest, opts ...grpc.CallOption) (*CreateCategoryResponse, error)
+	RenameCategory(ctx context.Context, in *RenameCategoryRequest, opts ...grpc.CallOption) (*RenameCategoryResponse, error)
+	MoveCategory(ctx context.Context, in *MoveCategoryRequest, opts ...grpc.CallOption) (*MoveCategoryResponse, error)
+	DeleteCategory(ctx context.Context, in *DeleteCategoryRequest, opts ...grpc.CallOption) (*DeleteCategoryResponse, error)
+	AddVariant(ctx context.Context, in *AddVariantRequest, opts ...grpc.CallOption) (*AddVariantResponse, error)
+	UpdateVariant(ctx context.Context, in *UpdateVariantRequest, opts ...grpc.CallOption) (*UpdateVariantResponse, error)
+	RemoveVariant(ctx context.Context, in *RemoveVariantRequest, opts ...grpc.CallOption) (*RemoveVariantResponse, error)
+	ListLocations(ctx context.Context, in *ListLocationsRequest, opts ...grpc.CallOption) (InventoryService_ListLocationsClient, error)
+	GetLocation(ctx context.Context, in *GetLocationRequest, opts ...grpc.CallOption) (*GetLocationResponse, error)
+	CreateLocation(ctx context.Context, in *CreateLocationRequest, opts ...grpc.CallOption) (*CreateLocationResponse, error)
+	RenameLocation(ctx context.Context, in *RenameLocationRequest, opts ...grpc.CallOption) (*RenameLocationResponse, error)
+	DeleteLocation(ctx context.Context, in *DeleteLocationRequest, opts ...grpc.CallOption) (*DeleteLocationResponse, error)
+	TransferStock(ctx context.Context, in *TransferStockRequest, opts ...grpc.CallOption) (*TransferStockResponse, error)
+	// ListStockMovements streams the stock ledger of an item, oldest first.
+	ListStockMovements(ctx context.Context, in *ListStockMovementsRequest, opts ...grpc.CallOption) (InventoryService_ListStockMovementsClient, error)
+	// ListLowStockItems streams the items at or below their reorder point.
+	ListLowStockItems(ctx context.Context, in *ListLowStockItemsRequest, opts ...grpc.CallOption) (InventoryService_ListLowStockItemsClient, error)
+	// WatchInventory streams changes to items as they happen until the
+	// client cancels. Every change carries a resume token; a client that
+	// reconnects with the token of the last change it saw gets every change
+	// after it. Tokens of changes too old to replay fail with OUT_OF_RANGE.
+	WatchInventory(ctx context.Context, in *WatchInventoryRequest, opts ...grpc.CallOption) (InventoryService_WatchInventoryClient, error)
+	// ImportItems creates or updates items from a CSV or newline-delimited
+	// JSON document sent in chunks. Items are matched by SKU: rows with a
+	// known SKU update the fields they set, other rows create an item. Rows
+	// that fail are reported and do not stop the import.
+	ImportItems(ctx context.Context, opts ...grpc.CallOption) (InventoryService_ImportItemsClient, error)
+	// ExportItems streams items as a CSV or newline-delimited JSON document
+	// in chunks, in the form ImportItems reads.
+	ExportItems(ctx context.Context, in *ExportItemsRequest, opts ...grpc.CallOption) (InventoryService_ExportItemsClient, error)
+	// BatchGetItems returns the items with the given ids in that order. It
+	// fails with NOT_FOUND when any of them does not exist.
+	BatchGetItems(ctx context.Context, in *BatchGetItemsRequest, opts ...grpc.CallOption) (*BatchGetItemsResponse, error)
+	// BatchUpdateItems and BatchAdjustQuantities apply their requests in
+	// order, each seeing the changes of the ones before it. Either all of
+	// them take effect or, when one fails, none does. The MongoDB backend
+	// needs to run as a replica set for these.
+	BatchUpdateItems(ctx context.Context, in *BatchUpdateItemsRequest, opts ...grpc.CallOption) (*BatchUpdateItemsResponse, error)
+	BatchAdjustQuantities(ctx context.Context, in *BatchAdjustQuantitiesRequest, opts ...grpc.CallOption) (*BatchAdjustQuantitiesResponse, error)
+	// UploadItemImage adds an image after the images of an item. The item id,
+	// alt text and expected version are read from the first message, and the
+	// image is the data of all messages together. PNG, JPEG, GIF and WebP
+	// images of up to 10 MiB are accepted.
+	UploadItemImage(ctx context.Context, opts ...grpc.CallOption) (InventoryService_UploadItemImageClient, error)
+	// SetItemImages reorders the images of an item, changes their alt text
+	// and removes those left out. Only images the item has may be given.
+	SetItemImages(ctx context.Context, in *SetItemImagesRequest, opts ...grpc.CallOption) (*SetItemImagesResponse, error)
+	// DownloadItemImage streams an image in chunks. The first chunk carries
+	// its content type.
+	DownloadItemImage(ctx context.Context, in *DownloadItemImageRequest, opts ...grpc.CallOption) (InventoryService_DownloadItemImageClient, error)
+	// SchedulePrice schedules a price for an item from effectiveFrom until
+	// effectiveTo, or until it is cancelled when effectiveTo is unset. The
+	// windows of the scheduled and active prices of an item must not
+	// overlap.
+	SchedulePrice(ctx context.Context, in *SchedulePriceRequest, opts ...grpc.CallOption) (*SchedulePriceResponse, error)
+	// CancelScheduledPrice cancels a scheduled price, ending it at once if it
+	// is active.
+	CancelScheduledPrice(ctx context.Context, in *CancelScheduledPriceRequest, opts ...grpc.CallOption) (*CancelScheduledPriceResponse, error)
+	// GetCurrentPrice resolves the price of an item at the time of the
+	// request: the scheduled price whose window contains it, or else the
+	// item's own price.
+	GetCurrentPrice(ctx context.Context, in *GetCurrentPriceRequest, opts ...grpc.CallOption) (*GetCurrentPriceResponse, error)
+	// GetPriceHistory lists the scheduled prices of an item, past, present
+	// and future, in the order they take effect.
+	GetPriceHistory(ctx context.Context, in *GetPriceHistoryRequest, opts ...grpc.CallOption) (*GetPriceHistoryResponse, error)
+	// QueryAuditLog lists the changes made through the inventory and
+	// authentication services, newest first. It is open to the users named
+	// in ADMIN_USERS only.
+	QueryAuditLog(ctx context.Context, in *QueryAuditLogRequest, opts ...grpc.CallOption) (*QueryAuditLogResponse, error)
 }
 
 type inventoryServiceClient struct {
@@ -39,7 +132,7 @@ func NewInventoryServiceClient(cc grpc.ClientConnInterface) InventoryServiceClie
 	return &inventoryServiceClient{cc}
 }
 
-func (c *inventoryServiceClient) GetInventory(ctx context.Context, in *Empty, opts ...grpc.CallOption) (InventoryService_GetInventoryClient, error) {
+func (c *inventoryServiceClient) GetInventory(ctx context.Context, in *GetInventoryRequest, opts ...grpc.CallOption) (InventoryService_GetInventoryClient, error) {
 	stream, err := c.cc.NewStream(ctx, &InventoryService_ServiceDesc.Streams[0], "/protobuf.InventoryService/GetInventory", opts...)
 	if err != nil {
 		return nil, err
@@ -80,6 +173,24 @@ func (c *inventoryServiceClient) GetItem(ctx context.Context, in *GetItemRequest
 	return out, nil
 }
 
+func (c *inventoryServiceClient) GetItemBySku(ctx context.Context, in *GetItemBySkuRequest, opts ...grpc.CallOption) (*GetItemResponse, error) {
+	out := new(GetItemResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/GetItemBySku", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) GetItemByBarcode(ctx context.Context, in *GetItemByBarcodeRequest, opts ...grpc.CallOption) (*GetItemResponse, error) {
+	out := new(GetItemResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/GetItemByBarcode", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *inventoryServiceClient) FindItems(ctx context.Context, in *FindItemsRequest, opts ...grpc.CallOption) (InventoryService_FindItemsClient, error) {
 	stream, err := c.cc.NewStream(ctx, &InventoryService_ServiceDesc.Streams[1], "/protobuf.InventoryService/FindItems", opts...)
 	if err != nil {
@@ -118,216 +229,1735 @@ func (c *inventoryServiceClient) InsertItem(ctx context.Context, in *InsertItemR
 	if err != nil {
 		return nil, err
 	}
-	return out, nil
+	return out, nil
+}
+
+func (c *inventoryServiceClient) UpdateItem(ctx context.Context, in *UpdateItemRequest, opts ...grpc.CallOption) (*UpdateItemResponse, error) {
+	out := new(UpdateItemResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/UpdateItem", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) DeleteItem(ctx context.Context, in *DeleteItemRequest, opts ...grpc.CallOption) (*DeleteItemResponse, error) {
+	out := new(DeleteItemResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/DeleteItem", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) RestoreItem(ctx context.Context, in *RestoreItemRequest, opts ...grpc.CallOption) (*RestoreItemResponse, error) {
+	out := new(RestoreItemResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/RestoreItem", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) PurgeItem(ctx context.Context, in *PurgeItemRequest, opts ...grpc.CallOption) (*PurgeItemResponse, error) {
+	out := new(PurgeItemResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/PurgeItem", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) IncrementItemQuantity(ctx context.Context, in *IncrementItemQuantityRequest, opts ...grpc.CallOption) (*IncrementItemQuantityResponse, error) {
+	out := new(IncrementItemQuantityResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/IncrementItemQuantity", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) ReserveStock(ctx context.Context, in *ReserveStockRequest, opts ...grpc.CallOption) (*ReserveStockResponse, error) {
+	out := new(ReserveStockResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/ReserveStock", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) CommitReservation(ctx context.Context, in *CommitReservationRequest, opts ...grpc.CallOption) (*CommitReservationResponse, error) {
+	out := new(CommitReservationResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/CommitReservation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) ReleaseReservation(ctx context.Context, in *ReleaseReservationRequest, opts ...grpc.CallOption) (*ReleaseReservationResponse, error) {
+	out := new(ReleaseReservationResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/ReleaseReservation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) SearchItems(ctx context.Context, in *SearchItemsRequest, opts ...grpc.CallOption) (InventoryService_SearchItemsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &InventoryService_ServiceDesc.Streams[2], "/protobuf.InventoryService/SearchItems", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &inventoryServiceSearchItemsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type InventoryService_SearchItemsClient interface {
+	Recv() (*SearchResult, error)
+	grpc.ClientStream
+}
+
+type inventoryServiceSearchItemsClient struct {
+	grpc.ClientStream
+}
+
+func (x *inventoryServiceSearchItemsClient) Recv() (*SearchResult, error) {
+	m := new(SearchResult)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *inventoryServiceClient) ListCategories(ctx context.Context, in *ListCategoriesRequest, opts ...grpc.CallOption) (InventoryService_ListCategoriesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &InventoryService_ServiceDesc.Streams[3], "/protobuf.InventoryService/ListCategories", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &inventoryServiceListCategoriesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type InventoryService_ListCategoriesClient interface {
+	Recv() (*Category, error)
+	grpc.ClientStream
+}
+
+type inventoryServiceListCategoriesClient struct {
+	grpc.ClientStream
+}
+
+func (x *inventoryServiceListCategoriesClient) Recv() (*Category, error) {
+	m := new(Category)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *inventoryServiceClient) GetCategory(ctx context.Context, in *GetCategoryRequest, opts ...grpc.CallOption) (*GetCategoryResponse, error) {
+	out := new(GetCategoryResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/GetCategory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) CreateCategory(ctx context.Context, in *CreateCategoryRequest, opts ...grpc.CallOption) (*CreateCategoryResponse, error) {
+	out := new(CreateCategoryResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/CreateCategory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) RenameCategory(ctx context.Context, in *RenameCategoryRequest, opts ...grpc.CallOption) (*RenameCategoryResponse, error) {
+	out := new(RenameCategoryResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/RenameCategory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) MoveCategory(ctx context.Context, in *MoveCategoryRequest, opts ...grpc.CallOption) (*MoveCategoryResponse, error) {
+	out := new(MoveCategoryResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/MoveCategory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) DeleteCategory(ctx context.Context, in *DeleteCategoryRequest, opts ...grpc.CallOption) (*DeleteCategoryResponse, error) {
+	out := new(DeleteCategoryResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/DeleteCategory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) AddVariant(ctx context.Context, in *AddVariantRequest, opts ...grpc.CallOption) (*AddVariantResponse, error) {
+	out := new(AddVariantResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/AddVariant", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) UpdateVariant(ctx context.Context, in *UpdateVariantRequest, opts ...grpc.CallOption) (*UpdateVariantResponse, error) {
+	out := new(UpdateVariantResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/UpdateVariant", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) RemoveVariant(ctx context.Context, in *RemoveVariantRequest, opts ...grpc.CallOption) (*RemoveVariantResponse, error) {
+	out := new(RemoveVariantResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/RemoveVariant", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) ListLocations(ctx context.Context, in *ListLocationsRequest, opts ...grpc.CallOption) (InventoryService_ListLocationsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &InventoryService_ServiceDesc.Streams[4], "/protobuf.InventoryService/ListLocations", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &inventoryServiceListLocationsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type InventoryService_ListLocationsClient interface {
+	Recv() (*Location, error)
+	grpc.ClientStream
+}
+
+type inventoryServiceListLocationsClient struct {
+	grpc.ClientStream
+}
+
+func (x *inventoryServiceListLocationsClient) Recv() (*Location, error) {
+	m := new(Location)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *inventoryServiceClient) GetLocation(ctx context.Context, in *GetLocationRequest, opts ...grpc.CallOption) (*GetLocationResponse, error) {
+	out := new(GetLocationResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/GetLocation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) CreateLocation(ctx context.Context, in *CreateLocationRequest, opts ...grpc.CallOption) (*CreateLocationResponse, error) {
+	out := new(CreateLocationResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/CreateLocation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) RenameLocation(ctx context.Context, in *RenameLocationRequest, opts ...grpc.CallOption) (*RenameLocationResponse, error) {
+	out := new(RenameLocationResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/RenameLocation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) DeleteLocation(ctx context.Context, in *DeleteLocationRequest, opts ...grpc.CallOption) (*DeleteLocationResponse, error) {
+	out := new(DeleteLocationResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/DeleteLocation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) TransferStock(ctx context.Context, in *TransferStockRequest, opts ...grpc.CallOption) (*TransferStockResponse, error) {
+	out := new(TransferStockResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/TransferStock", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) ListStockMovements(ctx context.Context, in *ListStockMovementsRequest, opts ...grpc.CallOption) (InventoryService_ListStockMovementsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &InventoryService_ServiceDesc.Streams[5], "/protobuf.InventoryService/ListStockMovements", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &inventoryServiceListStockMovementsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type InventoryService_ListStockMovementsClient interface {
+	Recv() (*StockMovement, error)
+	grpc.ClientStream
+}
+
+type inventoryServiceListStockMovementsClient struct {
+	grpc.ClientStream
+}
+
+func (x *inventoryServiceListStockMovementsClient) Recv() (*StockMovement, error) {
+	m := new(StockMovement)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *inventoryServiceClient) ListLowStockItems(ctx context.Context, in *ListLowStockItemsRequest, opts ...grpc.CallOption) (InventoryService_ListLowStockItemsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &InventoryService_ServiceDesc.Streams[6], "/protobuf.InventoryService/ListLowStockItems", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &inventoryServiceListLowStockItemsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type InventoryService_ListLowStockItemsClient interface {
+	Recv() (*InventoryItem, error)
+	grpc.ClientStream
+}
+
+type inventoryServiceListLowStockItemsClient struct {
+	grpc.ClientStream
+}
+
+func (x *inventoryServiceListLowStockItemsClient) Recv() (*InventoryItem, error) {
+	m := new(InventoryItem)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *inventoryServiceClient) WatchInventory(ctx context.Context, in *WatchInventoryRequest, opts ...grpc.CallOption) (InventoryService_WatchInventoryClient, error) {
+	stream, err := c.cc.NewStream(ctx, &InventoryService_ServiceDesc.Streams[7], "/protobuf.InventoryService/WatchInventory", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &inventoryServiceWatchInventoryClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type InventoryService_WatchInventoryClient interface {
+	Recv() (*ItemChange, error)
+	grpc.ClientStream
+}
+
+type inventoryServiceWatchInventoryClient struct {
+	grpc.ClientStream
+}
+
+func (x *inventoryServiceWatchInventoryClient) Recv() (*ItemChange, error) {
+	m := new(ItemChange)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *inventoryServiceClient) ImportItems(ctx context.Context, opts ...grpc.CallOption) (InventoryService_ImportItemsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &InventoryService_ServiceDesc.Streams[8], "/protobuf.InventoryService/ImportItems", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &inventoryServiceImportItemsClient{stream}
+	return x, nil
+}
+
+type InventoryService_ImportItemsClient interface {
+	Send(*ImportItemsRequest) error
+	CloseAndRecv() (*ImportItemsResponse, error)
+	grpc.ClientStream
+}
+
+type inventoryServiceImportItemsClient struct {
+	grpc.ClientStream
+}
+
+func (x *inventoryServiceImportItemsClient) Send(m *ImportItemsRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *inventoryServiceImportItemsClient) CloseAndRecv() (*ImportItemsResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ImportItemsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *inventoryServiceClient) ExportItems(ctx context.Context, in *ExportItemsRequest, opts ...grpc.CallOption) (InventoryService_ExportItemsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &InventoryService_ServiceDesc.Streams[9], "/protobuf.InventoryService/ExportItems", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &inventoryServiceExportItemsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type InventoryService_ExportItemsClient interface {
+	Recv() (*ExportItemsChunk, error)
+	grpc.ClientStream
+}
+
+type inventoryServiceExportItemsClient struct {
+	grpc.ClientStream
+}
+
+func (x *inventoryServiceExportItemsClient) Recv() (*ExportItemsChunk, error) {
+	m := new(ExportItemsChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *inventoryServiceClient) BatchGetItems(ctx context.Context, in *BatchGetItemsRequest, opts ...grpc.CallOption) (*BatchGetItemsResponse, error) {
+	out := new(BatchGetItemsResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/BatchGetItems", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) BatchUpdateItems(ctx context.Context, in *BatchUpdateItemsRequest, opts ...grpc.CallOption) (*BatchUpdateItemsResponse, error) {
+	out := new(BatchUpdateItemsResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/BatchUpdateItems", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) BatchAdjustQuantities(ctx context.Context, in *BatchAdjustQuantitiesRequest, opts ...grpc.CallOption) (*BatchAdjustQuantitiesResponse, error) {
+	out := new(BatchAdjustQuantitiesResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/BatchAdjustQuantities", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) UploadItemImage(ctx context.Context, opts ...grpc.CallOption) (InventoryService_UploadItemImageClient, error) {
+	stream, err := c.cc.NewStream(ctx, &InventoryService_ServiceDesc.Streams[10], "/protobuf.InventoryService/UploadItemImage", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &inventoryServiceUploadItemImageClient{stream}
+	return x, nil
+}
+
+type InventoryService_UploadItemImageClient interface {
+	Send(*UploadItemImageRequest) error
+	CloseAndRecv() (*UploadItemImageResponse, error)
+	grpc.ClientStream
+}
+
+type inventoryServiceUploadItemImageClient struct {
+	grpc.ClientStream
+}
+
+func (x *inventoryServiceUploadItemImageClient) Send(m *UploadItemImageRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *inventoryServiceUploadItemImageClient) CloseAndRecv() (*UploadItemImageResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(UploadItemImageResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *inventoryServiceClient) SetItemImages(ctx context.Context, in *SetItemImagesRequest, opts ...grpc.CallOption) (*SetItemImagesResponse, error) {
+	out := new(SetItemImagesResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/SetItemImages", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) DownloadItemImage(ctx context.Context, in *DownloadItemImageRequest, opts ...grpc.CallOption) (InventoryService_DownloadItemImageClient, error) {
+	stream, err := c.cc.NewStream(ctx, &InventoryService_ServiceDesc.Streams[11], "/protobuf.InventoryService/DownloadItemImage", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &inventoryServiceDownloadItemImageClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type InventoryService_DownloadItemImageClient interface {
+	Recv() (*ItemImageChunk, error)
+	grpc.ClientStream
+}
+
+type inventoryServiceDownloadItemImageClient struct {
+	grpc.ClientStream
+}
+
+func (x *inventoryServiceDownloadItemImageClient) Recv() (*ItemImageChunk, error) {
+	m := new(ItemImageChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *inventoryServiceClient) SchedulePrice(ctx context.Context, in *SchedulePriceRequest, opts ...grpc.CallOption) (*SchedulePriceResponse, error) {
+	out := new(SchedulePriceResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/SchedulePrice", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) CancelScheduledPrice(ctx context.Context, in *CancelScheduledPriceRequest, opts ...grpc.CallOption) (*CancelScheduledPriceResponse, error) {
+	out := new(CancelScheduledPriceResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/CancelScheduledPrice", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) GetCurrentPrice(ctx context.Context, in *GetCurrentPriceRequest, opts ...grpc.CallOption) (*GetCurrentPriceResponse, error) {
+	out := new(GetCurrentPriceResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/GetCurrentPrice", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) GetPriceHistory(ctx context.Context, in *GetPriceHistoryRequest, opts ...grpc.CallOption) (*GetPriceHistoryResponse, error) {
+	out := new(GetPriceHistoryResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/GetPriceHistory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventoryServiceClient) QueryAuditLog(ctx context.Context, in *QueryAuditLogRequest, opts ...grpc.CallOption) (*QueryAuditLogResponse, error) {
+	out := new(QueryAuditLogResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/QueryAuditLog", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// InventoryServiceServer is the server API for InventoryService service.
+// All implementations must embed UnimplementedInventoryServiceServer
+// for forward compatibility
+type InventoryServiceServer interface {
+	// GetInventory and FindItems stream one page of items, ordered by id unless
+	// FindItems asks otherwise. The token for the next page and the total
+	// number of matching items are sent in the "next-page-token" and
+	// "total-count" trailers.
+	GetInventory(*GetInventoryRequest, InventoryService_GetInventoryServer) error
+	GetItem(context.Context, *GetItemRequest) (*GetItemResponse, error)
+	// GetItemBySku finds an item by its sku, or else by the sku of one of its
+	// variants, which the response names.
+	GetItemBySku(context.Context, *GetItemBySkuRequest) (*GetItemResponse, error)
+	GetItemByBarcode(context.Context, *GetItemByBarcodeRequest) (*GetItemResponse, error)
+	FindItems(*FindItemsRequest, InventoryService_FindItemsServer) error
+	InsertItem(context.Context, *InsertItemRequest) (*InsertItemResponse, error)
+	UpdateItem(context.Context, *UpdateItemRequest) (*UpdateItemResponse, error)
+	// DeleteItem archives an item. Archived items are left out of listings
+	// unless asked for, and can be restored until they are purged.
+	DeleteItem(context.Context, *DeleteItemRequest) (*DeleteItemResponse, error)
+	// RestoreItem brings an archived item back.
+	RestoreItem(context.Context, *RestoreItemRequest) (*RestoreItemResponse, error)
+	// PurgeItem permanently removes an archived item. Its entries in the
+	// stock ledger are kept.
+	PurgeItem(context.Context, *PurgeItemRequest) (*PurgeItemResponse, error)
+	IncrementItemQuantity(context.Context, *IncrementItemQuantityRequest) (*IncrementItemQuantityResponse, error)
+	ReserveStock(context.Context, *ReserveStockRequest) (*ReserveStockResponse, error)
+	CommitReservation(context.Context, *CommitReservationRequest) (*CommitReservationResponse, error)
+	ReleaseReservation(context.Context, *ReleaseReservationRequest) (*ReleaseReservationResponse, error)
+	// SearchItems ranks items with an index each instance keeps in memory. The
+	// index follows the changes of the store, so the writes of other instances
+	// show up shortly after they are made.
+	SearchItems(*SearchItemsRequest, InventoryService_SearchItemsServer) error
+	ListCategories(*ListCategoriesRequest, InventoryService_ListCategoriesServer) error
+	GetCategory(context.Context, *GetCategoryRequest) (*GetCategoryResponse, error)
+	CreateCategory(context.Context, *CreateCategoryRequest) (*CreateCategoryResponse, error)
+	RenameCategory(context.Context, *RenameCategoryRequest) (*RenameCategoryResponse, error)
+	MoveCategory(context.Context, *MoveCategoryRequest) (*MoveCategoryResponse, error)
+	DeleteCategory(context.Context, *DeleteCategoryRequest) (*DeleteCategoryResponse, error)
+	AddVariant(context.Context, *AddVariantRequest) (*AddVariantResponse, error)
+	UpdateVariant(context.Context, *UpdateVariantRequest) (*UpdateVariantResponse, error)
+	RemoveVariant(context.Context, *RemoveVariantRequest) (*RemoveVariantResponse, error)
+	ListLocations(*ListLocationsRequest, InventoryService_ListLocationsServer) error
+	GetLocation(context.Context, *GetLocationRequest) (*GetLocationResponse, error)
+	CreateLocation(context.Context, *CreateLocationRequest) (*CreateLocationResponse, error)
+	RenameLocation(context.Context, *RenameLocationRequest) (*RenameLocationResponse, error)
+	DeleteLocation(context.Context, *DeleteLocationRequest) (*DeleteLocationResponse, error)
+	TransferStock(context.Context, *TransferStockRequest) (*TransferStockResponse, error)
+	// ListStockMovements streams the stock ledger of an item, oldest first.
+	ListStockMovements(*ListStockMovementsRequest, InventoryService_ListStockMovementsServer) error
+	// ListLowStockItems streams the items at or below their reorder point.
+	ListLowStockItems(*ListLowStockItemsRequest, InventoryService_ListLowStockItemsServer) error
+	// WatchInventory streams changes to items as they happen until the
+	// client cancels. Every change carries a resume token; a client that
+	// reconnects with the token of the last change it saw gets every change
+	// after it. Tokens of changes too old to replay fail with OUT_OF_RANGE.
+	WatchInventory(*WatchInventoryRequest, InventoryService_WatchInventoryServer) error
+	// ImportItems creates or updates items from a CSV or newline-delimited
+	// JSON document sent in chunks. Items are matched by SKU: rows with a
+	// known SKU update the fields they set, other rows create an item. Rows
+	// that fail are reported and do not stop the import.
+	ImportItems(InventoryService_ImportItemsServer) error
+	// ExportItems streams items as a CSV or newline-delimited JSON document
+	// in chunks, in the form ImportItems reads.
+	ExportItems(*ExportItemsRequest, InventoryService_ExportItemsServer) error
+	// BatchGetItems returns the items with the given ids in that order. It
+	// fails with NOT_FOUND when any of them does not exist.
+	BatchGetItems(context.Context, *BatchGetItemsRequest) (*BatchGetItemsResponse, error)
+	// BatchUpdateItems and BatchAdjustQuantities apply their requests in
+	// order, each seeing the changes of the ones before it. Either all of
+	// them take effect or, when one fails, none does. The MongoDB backend
+	// needs to run as a replica set for these.
+	BatchUpdateItems(context.Context, *BatchUpdateItemsRequest) (*BatchUpdateItemsResponse, error)
+	BatchAdjustQuantities(context.Context, *BatchAdjustQuantitiesRequest) (*BatchAdjustQuantitiesResponse, error)
+	// UploadItemImage adds an image after the images of an item. The item id,
+	// alt text and expected version are read from the first message, and the
+	// image is the data of all messages together. PNG, JPEG, GIF and WebP
+	// images of up to 10 MiB are accepted.
+	UploadItemImage(InventoryService_UploadItemImageServer) error
+	// SetItemImages reorders the images of an item, changes their alt text
+	// and removes those left out. Only images the item has may be given.
+	SetItemImages(context.Context, *SetItemImagesRequest) (*SetItemImagesResponse, error)
+	// DownloadItemImage streams an image in chunks. The first chunk carries
+	// its content type.
+	DownloadItemImage(*DownloadItemImageRequest, InventoryService_DownloadItemImageServer) error
+	// SchedulePrice schedules a price for an item from effectiveFrom until
+	// effectiveTo, or until it is cancelled when effectiveTo is unset. The
+	// windows of the scheduled and active prices of an item must not
+	// overlap.
+	SchedulePrice(context.Context, *SchedulePriceRequest) (*SchedulePriceResponse, error)
+	// CancelScheduledPrice cancels a scheduled price, ending it at once if it
+	// is active.
+	CancelScheduledPrice(context.Context, *CancelScheduledPriceRequest) (*CancelScheduledPriceResponse, error)
+	// GetCurrentPrice resolves the price of an item at the time of the
+	// request: the scheduled price whose window contains it, or else the
+	// item's own price.
+	GetCurrentPrice(context.Context, *GetCurrentPriceRequest) (*GetCurrentPriceResponse, error)
+	// GetPriceHistory lists the scheduled prices of an item, past, present
+	// and future, in the order they take effect.
+	GetPriceHistory(context.Context, *GetPriceHistoryRequest) (*GetPriceHistoryResponse, error)
+	// QueryAuditLog lists the changes made through the inventory and
+	// authentication services, newest first. It is open to the users named
+	// in ADMIN_USERS only.
+	QueryAuditLog(context.Context, *QueryAuditLogRequest) (*QueryAuditLogResponse, error)
+	mustEmbedUnimplementedInventoryServiceServer()
+}
+
+// UnimplementedInventoryServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedInventoryServiceServer struct {
+}
+
+func (UnimplementedInventoryServiceServer) GetInventory(*GetInventoryRequest, InventoryService_GetInventoryServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetInventory not implemented")
+}
+func (UnimplementedInventoryServiceServer) GetItem(context.Context, *GetItemRequest) (*GetItemResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetItem not implemented")
+}
+func (UnimplementedInventoryServiceServer) GetItemBySku(context.Context, *GetItemBySkuRequest) (*GetItemResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetItemBySku not implemented")
+}
+func (UnimplementedInventoryServiceServer) GetItemByBarcode(context.Context, *GetItemByBarcodeRequest) (*GetItemResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetItemByBarcode not implemented")
+}
+func (UnimplementedInventoryServiceServer) FindItems(*FindItemsRequest, InventoryService_FindItemsServer) error {
+	return status.Errorf(codes.Unimplemented, "method FindItems not implemented")
+}
+func (UnimplementedInventoryServiceServer) InsertItem(context.Context, *InsertItemRequest) (*InsertItemResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InsertItem not implemented")
+}
+func (UnimplementedInventoryServiceServer) UpdateItem(context.Context, *UpdateItemRequest) (*UpdateItemResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateItem not implemented")
+}
+func (UnimplementedInventoryServiceServer) DeleteItem(context.Context, *DeleteItemRequest) (*DeleteItemResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteItem not implemented")
+}
+func (UnimplementedInventoryServiceServer) RestoreItem(context.Context, *RestoreItemRequest) (*RestoreItemResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreItem not implemented")
+}
+func (UnimplementedInventoryServiceServer) PurgeItem(context.Context, *PurgeItemRequest) (*PurgeItemResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PurgeItem not implemented")
+}
+func (UnimplementedInventoryServiceServer) IncrementItemQuantity(context.Context, *IncrementItemQuantityRequest) (*IncrementItemQuantityResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IncrementItemQuantity not implemented")
+}
+func (UnimplementedInventoryServiceServer) ReserveStock(context.Context, *ReserveStockRequest) (*ReserveStockResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReserveStock not implemented")
+}
+func (UnimplementedInventoryServiceServer) CommitReservation(context.Context, *CommitReservationRequest) (*CommitReservationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CommitReservation not implemented")
+}
+func (UnimplementedInventoryServiceServer) ReleaseReservation(context.Context, *ReleaseReservationRequest) (*ReleaseReservationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReleaseReservation not implemented")
+}
+func (UnimplementedInventoryServiceServer) SearchItems(*SearchItemsRequest, InventoryService_SearchItemsServer) error {
+	return status.Errorf(codes.Unimplemented, "method SearchItems not implemented")
+}
+func (UnimplementedInventoryServiceServer) ListCategories(*ListCategoriesRequest, InventoryService_ListCategoriesServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListCategories not implemented")
+}
+func (UnimplementedInventoryServiceServer) GetCategory(context.Context, *GetCategoryRequest) (*GetCategoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCategory not implemented")
+}
+func (UnimplementedInventoryServiceServer) CreateCategory(context.Context, *CreateCategoryRequest) (*CreateCategoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateCategory not implemented")
+}
+func (UnimplementedInventoryServiceServer) RenameCategory(context.Context, *RenameCategoryRequest) (*RenameCategoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RenameCategory not implemented")
+}
+func (UnimplementedInventoryServiceServer) MoveCategory(context.Context, *MoveCategoryRequest) (*MoveCategoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MoveCategory not implemented")
+}
+func (UnimplementedInventoryServiceServer) DeleteCategory(context.Context, *DeleteCategoryRequest) (*DeleteCategoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteCategory not implemented")
+}
+func (UnimplementedInventoryServiceServer) AddVariant(context.Context, *AddVariantRequest) (*AddVariantResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddVariant not implemented")
+}
+func (UnimplementedInventoryServiceServer) UpdateVariant(context.Context, *UpdateVariantRequest) (*UpdateVariantResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateVariant not implemented")
+}
+func (UnimplementedInventoryServiceServer) RemoveVariant(context.Context, *RemoveVariantRequest) (*RemoveVariantResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveVariant not implemented")
+}
+func (UnimplementedInventoryServiceServer) ListLocations(*ListLocationsRequest, InventoryService_ListLocationsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListLocations not implemented")
+}
+func (UnimplementedInventoryServiceServer) GetLocation(context.Context, *GetLocationRequest) (*GetLocationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLocation not implemented")
+}
+func (UnimplementedInventoryServiceServer) CreateLocation(context.Context, *CreateLocationRequest) (*CreateLocationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateLocation not implemented")
+}
+func (UnimplementedInventoryServiceServer) RenameLocation(context.Context, *RenameLocationRequest) (*RenameLocationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RenameLocation not implemented")
+}
+func (UnimplementedInventoryServiceServer) DeleteLocation(context.Context, *DeleteLocationRequest) (*DeleteLocationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteLocation not implemented")
+}
+func (UnimplementedInventoryServiceServer) TransferStock(context.Context, *TransferStockRequest) (*TransferStockResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TransferStock not implemented")
+}
+func (UnimplementedInventoryServiceServer) ListStockMovements(*ListStockMovementsRequest, InventoryService_ListStockMovementsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListStockMovements not implemented")
+}
+func (UnimplementedInventoryServiceServer) ListLowStockItems(*ListLowStockItemsRequest, InventoryService_ListLowStockItemsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListLowStockItems not implemented")
+}
+func (UnimplementedInventoryServiceServer) WatchInventory(*WatchInventoryRequest, InventoryService_WatchInventoryServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchInventory not implemented")
+}
+func (UnimplementedInventoryServiceServer) ImportItems(InventoryService_ImportItemsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ImportItems not implemented")
+}
+func (UnimplementedInventoryServiceServer) ExportItems(*ExportItemsRequest, InventoryService_ExportItemsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportItems not implemented")
+}
+func (UnimplementedInventoryServiceServer) BatchGetItems(context.Context, *BatchGetItemsRequest) (*BatchGetItemsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetItems not implemented")
+}
+func (UnimplementedInventoryServiceServer) BatchUpdateItems(context.Context, *BatchUpdateItemsRequest) (*BatchUpdateItemsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchUpdateItems not implemented")
+}
+func (UnimplementedInventoryServiceServer) BatchAdjustQuantities(context.Context, *BatchAdjustQuantitiesRequest) (*BatchAdjustQuantitiesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchAdjustQuantities not implemented")
+}
+func (UnimplementedInventoryServiceServer) UploadItemImage(InventoryService_UploadItemImageServer) error {
+	return status.Errorf(codes.Unimplemented, "method UploadItemImage not implemented")
+}
+func (UnimplementedInventoryServiceServer) SetItemImages(context.Context, *SetItemImagesRequest) (*SetItemImagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetItemImages not implemented")
+}
+func (UnimplementedInventoryServiceServer) DownloadItemImage(*DownloadItemImageRequest, InventoryService_DownloadItemImageServer) error {
+	return status.Errorf(codes.Unimplemented, "method DownloadItemImage not implemented")
+}
+func (UnimplementedInventoryServiceServer) SchedulePrice(context.Context, *SchedulePriceRequest) (*SchedulePriceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SchedulePrice not implemented")
+}
+func (UnimplementedInventoryServiceServer) CancelScheduledPrice(context.Context, *CancelScheduledPriceRequest) (*CancelScheduledPriceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelScheduledPrice not implemented")
+}
+func (UnimplementedInventoryServiceServer) GetCurrentPrice(context.Context, *GetCurrentPriceRequest) (*GetCurrentPriceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCurrentPrice not implemented")
+}
+func (UnimplementedInventoryServiceServer) GetPriceHistory(context.Context, *GetPriceHistoryRequest) (*GetPriceHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPriceHistory not implemented")
+}
+func (UnimplementedInventoryServiceServer) QueryAuditLog(context.Context, *QueryAuditLogRequest) (*QueryAuditLogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryAuditLog not implemented")
+}
+func (UnimplementedInventoryServiceServer) mustEmbedUnimplementedInventoryServiceServer() {}
+
+// UnsafeInventoryServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to InventoryServiceServer will
+// result in compilation errors.
+type UnsafeInventoryServiceServer interface {
+	mustEmbedUnimplementedInventoryServiceServer()
+}
+
+func RegisterInventoryServiceServer(s grpc.ServiceRegistrar, srv InventoryServiceServer) {
+	s.RegisterService(&InventoryService_ServiceDesc, srv)
+}
+
+func _InventoryService_GetInventory_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetInventoryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(InventoryServiceServer).GetInventory(m, &inventoryServiceGetInventoryServer{stream})
+}
+
+type InventoryService_GetInventoryServer interface {
+	Send(*InventoryItem) error
+	grpc.ServerStream
+}
+
+type inventoryServiceGetInventoryServer struct {
+	grpc.ServerStream
+}
+
+func (x *inventoryServiceGetInventoryServer) Send(m *InventoryItem) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _InventoryService_GetItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetItemRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).GetItem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/GetItem",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).GetItem(ctx, req.(*GetItemRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_GetItemBySku_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetItemBySkuRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).GetItemBySku(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/GetItemBySku",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).GetItemBySku(ctx, req.(*GetItemBySkuRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_GetItemByBarcode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetItemByBarcodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).GetItemByBarcode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/GetItemByBarcode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).GetItemByBarcode(ctx, req.(*GetItemByBarcodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_FindItems_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(FindItemsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(InventoryServiceServer).FindItems(m, &inventoryServiceFindItemsServer{stream})
+}
+
+type InventoryService_FindItemsServer interface {
+	Send(*InventoryItem) error
+	grpc.ServerStream
+}
+
+type inventoryServiceFindItemsServer struct {
+	grpc.ServerStream
+}
+
+func (x *inventoryServiceFindItemsServer) Send(m *InventoryItem) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _InventoryService_InsertItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InsertItemRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).InsertItem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/InsertItem",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).InsertItem(ctx, req.(*InsertItemRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_UpdateItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateItemRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).UpdateItem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/UpdateItem",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).UpdateItem(ctx, req.(*UpdateItemRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_DeleteItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteItemRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).DeleteItem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/DeleteItem",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).DeleteItem(ctx, req.(*DeleteItemRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_RestoreItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreItemRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).RestoreItem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/RestoreItem",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).RestoreItem(ctx, req.(*RestoreItemRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_PurgeItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PurgeItemRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).PurgeItem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/PurgeItem",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).PurgeItem(ctx, req.(*PurgeItemRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_IncrementItemQuantity_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IncrementItemQuantityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).IncrementItemQuantity(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/IncrementItemQuantity",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).IncrementItemQuantity(ctx, req.(*IncrementItemQuantityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_ReserveStock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReserveStockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).ReserveStock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/ReserveStock",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).ReserveStock(ctx, req.(*ReserveStockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_CommitReservation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommitReservationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).CommitReservation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/CommitReservation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).CommitReservation(ctx, req.(*CommitReservationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_ReleaseReservation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReleaseReservationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).ReleaseReservation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/ReleaseReservation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).ReleaseReservation(ctx, req.(*ReleaseReservationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_SearchItems_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SearchItemsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(InventoryServiceServer).SearchItems(m, &inventoryServiceSearchItemsServer{stream})
+}
+
+type InventoryService_SearchItemsServer interface {
+	Send(*SearchResult) error
+	grpc.ServerStream
+}
+
+type inventoryServiceSearchItemsServer struct {
+	grpc.ServerStream
+}
+
+func (x *inventoryServiceSearchItemsServer) Send(m *SearchResult) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _InventoryService_ListCategories_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListCategoriesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(InventoryServiceServer).ListCategories(m, &inventoryServiceListCategoriesServer{stream})
+}
+
+type InventoryService_ListCategoriesServer interface {
+	Send(*Category) error
+	grpc.ServerStream
+}
+
+type inventoryServiceListCategoriesServer struct {
+	grpc.ServerStream
+}
+
+func (x *inventoryServiceListCategoriesServer) Send(m *Category) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _InventoryService_GetCategory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCategoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).GetCategory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/GetCategory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).GetCategory(ctx, req.(*GetCategoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_CreateCategory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateCategoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).CreateCategory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/CreateCategory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).CreateCategory(ctx, req.(*CreateCategoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_RenameCategory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenameCategoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).RenameCategory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/RenameCategory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).RenameCategory(ctx, req.(*RenameCategoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_MoveCategory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MoveCategoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).MoveCategory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/MoveCategory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).MoveCategory(ctx, req.(*MoveCategoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_DeleteCategory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCategoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).DeleteCategory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/DeleteCategory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).DeleteCategory(ctx, req.(*DeleteCategoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_AddVariant_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddVariantRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).AddVariant(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/AddVariant",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).AddVariant(ctx, req.(*AddVariantRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_UpdateVariant_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateVariantRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).UpdateVariant(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/UpdateVariant",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).UpdateVariant(ctx, req.(*UpdateVariantRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_RemoveVariant_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveVariantRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).RemoveVariant(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/RemoveVariant",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).RemoveVariant(ctx, req.(*RemoveVariantRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_ListLocations_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListLocationsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(InventoryServiceServer).ListLocations(m, &inventoryServiceListLocationsServer{stream})
+}
+
+type InventoryService_ListLocationsServer interface {
+	Send(*Location) error
+	grpc.ServerStream
+}
+
+type inventoryServiceListLocationsServer struct {
+	grpc.ServerStream
+}
+
+func (x *inventoryServiceListLocationsServer) Send(m *Location) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _InventoryService_GetLocation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLocationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).GetLocation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/GetLocation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).GetLocation(ctx, req.(*GetLocationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_CreateLocation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateLocationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).CreateLocation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/CreateLocation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).CreateLocation(ctx, req.(*CreateLocationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_RenameLocation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenameLocationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).RenameLocation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/RenameLocation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).RenameLocation(ctx, req.(*RenameLocationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_DeleteLocation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteLocationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).DeleteLocation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/DeleteLocation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).DeleteLocation(ctx, req.(*DeleteLocationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_TransferStock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransferStockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).TransferStock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/TransferStock",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).TransferStock(ctx, req.(*TransferStockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_ListStockMovements_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListStockMovementsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(InventoryServiceServer).ListStockMovements(m, &inventoryServiceListStockMovementsServer{stream})
+}
+
+type InventoryService_ListStockMovementsServer interface {
+	Send(*StockMovement) error
+	grpc.ServerStream
+}
+
+type inventoryServiceListStockMovementsServer struct {
+	grpc.ServerStream
+}
+
+func (x *inventoryServiceListStockMovementsServer) Send(m *StockMovement) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _InventoryService_ListLowStockItems_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListLowStockItemsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(InventoryServiceServer).ListLowStockItems(m, &inventoryServiceListLowStockItemsServer{stream})
+}
+
+type InventoryService_ListLowStockItemsServer interface {
+	Send(*InventoryItem) error
+	grpc.ServerStream
+}
+
+type inventoryServiceListLowStockItemsServer struct {
+	grpc.ServerStream
+}
+
+func (x *inventoryServiceListLowStockItemsServer) Send(m *InventoryItem) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _InventoryService_WatchInventory_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchInventoryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(InventoryServiceServer).WatchInventory(m, &inventoryServiceWatchInventoryServer{stream})
 }
 
-func (c *inventoryServiceClient) UpdateItem(ctx context.Context, in *UpdateItemRequest, opts ...grpc.CallOption) (*UpdateItemResponse, error) {
-	out := new(UpdateItemResponse)
-	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/UpdateItem", in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+type InventoryService_WatchInventoryServer interface {
+	Send(*ItemChange) error
+	grpc.ServerStream
 }
 
-func (c *inventoryServiceClient) DeleteItem(ctx context.Context, in *DeleteItemRequest, opts ...grpc.CallOption) (*DeleteItemResponse, error) {
-	out := new(DeleteItemResponse)
-	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/DeleteItem", in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+type inventoryServiceWatchInventoryServer struct {
+	grpc.ServerStream
 }
 
-func (c *inventoryServiceClient) IncrementItemQuantity(ctx context.Context, in *IncrementItemQuantityRequest, opts ...grpc.CallOption) (*IncrementItemQuantityResponse, error) {
-	out := new(IncrementItemQuantityResponse)
-	err := c.cc.Invoke(ctx, "/protobuf.InventoryService/IncrementItemQuantity", in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+func (x *inventoryServiceWatchInventoryServer) Send(m *ItemChange) error {
+	return x.ServerStream.SendMsg(m)
 }
 
-// InventoryServiceServer is the server API for InventoryService service.
-// All implementations must embed UnimplementedInventoryServiceServer
-// for forward compatibility
-type InventoryServiceServer interface {
-	GetInventory(*Empty, InventoryService_GetInventoryServer) error
-	GetItem(context.Context, *GetItemRequest) (*GetItemResponse, error)
-	FindItems(*FindItemsRequest, InventoryService_FindItemsServer) error
-	InsertItem(context.Context, *InsertItemRequest) (*InsertItemResponse, error)
-	UpdateItem(context.Context, *UpdateItemRequest) (*UpdateItemResponse, error)
-	DeleteItem(context.Context, *DeleteItemRequest) (*DeleteItemResponse, error)
-	IncrementItemQuantity(context.Context, *IncrementItemQuantityRequest) (*IncrementItemQuantityResponse, error)
-	mustEmbedUnimplementedInventoryServiceServer()
+func _InventoryService_ImportItems_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(InventoryServiceServer).ImportItems(&inventoryServiceImportItemsServer{stream})
 }
 
-// UnimplementedInventoryServiceServer must be embedded to have forward compatible implementations.
-type UnimplementedInventoryServiceServer struct {
+type InventoryService_ImportItemsServer interface {
+	SendAndClose(*ImportItemsResponse) error
+	Recv() (*ImportItemsRequest, error)
+	grpc.ServerStream
 }
 
-func (UnimplementedInventoryServiceServer) GetInventory(*Empty, InventoryService_GetInventoryServer) error {
-	return status.Errorf(codes.Unimplemented, "method GetInventory not implemented")
+type inventoryServiceImportItemsServer struct {
+	grpc.ServerStream
 }
-func (UnimplementedInventoryServiceServer) GetItem(context.Context, *GetItemRequest) (*GetItemResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetItem not implemented")
+
+func (x *inventoryServiceImportItemsServer) SendAndClose(m *ImportItemsResponse) error {
+	return x.ServerStream.SendMsg(m)
 }
-func (UnimplementedInventoryServiceServer) FindItems(*FindItemsRequest, InventoryService_FindItemsServer) error {
-	return status.Errorf(codes.Unimplemented, "method FindItems not implemented")
+
+func (x *inventoryServiceImportItemsServer) Recv() (*ImportItemsRequest, error) {
+	m := new(ImportItemsRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
 }
-func (UnimplementedInventoryServiceServer) InsertItem(context.Context, *InsertItemRequest) (*InsertItemResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method InsertItem not implemented")
+
+func _InventoryService_ExportItems_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportItemsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(InventoryServiceServer).ExportItems(m, &inventoryServiceExportItemsServer{stream})
 }
-func (UnimplementedInventoryServiceServer) UpdateItem(context.Context, *UpdateItemRequest) (*UpdateItemResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method UpdateItem not implemented")
+
+type InventoryService_ExportItemsServer interface {
+	Send(*ExportItemsChunk) error
+	grpc.ServerStream
 }
-func (UnimplementedInventoryServiceServer) DeleteItem(context.Context, *DeleteItemRequest) (*DeleteItemResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method DeleteItem not implemented")
+
+type inventoryServiceExportItemsServer struct {
+	grpc.ServerStream
 }
-func (UnimplementedInventoryServiceServer) IncrementItemQuantity(context.Context, *IncrementItemQuantityRequest) (*IncrementItemQuantityResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method IncrementItemQuantity not implemented")
+
+func (x *inventoryServiceExportItemsServer) Send(m *ExportItemsChunk) error {
+	return x.ServerStream.SendMsg(m)
 }
-func (UnimplementedInventoryServiceServer) mustEmbedUnimplementedInventoryServiceServer() {}
 
-// UnsafeInventoryServiceServer may be embedded to opt out of forward compatibility for this service.
-// Use of this interface is not recommended, as added methods to InventoryServiceServer will
-// result in compilation errors.
-type UnsafeInventoryServiceServer interface {
-	mustEmbedUnimplementedInventoryServiceServer()
+func _InventoryService_BatchGetItems_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetItemsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).BatchGetItems(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/BatchGetItems",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).BatchGetItems(ctx, req.(*BatchGetItemsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func RegisterInventoryServiceServer(s grpc.ServiceRegistrar, srv InventoryServiceServer) {
-	s.RegisterService(&InventoryService_ServiceDesc, srv)
+func _InventoryService_BatchUpdateItems_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchUpdateItemsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).BatchUpdateItems(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/BatchUpdateItems",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).BatchUpdateItems(ctx, req.(*BatchUpdateItemsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _InventoryService_GetInventory_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(Empty)
-	if err := stream.RecvMsg(m); err != nil {
-		return err
+func _InventoryService_BatchAdjustQuantities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchAdjustQuantitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
 	}
-	return srv.(InventoryServiceServer).GetInventory(m, &inventoryServiceGetInventoryServer{stream})
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).BatchAdjustQuantities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/BatchAdjustQuantities",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).BatchAdjustQuantities(ctx, req.(*BatchAdjustQuantitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-type InventoryService_GetInventoryServer interface {
-	Send(*InventoryItem) error
+func _InventoryService_UploadItemImage_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(InventoryServiceServer).UploadItemImage(&inventoryServiceUploadItemImageServer{stream})
+}
+
+type InventoryService_UploadItemImageServer interface {
+	SendAndClose(*UploadItemImageResponse) error
+	Recv() (*UploadItemImageRequest, error)
 	grpc.ServerStream
 }
 
-type inventoryServiceGetInventoryServer struct {
+type inventoryServiceUploadItemImageServer struct {
 	grpc.ServerStream
 }
 
-func (x *inventoryServiceGetInventoryServer) Send(m *InventoryItem) error {
+func (x *inventoryServiceUploadItemImageServer) SendAndClose(m *UploadItemImageResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func _InventoryService_GetItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetItemRequest)
+func (x *inventoryServiceUploadItemImageServer) Recv() (*UploadItemImageRequest, error) {
+	m := new(UploadItemImageRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _InventoryService_SetItemImages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetItemImagesRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(InventoryServiceServer).GetItem(ctx, in)
+		return srv.(InventoryServiceServer).SetItemImages(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/protobuf.InventoryService/GetItem",
+		FullMethod: "/protobuf.InventoryService/SetItemImages",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(InventoryServiceServer).GetItem(ctx, req.(*GetItemRequest))
+		return srv.(InventoryServiceServer).SetItemImages(ctx, req.(*SetItemImagesRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _InventoryService_FindItems_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(FindItemsRequest)
+func _InventoryService_DownloadItemImage_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadItemImageRequest)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(InventoryServiceServer).FindItems(m, &inventoryServiceFindItemsServer{stream})
+	return srv.(InventoryServiceServer).DownloadItemImage(m, &inventoryServiceDownloadItemImageServer{stream})
 }
 
-type InventoryService_FindItemsServer interface {
-	Send(*InventoryItem) error
+type InventoryService_DownloadItemImageServer interface {
+	Send(*ItemImageChunk) error
 	grpc.ServerStream
 }
 
-type inventoryServiceFindItemsServer struct {
+type inventoryServiceDownloadItemImageServer struct {
 	grpc.ServerStream
 }
 
-func (x *inventoryServiceFindItemsServer) Send(m *InventoryItem) error {
+func (x *inventoryServiceDownloadItemImageServer) Send(m *ItemImageChunk) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func _InventoryService_InsertItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(InsertItemRequest)
+func _InventoryService_SchedulePrice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SchedulePriceRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(InventoryServiceServer).InsertItem(ctx, in)
+		return srv.(InventoryServiceServer).SchedulePrice(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/protobuf.InventoryService/InsertItem",
+		FullMethod: "/protobuf.InventoryService/SchedulePrice",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(InventoryServiceServer).InsertItem(ctx, req.(*InsertItemRequest))
+		return srv.(InventoryServiceServer).SchedulePrice(ctx, req.(*SchedulePriceRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _InventoryService_UpdateItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(UpdateItemRequest)
+func _InventoryService_CancelScheduledPrice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelScheduledPriceRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(InventoryServiceServer).UpdateItem(ctx, in)
+		return srv.(InventoryServiceServer).CancelScheduledPrice(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/protobuf.InventoryService/UpdateItem",
+		FullMethod: "/protobuf.InventoryService/CancelScheduledPrice",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(InventoryServiceServer).UpdateItem(ctx, req.(*UpdateItemRequest))
+		return srv.(InventoryServiceServer).CancelScheduledPrice(ctx, req.(*CancelScheduledPriceRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _InventoryService_DeleteItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DeleteItemRequest)
+func _InventoryService_GetCurrentPrice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCurrentPriceRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(InventoryServiceServer).DeleteItem(ctx, in)
+		return srv.(InventoryServiceServer).GetCurrentPrice(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/protobuf.InventoryService/DeleteItem",
+		FullMethod: "/protobuf.InventoryService/GetCurrentPrice",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(InventoryServiceServer).DeleteItem(ctx, req.(*DeleteItemRequest))
+		return srv.(InventoryServiceServer).GetCurrentPrice(ctx, req.(*GetCurrentPriceRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _InventoryService_IncrementItemQuantity_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(IncrementItemQuantityRequest)
+func _InventoryService_GetPriceHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPriceHistoryRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(InventoryServiceServer).IncrementItemQuantity(ctx, in)
+		return srv.(InventoryServiceServer).GetPriceHistory(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/protobuf.InventoryService/IncrementItemQuantity",
+		FullMethod: "/protobuf.InventoryService/GetPriceHistory",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(InventoryServiceServer).IncrementItemQuantity(ctx, req.(*IncrementItemQuantityRequest))
+		return srv.(InventoryServiceServer).GetPriceHistory(ctx, req.(*GetPriceHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventoryService_QueryAuditLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryAuditLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventoryServiceServer).QueryAuditLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.InventoryService/QueryAuditLog",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventoryServiceServer).QueryAuditLog(ctx, req.(*QueryAuditLogRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -343,6 +1973,14 @@ var InventoryService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetItem",
 			Handler:    _InventoryService_GetItem_Handler,
 		},
+		{
+			MethodName: "GetItemBySku",
+			Handler:    _InventoryService_GetItemBySku_Handler,
+		},
+		{
+			MethodName: "GetItemByBarcode",
+			Handler:    _InventoryService_GetItemByBarcode_Handler,
+		},
 		{
 			MethodName: "InsertItem",
 			Handler:    _InventoryService_InsertItem_Handler,
@@ -355,10 +1993,118 @@ var InventoryService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteItem",
 			Handler:    _InventoryService_DeleteItem_Handler,
 		},
+		{
+			MethodName: "RestoreItem",
+			Handler:    _InventoryService_RestoreItem_Handler,
+		},
+		{
+			MethodName: "PurgeItem",
+			Handler:    _InventoryService_PurgeItem_Handler,
+		},
 		{
 			MethodName: "IncrementItemQuantity",
 			Handler:    _InventoryService_IncrementItemQuantity_Handler,
 		},
+		{
+			MethodName: "ReserveStock",
+			Handler:    _InventoryService_ReserveStock_Handler,
+		},
+		{
+			MethodName: "CommitReservation",
+			Handler:    _InventoryService_CommitReservation_Handler,
+		},
+		{
+			MethodName: "ReleaseReservation",
+			Handler:    _InventoryService_ReleaseReservation_Handler,
+		},
+		{
+			MethodName: "GetCategory",
+			Handler:    _InventoryService_GetCategory_Handler,
+		},
+		{
+			MethodName: "CreateCategory",
+			Handler:    _InventoryService_CreateCategory_Handler,
+		},
+		{
+			MethodName: "RenameCategory",
+			Handler:    _InventoryService_RenameCategory_Handler,
+		},
+		{
+			MethodName: "MoveCategory",
+			Handler:    _InventoryService_MoveCategory_Handler,
+		},
+		{
+			MethodName: "DeleteCategory",
+			Handler:    _InventoryService_DeleteCategory_Handler,
+		},
+		{
+			MethodName: "AddVariant",
+			Handler:    _InventoryService_AddVariant_Handler,
+		},
+		{
+			MethodName: "UpdateVariant",
+			Handler:    _InventoryService_UpdateVariant_Handler,
+		},
+		{
+			MethodName: "RemoveVariant",
+			Handler:    _InventoryService_RemoveVariant_Handler,
+		},
+		{
+			MethodName: "GetLocation",
+			Handler:    _InventoryService_GetLocation_Handler,
+		},
+		{
+			MethodName: "CreateLocation",
+			Handler:    _InventoryService_CreateLocation_Handler,
+		},
+		{
+			MethodName: "RenameLocation",
+			Handler:    _InventoryService_RenameLocation_Handler,
+		},
+		{
+			MethodName: "DeleteLocation",
+			Handler:    _InventoryService_DeleteLocation_Handler,
+		},
+		{
+			MethodName: "TransferStock",
+			Handler:    _InventoryService_TransferStock_Handler,
+		},
+		{
+			MethodName: "BatchGetItems",
+			Handler:    _InventoryService_BatchGetItems_Handler,
+		},
+		{
+			MethodName: "BatchUpdateItems",
+			Handler:    _InventoryService_BatchUpdateItems_Handler,
+		},
+		{
+			MethodName: "BatchAdjustQuantities",
+			Handler:    _InventoryService_BatchAdjustQuantities_Handler,
+		},
+		{
+			MethodName: "SetItemImages",
+			Handler:    _InventoryService_SetItemImages_Handler,
+		},
+		{
+			MethodName: "SchedulePrice",
+			Handler:    _InventoryService_SchedulePrice_Handler,
+		},
+		{
+			MethodName: "CancelScheduledPrice",
+			Handler:    _InventoryService_CancelScheduledPrice_Handler,
+		},
+		{
+			MethodName: "GetCurrentPrice",
+			Handler:    _InventoryService_GetCurrentPrice_Handler,
+		},
+		{
+			MethodName: "GetPriceHistory",
+			Handler:    _InventoryService_GetPriceHistory_Handler,
+		},
+		{
+			MethodName: "QueryAuditLog",
+			Handler:    _InventoryService_QueryAuditLog_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -371,6 +2117,56 @@ var InventoryService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _InventoryService_FindItems_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "SearchItems",
+			Handler:       _InventoryService_SearchItems_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ListCategories",
+			Handler:       _InventoryService_ListCategories_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ListLocations",
+			Handler:       _InventoryService_ListLocations_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ListStockMovements",
+			Handler:       _InventoryService_ListStockMovements_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ListLowStockItems",
+			Handler:       _InventoryService_ListLowStockItems_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchInventory",
+			Handler:       _InventoryService_WatchInventory_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ImportItems",
+			Handler:       _InventoryService_ImportItems_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ExportItems",
+			Handler:       _InventoryService_ExportItems_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "UploadItemImage",
+			Handler:       _InventoryService_UploadItemImage_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "DownloadItemImage",
+			Handler:       _InventoryService_DownloadItemImage_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "inventory.proto",
 }
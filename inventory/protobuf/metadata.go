@@ -0,0 +1,11 @@
+package protobuf
+
+// The gRPC metadata keys of the inventory service, shared by its clients.
+const (
+	// IdempotencyKeyMetadataKey carries the key that makes a write
+	// idempotent.
+	IdempotencyKeyMetadataKey = "idempotency-key"
+	// IdempotentReplayMetadataKey is sent in the header of a response that
+	// was replayed for an idempotency key.
+	IdempotentReplayMetadataKey = "idempotent-replayed"
+)
@@ -0,0 +1,129 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// token is a normalized term together with the byte range of the word it
+// was read from, so that matches can be highlighted in the original text.
+type token struct {
+	term  string
+	start int
+	end   int
+}
+
+// analyze splits text into words, lowercases and stems them.
+func analyze(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, token{term: stem(strings.ToLower(text[start:i])), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{term: stem(strings.ToLower(text[start:])), start: start, end: len(text)})
+	}
+	return tokens
+}
+
+// stem strips common English inflections so that "apples" finds "apple" and
+// "running" finds "run". It is deliberately conservative: stems only need to
+// agree with each other, not be dictionary words.
+func stem(word string) string {
+	if utf8.RuneCountInString(word) <= 3 {
+		return word
+	}
+	switch {
+	case strings.HasSuffix(word, "sses"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "ches"), strings.HasSuffix(word, "shes"),
+		strings.HasSuffix(word, "xes"), strings.HasSuffix(word, "zes"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "ss"), strings.HasSuffix(word, "us"), strings.HasSuffix(word, "is"):
+		return word
+	case strings.HasSuffix(word, "s"):
+		return word[:len(word)-1]
+	case strings.HasSuffix(word, "ing") && len(word) > 5:
+		return undouble(word[:len(word)-3])
+	case strings.HasSuffix(word, "ed") && len(word) > 4:
+		return undouble(word[:len(word)-2])
+	}
+	return word
+}
+
+// undouble removes a doubled final consonant left behind by a stripped
+// suffix, as in "stopped" -> "stopp" -> "stop".
+func undouble(word string) string {
+	n := len(word)
+	if n >= 2 && word[n-1] == word[n-2] && !strings.ContainsRune("aeiouls", rune(word[n-1])) {
+		return word[:n-1]
+	}
+	return word
+}
+
+// maxEdits is the number of typos tolerated in a query term of the given
+// length. Short terms must match exactly to avoid drowning results in noise.
+func maxEdits(term string) int {
+	switch n := utf8.RuneCountInString(term); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	}
+	return 2
+}
+
+// editDistance returns the Levenshtein distance between a and b, or a value
+// greater than limit once the distance is known to exceed it.
+func editDistance(a, b string, limit int) int {
+	ar, br := []rune(a), []rune(b)
+	if d := len(ar) - len(br); d > limit || -d > limit {
+		return limit + 1
+	}
+	previous := make([]int, len(br)+1)
+	current := make([]int, len(br)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		current[0] = i
+		rowMin := current[0]
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if current[j] < rowMin {
+				rowMin = current[j]
+			}
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		previous, current = current, previous
+	}
+	return previous[len(br)]
+}
+
+func minInt(values ...int) int {
+	result := values[0]
+	for _, v := range values[1:] {
+		if v < result {
+			result = v
+		}
+	}
+	return result
+}
@@ -0,0 +1,60 @@
+package search
+
+import "testing"
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"apples", "apple"},
+		{"berries", "berry"},
+		{"glasses", "glass"},
+		{"boxes", "box"},
+		{"brushes", "brush"},
+		{"running", "run"},
+		{"stopped", "stop"},
+		{"filled", "fill"},
+		{"cactus", "cactus"},
+		{"cups", "cup"},
+		{"mug", "mug"},
+	}
+	for _, test := range tests {
+		if got := stem(test.word); got != test.want {
+			t.Errorf("stem(%q) = %q, want %q", test.word, got, test.want)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	tokens := analyze("Red, Enamel-Mugs!")
+	want := []token{{"red", 0, 3}, {"enamel", 5, 11}, {"mug", 12, 16}}
+	if len(tokens) != len(want) {
+		t.Fatalf("analyze returned %v, want %v", tokens, want)
+	}
+	for i := range want {
+		if tokens[i] != want[i] {
+			t.Errorf("token %d is %v, want %v", i, tokens[i], want[i])
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		limit int
+		want  int
+	}{
+		{"kettle", "kettle", 2, 0},
+		{"kettle", "ketle", 2, 1},
+		{"kettle", "kettel", 2, 2},
+		{"kettle", "bottle", 1, 2},
+		{"kettle", "kettles and", 2, 3},
+		{"café", "cafe", 1, 1},
+	}
+	for _, test := range tests {
+		if got := editDistance(test.a, test.b, test.limit); got != test.want {
+			t.Errorf("editDistance(%q, %q, %d) = %d, want %d", test.a, test.b, test.limit, got, test.want)
+		}
+	}
+}
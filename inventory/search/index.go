@@ -0,0 +1,299 @@
+// Package search implements an in-process full-text index over inventory
+// items, so that relevance ranked search works without an external search
+// engine.
+package search
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+)
+
+// field is an indexed item field. Matches in heavier fields rank higher.
+type field struct {
+	name   string
+	weight float64
+	text   func(*pb.InventoryItem) string
+}
+
+var fields = []field{
+	{name: "name", weight: 3, text: (*pb.InventoryItem).GetName},
+	{name: "description", weight: 1, text: (*pb.InventoryItem).GetDescription},
+}
+
+// BM25 parameters.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Score multipliers for query terms matched with typos.
+var editPenalty = []float64{1, 0.6, 0.4}
+
+// descriptionWindow is the number of words shown around the first match in
+// a description snippet.
+const descriptionWindow = 12
+
+type document struct {
+	texts  []string
+	tokens [][]token
+	// counts holds per field term frequencies.
+	counts []map[string]int
+}
+
+// Hit is an item matching a search.
+type Hit struct {
+	ItemId     string
+	Score      float64
+	Highlights []*pb.Highlight
+}
+
+// Index is a full-text index of item names and descriptions. It is safe for
+// concurrent use.
+type Index struct {
+	mu        sync.RWMutex
+	documents map[string]*document
+	// postings maps each term to the ids of the items containing it.
+	postings map[string]map[string]bool
+	// totalLength holds the summed token count of each field, for BM25
+	// length normalization.
+	totalLength []int
+}
+
+func NewIndex() *Index {
+	return &Index{
+		documents:   make(map[string]*document),
+		postings:    make(map[string]map[string]bool),
+		totalLength: make([]int, len(fields)),
+	}
+}
+
+// Add indexes item, replacing any earlier version of it.
+func (x *Index) Add(item *pb.InventoryItem) {
+	doc := &document{}
+	for _, f := range fields {
+		text := f.text(item)
+		tokens := analyze(text)
+		counts := make(map[string]int)
+		for _, t := range tokens {
+			counts[t.term]++
+		}
+		doc.texts = append(doc.texts, text)
+		doc.tokens = append(doc.tokens, tokens)
+		doc.counts = append(doc.counts, counts)
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(item.GetId())
+	x.documents[item.GetId()] = doc
+	for i, counts := range doc.counts {
+		x.totalLength[i] += len(doc.tokens[i])
+		for term := range counts {
+			if x.postings[term] == nil {
+				x.postings[term] = make(map[string]bool)
+			}
+			x.postings[term][item.GetId()] = true
+		}
+	}
+}
+
+// Replace makes x index what other indexes, for rebuilding an index in
+// use. other must not be used afterwards.
+func (x *Index) Replace(other *Index) {
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.documents, x.postings, x.totalLength = other.documents, other.postings, other.totalLength
+}
+
+// Remove drops an item from the index.
+func (x *Index) Remove(itemId string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(itemId)
+}
+
+func (x *Index) remove(itemId string) {
+	doc, ok := x.documents[itemId]
+	if !ok {
+		return
+	}
+	for i, counts := range doc.counts {
+		x.totalLength[i] -= len(doc.tokens[i])
+		for term := range counts {
+			delete(x.postings[term], itemId)
+			if len(x.postings[term]) == 0 {
+				delete(x.postings, term)
+			}
+		}
+	}
+	delete(x.documents, itemId)
+}
+
+// Search returns up to limit items matching query, most relevant first. Any
+// query term may match; items matching more and rarer terms rank higher.
+// With fuzzy set, query terms also match indexed terms a few typos away.
+func (x *Index) Search(query string, limit int, fuzzy bool) []Hit {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	scores := make(map[string]float64)
+	matched := make(map[string]map[string]bool)
+	seen := make(map[string]bool)
+	for _, t := range analyze(query) {
+		if seen[t.term] {
+			continue
+		}
+		seen[t.term] = true
+		best := make(map[string]float64)
+		for term, penalty := range x.variants(t.term, fuzzy) {
+			idf := x.idf(term)
+			for itemId := range x.postings[term] {
+				score := penalty * idf * x.termWeight(x.documents[itemId], term)
+				if score > best[itemId] {
+					best[itemId] = score
+				}
+				if matched[itemId] == nil {
+					matched[itemId] = make(map[string]bool)
+				}
+				matched[itemId][term] = true
+			}
+		}
+		for itemId, score := range best {
+			scores[itemId] += score
+		}
+	}
+	hits := make([]Hit, 0, len(scores))
+	for itemId, score := range scores {
+		hits = append(hits, Hit{ItemId: itemId, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ItemId < hits[j].ItemId
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	for i := range hits {
+		hits[i].Highlights = highlights(x.documents[hits[i].ItemId], matched[hits[i].ItemId])
+	}
+	return hits
+}
+
+// variants returns the indexed terms a query term matches, with the score
+// multiplier of each.
+func (x *Index) variants(term string, fuzzy bool) map[string]float64 {
+	result := make(map[string]float64)
+	if _, ok := x.postings[term]; ok {
+		result[term] = editPenalty[0]
+	}
+	limit := maxEdits(term)
+	if !fuzzy || limit == 0 {
+		return result
+	}
+	for candidate := range x.postings {
+		if candidate == term {
+			continue
+		}
+		if d := editDistance(term, candidate, limit); d <= limit {
+			result[candidate] = editPenalty[d]
+		}
+	}
+	return result
+}
+
+func (x *Index) idf(term string) float64 {
+	n := float64(len(x.documents))
+	df := float64(len(x.postings[term]))
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+// termWeight is the BM25F saturation of term's weighted, length normalized
+// frequency across the fields of doc.
+func (x *Index) termWeight(doc *document, term string) float64 {
+	var tf float64
+	for i, f := range fields {
+		count := doc.counts[i][term]
+		if count == 0 {
+			continue
+		}
+		average := float64(x.totalLength[i]) / float64(len(x.documents))
+		norm := 1 - b
+		if average > 0 {
+			norm += b * float64(len(doc.tokens[i])) / average
+		}
+		tf += f.weight * float64(count) / norm
+	}
+	return tf * (k1 + 1) / (tf + k1)
+}
+
+// highlights builds a snippet for every field of doc containing a matched
+// term. Names are shown whole; descriptions are cut to a window around the
+// first match.
+func highlights(doc *document, matched map[string]bool) []*pb.Highlight {
+	var result []*pb.Highlight
+	for i, f := range fields {
+		tokens := doc.tokens[i]
+		first := -1
+		for j, t := range tokens {
+			if matched[t.term] {
+				first = j
+				break
+			}
+		}
+		if first < 0 {
+			continue
+		}
+		from, to := 0, len(tokens)
+		if f.name == "description" {
+			from = first - descriptionWindow/4
+			if from < 0 {
+				from = 0
+			}
+			if to > from+descriptionWindow {
+				to = from + descriptionWindow
+			}
+		}
+		result = append(result, &pb.Highlight{
+			Field:   f.name,
+			Snippet: snippet(doc.texts[i], tokens, from, to, matched),
+		})
+	}
+	return result
+}
+
+// snippet renders the text spanned by tokens[from:to], escaping it as HTML
+// and wrapping matched words in <em> tags. Cut ends are marked with "…".
+func snippet(text string, tokens []token, from, to int, matched map[string]bool) string {
+	var sb strings.Builder
+	start, end := 0, len(text)
+	if from > 0 {
+		start = tokens[from].start
+		sb.WriteString("…")
+	}
+	if to < len(tokens) {
+		end = tokens[to-1].end
+	}
+	position := start
+	for _, t := range tokens[from:to] {
+		if !matched[t.term] {
+			continue
+		}
+		sb.WriteString(html.EscapeString(text[position:t.start]))
+		sb.WriteString("<em>")
+		sb.WriteString(html.EscapeString(text[t.start:t.end]))
+		sb.WriteString("</em>")
+		position = t.end
+	}
+	sb.WriteString(html.EscapeString(text[position:end]))
+	if to < len(tokens) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
@@ -0,0 +1,101 @@
+package search
+
+import (
+	"testing"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+)
+
+func testIndex() *Index {
+	index := NewIndex()
+	index.Add(&pb.InventoryItem{Id: "kettle", Name: "Stovetop kettle", Description: "A whistling kettle for gas and induction hobs"})
+	index.Add(&pb.InventoryItem{Id: "mug", Name: "Enamel mug", Description: "Goes well with a kettle of tea"})
+	index.Add(&pb.InventoryItem{Id: "teapot", Name: "Teapot", Description: "Cast iron"})
+	return index
+}
+
+func hitIds(hits []Hit) []string {
+	var ids []string
+	for _, hit := range hits {
+		ids = append(ids, hit.ItemId)
+	}
+	return ids
+}
+
+func TestSearchRanking(t *testing.T) {
+	index := testIndex()
+	tests := []struct {
+		query string
+		fuzzy bool
+		want  []string
+	}{
+		// Names weigh more than descriptions.
+		{"kettle", false, []string{"kettle", "mug"}},
+		{"kettles", false, []string{"kettle", "mug"}},
+		// Of equally rare terms, the one in the shorter name ranks higher.
+		{"enamel teapot", false, []string{"teapot", "mug"}},
+		{"ketle", false, nil},
+		{"ketle", true, []string{"kettle", "mug"}},
+		// Short terms must match exactly.
+		{"mig", true, nil},
+		{"", false, nil},
+	}
+	for _, test := range tests {
+		got := hitIds(index.Search(test.query, 10, test.fuzzy))
+		if len(got) != len(test.want) {
+			t.Errorf("Search(%q, fuzzy %v) found %v, want %v", test.query, test.fuzzy, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("Search(%q, fuzzy %v) found %v, want %v", test.query, test.fuzzy, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestSearchLimit(t *testing.T) {
+	if hits := testIndex().Search("kettle", 1, false); len(hits) != 1 || hits[0].ItemId != "kettle" {
+		t.Errorf("found %v, want only the kettle", hitIds(hits))
+	}
+}
+
+func TestSearchHighlights(t *testing.T) {
+	index := NewIndex()
+	index.Add(&pb.InventoryItem{
+		Id:          "kettle",
+		Name:        "Kettle <large>",
+		Description: "One two three four five six seven eight nine ten eleven twelve kettle thirteen fourteen",
+	})
+	hits := index.Search("kettle", 10, false)
+	if len(hits) != 1 || len(hits[0].Highlights) != 2 {
+		t.Fatalf("found %v", hits)
+	}
+	name, description := hits[0].Highlights[0], hits[0].Highlights[1]
+	if name.Field != "name" || name.Snippet != "<em>Kettle</em> &lt;large&gt;" {
+		t.Errorf("name highlighted as %q", name.Snippet)
+	}
+	want := "…ten eleven twelve <em>kettle</em> thirteen fourteen"
+	if description.Field != "description" || description.Snippet != want {
+		t.Errorf("description highlighted as %q, want %q", description.Snippet, want)
+	}
+}
+
+func TestIndexUpdates(t *testing.T) {
+	index := testIndex()
+	index.Add(&pb.InventoryItem{Id: "mug", Name: "Enamel cup"})
+	if hits := index.Search("mug", 10, false); len(hits) != 0 {
+		t.Errorf("found the old version of an item: %v", hitIds(hits))
+	}
+	index.Remove("kettle")
+	if hits := index.Search("kettle", 10, false); len(hits) != 0 {
+		t.Errorf("found a removed item: %v", hitIds(hits))
+	}
+	rebuilt := NewIndex()
+	rebuilt.Add(&pb.InventoryItem{Id: "bowl", Name: "Bowl"})
+	index.Replace(rebuilt)
+	if got := hitIds(index.Search("bowl teapot", 10, false)); len(got) != 1 || got[0] != "bowl" {
+		t.Errorf("replaced index found %v, want only the bowl", got)
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joesjo/grpc-store/inventory/blobstore"
+	"github.com/joesjo/grpc-store/inventory/database"
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+)
+
+func (s *server) RestoreItem(ctx context.Context, req *pb.RestoreItemRequest) (*pb.RestoreItemResponse, error) {
+	if req.Id == "" {
+		return nil, &InvalidRequestError{message: "item id is required"}
+	}
+	item, err := s.store.RestoreItem(req.Id, req.ExpectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	s.index.Add(item)
+	return &pb.RestoreItemResponse{Item: item}, nil
+}
+
+func (s *server) PurgeItem(ctx context.Context, req *pb.PurgeItemRequest) (*pb.PurgeItemResponse, error) {
+	if req.Id == "" {
+		return nil, &InvalidRequestError{message: "item id is required"}
+	}
+	count, err := s.store.PurgeItem(req.Id, req.ExpectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		deleteItemBlobs(s.blobs, req.Id)
+	}
+	return &pb.PurgeItemResponse{Count: count}, nil
+}
+
+// archiveRetention reads how long archived items are kept before they are
+// purged from the ARCHIVE_RETENTION environment variable, a duration such
+// as 720h. Zero keeps them until they are purged by hand.
+func archiveRetention() time.Duration {
+	value, exists := os.LookupEnv("ARCHIVE_RETENTION")
+	if !exists || value == "" {
+		return DEFAULT_ARCHIVE_RETENTION
+	}
+	retention, err := time.ParseDuration(value)
+	if err != nil || retention < 0 {
+		log.Fatalf("Invalid ARCHIVE_RETENTION %q, expected a duration such as 720h", value)
+	}
+	return retention
+}
+
+// purgeArchivedItems periodically purges the items that have been archived
+// for longer than retention, along with their images.
+func purgeArchivedItems(store database.ItemStore, blobs blobstore.Store, retention time.Duration) {
+	for now := range time.Tick(ARCHIVE_PURGE_INTERVAL) {
+		purged, err := store.PurgeArchivedItems(now.Add(-retention))
+		for _, itemId := range purged {
+			deleteItemBlobs(blobs, itemId)
+		}
+		if err != nil {
+			log.Println("Purging archived items failed:", err)
+			continue
+		}
+		if len(purged) > 0 {
+			log.Println("Purged archived items:", len(purged))
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/joesjo/grpc-store/audit"
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const AUDIT_SERVICE_NAME = "inventory"
+
+// auditedMethod says how the audit log records calls of an RPC that writes.
+type auditedMethod struct {
+	entity string
+	// ids returns the ids of the entities a call writes. It is called with
+	// a nil response before the call, when only the ids in the request are
+	// known, and with the response after it.
+	ids func(req interface{}, resp interface{}) []string
+	// load reads an entity, for its values before and after the call. For
+	// entities the store cannot read back, result takes the value after
+	// the call from the response instead.
+	load   func(s *server, id string) (proto.Message, error)
+	result func(resp interface{}) proto.Message
+}
+
+func loadItem(s *server, id string) (proto.Message, error) {
+	return s.store.FindById(id)
+}
+
+func loadCategory(s *server, id string) (proto.Message, error) {
+	return s.store.FindCategory(id)
+}
+
+func loadLocation(s *server, id string) (proto.Message, error) {
+	return s.store.FindLocation(id)
+}
+
+func reservationResult(resp interface{}) proto.Message {
+	type reservationResponse interface {
+		GetReservation() *pb.Reservation
+	}
+	if r, ok := resp.(reservationResponse); ok && r.GetReservation() != nil {
+		return r.GetReservation()
+	}
+	return nil
+}
+
+func scheduledPriceResult(resp interface{}) proto.Message {
+	type scheduledPriceResponse interface {
+		GetScheduledPrice() *pb.ScheduledPrice
+	}
+	if r, ok := resp.(scheduledPriceResponse); ok && r.GetScheduledPrice() != nil {
+		return r.GetScheduledPrice()
+	}
+	return nil
+}
+
+// ids drops the empty and repeated ids of a call.
+func ids(values ...string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, id := range values {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// auditedMethods are the RPCs that write, by method name. Their calls are
+// recorded in the audit log and can be made idempotent. ImportItems and
+// UploadItemImage, which stream, record their writes themselves.
+var auditedMethods = map[string]auditedMethod{
+	"InsertItem": {entity: "item", load: loadItem, ids: func(req, resp interface{}) []string {
+		r, _ := resp.(*pb.InsertItemResponse)
+		return ids(r.GetItemId())
+	}},
+	"UpdateItem": {entity: "item", load: loadItem, ids: func(req, resp interface{}) []string {
+		return ids(req.(*pb.UpdateItemRequest).GetItem().GetId())
+	}},
+	"DeleteItem": {entity: "item", load: loadItem, ids: func(req, resp interface{}) []string {
+		return ids(req.(*pb.DeleteItemRequest).Id)
+	}},
+	"RestoreItem": {entity: "item", load: loadItem, ids: func(req, resp interface{}) []string {
+		return ids(req.(*pb.RestoreItemRequest).Id)
+	}},
+	"PurgeItem": {entity: "item", load: loadItem, ids: func(req, resp interface{}) []string {
+		return ids(req.(*pb.PurgeItemRequest).Id)
+	}},
+	"IncrementItemQuantity": {entity: "item", load: loadItem, ids: func(req, resp interface{}) []string {
+		return ids(req.(*pb.IncrementItemQuantityRequest).Id)
+	}},
+	"TransferStock": {entity: "item", load: loadItem, ids: func(req, resp interface{}) []string {
+		return ids(req.(*pb.TransferStockRequest).ItemId)
+	}},
+	"AddVariant": {entity: "item", load: loadItem, ids: func(req, resp interface{}) []string {
+		return ids(req.(*pb.AddVariantRequest).ItemId)
+	}},
+	"UpdateVariant": {entity: "item", load: loadItem, ids: func(req, resp interface{}) []string {
+		return ids(req.(*pb.UpdateVariantRequest).ItemId)
+	}},
+	"RemoveVariant": {entity: "item", load: loadItem, ids: func(req, resp interface{}) []string {
+		return ids(req.(*pb.RemoveVariantRequest).ItemId)
+	}},
+	"SetItemImages": {entity: "item", load: loadItem, ids: func(req, resp interface{}) []string {
+		return ids(req.(*pb.SetItemImagesRequest).ItemId)
+	}},
+	"BatchUpdateItems": {entity: "item", load: loadItem, ids: func(req, resp interface{}) []string {
+		var itemIds []string
+		for _, update := range req.(*pb.BatchUpdateItemsRequest).Requests {
+			itemIds = append(itemIds, update.GetItem().GetId())
+		}
+		return ids(itemIds...)
+	}},
+	"BatchAdjustQuantities": {entity: "item", load: loadItem, ids: func(req, resp interface{}) []string {
+		var itemIds []string
+		for _, increment := range req.(*pb.BatchAdjustQuantitiesRequest).Requests {
+			itemIds = append(itemIds, increment.GetId())
+		}
+		return ids(itemIds...)
+	}},
+	"ReserveStock": {entity: "reservation", result: reservationResult, ids: func(req, resp interface{}) []string {
+		r, _ := resp.(*pb.ReserveStockResponse)
+		return ids(r.GetReservation().GetId())
+	}},
+	"CommitReservation": {entity: "reservation", result: reservationResult, ids: func(req, resp interface{}) []string {
+		return ids(req.(*pb.CommitReservationRequest).Id)
+	}},
+	"ReleaseReservation": {entity: "reservation", result: reservationResult, ids: func(req, resp interface{}) []string {
+		return ids(req.(*pb.ReleaseReservationRequest).Id)
+	}},
+	"SchedulePrice": {entity: "price", result: scheduledPriceResult, ids: func(req, resp interface{}) []string {
+		r, _ := resp.(*pb.SchedulePriceResponse)
+		return ids(r.GetScheduledPrice().GetId())
+	}},
+	"CancelScheduledPrice": {entity: "price", result: scheduledPriceResult, ids: func(req, resp interface{}) []string {
+		return ids(req.(*pb.CancelScheduledPriceRequest).Id)
+	}},
+	"CreateCategory": {entity: "category", load: loadCategory, ids: func(req, resp interface{}) []string {
+		r, _ := resp.(*pb.CreateCategoryResponse)
+		return ids(r.GetCategory().GetId())
+	}},
+	"RenameCategory": {entity: "category", load: loadCategory, ids: func(req, resp interface{}) []string {
+		return ids(req.(*pb.RenameCategoryRequest).Id)
+	}},
+	"MoveCategory": {entity: "category", load: loadCategory, ids: func(req, resp interface{}) []string {
+		return ids(req.(*pb.MoveCategoryRequest).Id)
+	}},
+	"DeleteCategory": {entity: "category", load: loadCategory, ids: func(req, resp interface{}) []string {
+		return ids(req.(*pb.DeleteCategoryRequest).Id)
+	}},
+	"CreateLocation": {entity: "location", load: loadLocation, ids: func(req, resp interface{}) []string {
+		r, _ := resp.(*pb.CreateLocationResponse)
+		return ids(r.GetLocation().GetId())
+	}},
+	"RenameLocation": {entity: "location", load: loadLocation, ids: func(req, resp interface{}) []string {
+		return ids(req.(*pb.RenameLocationRequest).Id)
+	}},
+	"DeleteLocation": {entity: "location", load: loadLocation, ids: func(req, resp interface{}) []string {
+		return ids(req.(*pb.DeleteLocationRequest).Id)
+	}},
+}
+
+// snapshot returns an entity as JSON, or an empty string when it does not
+// exist.
+func snapshot(message proto.Message, err error) string {
+	if err != nil || message == nil {
+		return ""
+	}
+	data, err := protojson.Marshal(message)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// auditCalls records the calls of the audited RPCs in the audit log, one
+// entry per entity written. The values before and after are read around
+// the call, so a write made at the same time by another call may show in
+// them.
+func (s *server) auditCalls(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	action := path.Base(info.FullMethod)
+	method, ok := auditedMethods[action]
+	if !ok {
+		return handler(ctx, req)
+	}
+	before := make(map[string]string)
+	if method.load != nil {
+		for _, id := range method.ids(req, nil) {
+			before[id] = snapshot(method.load(s, id))
+		}
+	}
+	resp, err := handler(ctx, req)
+	written := method.ids(req, nil)
+	if err == nil {
+		written = method.ids(req, resp)
+	}
+	if len(written) == 0 {
+		written = []string{""}
+	}
+	for _, id := range written {
+		entry := audit.NewEntry(ctx, AUDIT_SERVICE_NAME, action, method.entity, id)
+		entry.Before = before[id]
+		switch {
+		case err != nil:
+			entry.Error = err.Error()
+		case method.load != nil:
+			entry.After = snapshot(method.load(s, id))
+		default:
+			entry.After = snapshot(method.result(resp), nil)
+		}
+		s.recordAudit(entry)
+	}
+	return resp, err
+}
+
+func (s *server) recordAudit(entry *audit.Entry) {
+	if err := s.auditLog.Record(entry); err != nil {
+		log.Println("Recording audit entry failed:", entry.Action, entry.EntityId, err)
+	}
+}
+
+// adminUsers reads the users allowed to query the audit log from the
+// comma-separated ADMIN_USERS environment variable.
+func adminUsers() map[string]bool {
+	admins := make(map[string]bool)
+	for _, username := range strings.Split(os.Getenv("ADMIN_USERS"), ",") {
+		if username = strings.TrimSpace(username); username != "" {
+			admins[username] = true
+		}
+	}
+	return admins
+}
+
+func (s *server) QueryAuditLog(ctx context.Context, req *pb.QueryAuditLogRequest) (*pb.QueryAuditLogResponse, error) {
+	if user := actor(ctx); user == "" || !adminUsers()[user] {
+		return nil, status.Errorf(codes.PermissionDenied, "the audit log is open to admin users only")
+	}
+	page, err := pageFromRequest(req.PageSize, req.PageToken)
+	if err != nil {
+		return nil, err
+	}
+	query := audit.Query{
+		Actor:      req.Actor,
+		EntityType: req.EntityType,
+		EntityId:   req.EntityId,
+		Limit:      int(page.Size),
+		PageToken:  page.Token,
+	}
+	if from := req.Time.GetFrom(); from != nil {
+		query.From = from.AsTime()
+	}
+	if to := req.Time.GetTo(); to != nil {
+		query.To = to.AsTime()
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return nil, &InvalidRequestError{message: "time range must start before it ends"}
+	}
+	entries, nextPageToken, err := s.auditLog.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	response := &pb.QueryAuditLogResponse{NextPageToken: nextPageToken}
+	for _, entry := range entries {
+		response.Entries = append(response.Entries, auditEntryToProto(entry))
+	}
+	return response, nil
+}
+
+func auditEntryToProto(entry *audit.Entry) *pb.AuditEntry {
+	return &pb.AuditEntry{
+		Id:                 entry.Id,
+		Time:               timestamppb.New(entry.Time),
+		Service:            entry.Service,
+		Action:             entry.Action,
+		Actor:              entry.Actor,
+		Client:             entry.Client,
+		UserAgent:          entry.UserAgent,
+		ForwardedFor:       entry.ForwardedFor,
+		ForwardedUserAgent: entry.ForwardedUserAgent,
+		EntityType:         entry.EntityType,
+		EntityId:           entry.EntityId,
+		Before:             entry.Before,
+		After:              entry.After,
+		Error:              entry.Error,
+	}
+}
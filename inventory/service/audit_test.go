@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestAuditCalls(t *testing.T) {
+	t.Setenv("ADMIN_USERS", "admin")
+	s := newTestServer(t)
+	client := dial(t, s)
+	ctx := asUser(t, "alice")
+	inserted, err := client.InsertItem(ctx, &pb.InsertItemRequest{Item: &pb.InventoryItem{Name: "Mug", Quantity: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	update := &pb.UpdateItemRequest{
+		Item:       &pb.InventoryItem{Id: inserted.ItemId, Name: "Big mug"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name"}},
+	}
+	if _, err := client.UpdateItem(ctx, update); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.IncrementItemQuantity(ctx, &pb.IncrementItemQuantityRequest{Id: inserted.ItemId, Amount: -5})
+	checkCode(t, err, codes.FailedPrecondition)
+
+	res, err := client.QueryAuditLog(asUser(t, "admin"), &pb.QueryAuditLogRequest{EntityType: "item", EntityId: inserted.ItemId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(res.Entries))
+	}
+	failed, updated, created := res.Entries[0], res.Entries[1], res.Entries[2]
+	for _, entry := range res.Entries {
+		if entry.Actor != "alice" || entry.Service != AUDIT_SERVICE_NAME || entry.Client == "" {
+			t.Errorf("entry %s recorded actor %q, service %q and client %q", entry.Action, entry.Actor, entry.Service, entry.Client)
+		}
+	}
+	if created.Action != "InsertItem" || created.Before != "" || !strings.Contains(created.After, `"Mug"`) {
+		t.Errorf("insert recorded as %v", created)
+	}
+	if updated.Action != "UpdateItem" || !strings.Contains(updated.Before, `"Mug"`) || !strings.Contains(updated.After, `"Big mug"`) {
+		t.Errorf("update recorded as %v", updated)
+	}
+	if failed.Action != "IncrementItemQuantity" || failed.Error == "" || failed.After != "" {
+		t.Errorf("failed call recorded as %v", failed)
+	}
+}
+
+func TestQueryAuditLogIsForAdmins(t *testing.T) {
+	t.Setenv("ADMIN_USERS", "admin")
+	s := newTestServer(t)
+	client := dial(t, s)
+	_, err := client.QueryAuditLog(asUser(t, "alice"), &pb.QueryAuditLogRequest{})
+	checkCode(t, err, codes.PermissionDenied)
+	_, err = client.QueryAuditLog(context.Background(), &pb.QueryAuditLogRequest{})
+	checkCode(t, err, codes.PermissionDenied)
+}
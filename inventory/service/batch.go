@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/joesjo/grpc-store/inventory/database"
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+)
+
+func checkBatchSize(size int) error {
+	if size == 0 {
+		return &InvalidRequestError{message: "batch is empty"}
+	}
+	if size > MAX_BATCH_SIZE {
+		return &InvalidRequestError{message: fmt.Sprintf("batch has %d requests, at most %d are allowed", size, MAX_BATCH_SIZE)}
+	}
+	return nil
+}
+
+// batchRequestError says which request of a batch an invalid request error
+// is about. Other errors name the item they are about already.
+func batchRequestError(index int, err error) error {
+	if invalid, ok := err.(*InvalidRequestError); ok {
+		return &InvalidRequestError{message: fmt.Sprintf("request %d: %s", index, invalid.message)}
+	}
+	return err
+}
+
+func (s *server) BatchGetItems(ctx context.Context, req *pb.BatchGetItemsRequest) (*pb.BatchGetItemsResponse, error) {
+	if err := checkBatchSize(len(req.Ids)); err != nil {
+		return nil, err
+	}
+	items, err := s.store.FindByIds(req.Ids)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.BatchGetItemsResponse{Items: items}, nil
+}
+
+func (s *server) BatchUpdateItems(ctx context.Context, req *pb.BatchUpdateItemsRequest) (*pb.BatchUpdateItemsResponse, error) {
+	if err := checkBatchSize(len(req.Requests)); err != nil {
+		return nil, err
+	}
+	updates := make([]database.ItemUpdate, len(req.Requests))
+	for i, update := range req.Requests {
+		if err := s.checkUpdate(update); err != nil {
+			return nil, batchRequestError(i, err)
+		}
+		updates[i] = database.ItemUpdate{
+			Item:            update.Item,
+			Paths:           update.UpdateMask.GetPaths(),
+			ExpectedVersion: update.ExpectedVersion,
+		}
+	}
+	items, err := s.store.BatchUpdateItems(updates, stockChange(ctx, pb.MovementReason_MOVEMENT_ADJUSTMENT))
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		s.index.Add(item)
+	}
+	return &pb.BatchUpdateItemsResponse{Items: items}, nil
+}
+
+func (s *server) BatchAdjustQuantities(ctx context.Context, req *pb.BatchAdjustQuantitiesRequest) (*pb.BatchAdjustQuantitiesResponse, error) {
+	if err := checkBatchSize(len(req.Requests)); err != nil {
+		return nil, err
+	}
+	adjustments := make([]database.QuantityAdjustment, len(req.Requests))
+	for i, increment := range req.Requests {
+		if increment.Id == "" {
+			return nil, batchRequestError(i, &InvalidRequestError{message: "item id is required"})
+		}
+		reason, err := incrementReason(increment.Reason, increment.Amount)
+		if err != nil {
+			return nil, batchRequestError(i, err)
+		}
+		if err := s.checkLocation(increment.LocationId); err != nil {
+			return nil, batchRequestError(i, err)
+		}
+		adjustments[i] = database.QuantityAdjustment{
+			ItemId:          increment.Id,
+			VariantId:       increment.VariantId,
+			LocationId:      increment.LocationId,
+			Quantity:        increment.Amount,
+			AllowBackorder:  increment.AllowBackorder,
+			ExpectedVersion: increment.ExpectedVersion,
+			Change:          stockChange(ctx, reason),
+		}
+	}
+	items, err := s.store.BatchAdjustQuantities(adjustments)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.BatchAdjustQuantitiesResponse{Items: items}, nil
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestBatchUpdateItems(t *testing.T) {
+	s := newTestServer(t)
+	mug := insertItem(t, s, &pb.InventoryItem{Name: "Mug", Quantity: 5})
+	cup := insertItem(t, s, &pb.InventoryItem{Name: "Cup", Quantity: 2})
+	names := &fieldmaskpb.FieldMask{Paths: []string{"name"}}
+	res, err := s.BatchUpdateItems(context.Background(), &pb.BatchUpdateItemsRequest{Requests: []*pb.UpdateItemRequest{
+		{Item: &pb.InventoryItem{Id: mug.Id, Name: "Enamel mug"}, UpdateMask: names},
+		{Item: &pb.InventoryItem{Id: cup.Id, Name: "Paper cup"}, UpdateMask: names},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Items) != 2 || res.Items[0].Name != "Enamel mug" || res.Items[1].Name != "Paper cup" {
+		t.Errorf("batch returned %v", res.Items)
+	}
+	// The search index follows the batch.
+	if hits := s.index.Search("enamel", 10, false); len(hits) != 1 || hits[0].ItemId != mug.Id {
+		t.Errorf("search found %v", hits)
+	}
+}
+
+func TestBatchUpdateItemsAllOrNothing(t *testing.T) {
+	s := newTestServer(t)
+	mug := insertItem(t, s, &pb.InventoryItem{Name: "Mug", Quantity: 5})
+	_, err := s.BatchUpdateItems(context.Background(), &pb.BatchUpdateItemsRequest{Requests: []*pb.UpdateItemRequest{
+		{Item: &pb.InventoryItem{Id: mug.Id, Name: "Enamel mug"}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name"}}},
+		{Item: &pb.InventoryItem{Id: "000000000000000000000000", Name: "Cup"}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name"}}},
+	}})
+	checkCode(t, err, codes.NotFound)
+	if item, _ := s.store.FindById(mug.Id); item.Name != "Mug" {
+		t.Errorf("failed batch renamed the item to %q", item.Name)
+	}
+}
+
+func TestBatchAdjustQuantities(t *testing.T) {
+	s := newTestServer(t)
+	mug := insertItem(t, s, &pb.InventoryItem{Name: "Mug", Quantity: 5})
+	res, err := s.BatchAdjustQuantities(context.Background(), &pb.BatchAdjustQuantitiesRequest{Requests: []*pb.IncrementItemQuantityRequest{
+		{Id: mug.Id, Amount: 3},
+		{Id: mug.Id, Amount: -8},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Items) != 2 || res.Items[1].Quantity != 0 {
+		t.Errorf("batch returned %v", res.Items)
+	}
+	// Each adjustment sees the ones before it, so this one oversells.
+	_, err = s.BatchAdjustQuantities(context.Background(), &pb.BatchAdjustQuantitiesRequest{Requests: []*pb.IncrementItemQuantityRequest{
+		{Id: mug.Id, Amount: 1},
+		{Id: mug.Id, Amount: -2},
+	}})
+	checkCode(t, err, codes.FailedPrecondition)
+	if item, _ := s.store.FindById(mug.Id); item.Quantity != 0 {
+		t.Errorf("failed batch left quantity %d, want 0", item.Quantity)
+	}
+}
+
+func TestBatchRequestErrors(t *testing.T) {
+	s := newTestServer(t)
+	mug := insertItem(t, s, &pb.InventoryItem{Name: "Mug", Quantity: 5})
+	tests := []struct {
+		name     string
+		requests []*pb.IncrementItemQuantityRequest
+		want     string
+	}{
+		{"empty", nil, "batch is empty"},
+		{"too large", make([]*pb.IncrementItemQuantityRequest, MAX_BATCH_SIZE+1), "at most"},
+		{"invalid request", []*pb.IncrementItemQuantityRequest{{Id: mug.Id, Amount: 1}, {Amount: 1}}, "request 1: item id is required"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := s.BatchAdjustQuantities(context.Background(), &pb.BatchAdjustQuantitiesRequest{Requests: test.requests})
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want one containing %q", err, test.want)
+			}
+		})
+	}
+	if item, _ := s.store.FindById(mug.Id); item.Quantity != 5 {
+		t.Errorf("invalid batches changed the quantity to %d", item.Quantity)
+	}
+}
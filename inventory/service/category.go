@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// categoryTree indexes all categories by id and by parent id.
+type categoryTree struct {
+	categories map[string]*pb.Category
+	children   map[string][]*pb.Category
+}
+
+func (s *server) categoryTree() (*categoryTree, error) {
+	categories, err := s.store.ListCategories()
+	if err != nil {
+		return nil, err
+	}
+	tree := &categoryTree{
+		categories: make(map[string]*pb.Category),
+		children:   make(map[string][]*pb.Category),
+	}
+	for _, category := range categories {
+		tree.categories[category.Id] = category
+		tree.children[category.ParentId] = append(tree.children[category.ParentId], category)
+	}
+	return tree, nil
+}
+
+// descendants returns categoryIds together with the ids of all categories
+// below them.
+func (t *categoryTree) descendants(categoryIds []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for len(categoryIds) > 0 {
+		categoryId := categoryIds[0]
+		categoryIds = categoryIds[1:]
+		if seen[categoryId] {
+			continue
+		}
+		seen[categoryId] = true
+		result = append(result, categoryId)
+		for _, child := range t.children[categoryId] {
+			categoryIds = append(categoryIds, child.Id)
+		}
+	}
+	return result
+}
+
+// checkPlacement validates putting a category named name under parentId.
+// The category's own id is empty for new categories.
+func (t *categoryTree) checkPlacement(categoryId, name, parentId string) error {
+	if parentId != "" {
+		if _, ok := t.categories[parentId]; !ok {
+			return status.Errorf(codes.NotFound, "Could not find category with id "+parentId)
+		}
+	}
+	for ancestor := parentId; ancestor != ""; ancestor = t.categories[ancestor].GetParentId() {
+		if ancestor == categoryId {
+			return &InvalidRequestError{message: "a category cannot be moved below itself"}
+		}
+	}
+	for _, sibling := range t.children[parentId] {
+		if sibling.Id != categoryId && strings.EqualFold(sibling.Name, name) {
+			return status.Errorf(codes.AlreadyExists, "category %q already exists", name)
+		}
+	}
+	return nil
+}
+
+// checkCategories validates the category assignments of an item.
+func (s *server) checkCategories(item *pb.InventoryItem) error {
+	for _, categoryId := range item.GetCategoryIds() {
+		if _, err := s.store.FindCategory(categoryId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandSubcategories replaces the categories of a filter that includes
+// subcategories with the categories and all their descendants.
+func (s *server) expandSubcategories(filter *pb.ItemFilter) error {
+	if !filter.IncludeSubcategories || len(filter.CategoryIds) == 0 {
+		return nil
+	}
+	tree, err := s.categoryTree()
+	if err != nil {
+		return err
+	}
+	filter.CategoryIds = tree.descendants(filter.CategoryIds)
+	filter.IncludeSubcategories = false
+	return nil
+}
+
+func (s *server) ListCategories(req *pb.ListCategoriesRequest, stream pb.InventoryService_ListCategoriesServer) error {
+	categories, err := s.store.ListCategories()
+	if err != nil {
+		return err
+	}
+	for _, category := range categories {
+		if (req.Root || req.ParentId != "") && category.ParentId != req.ParentId {
+			continue
+		}
+		if err := stream.Send(category); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *server) GetCategory(ctx context.Context, req *pb.GetCategoryRequest) (*pb.GetCategoryResponse, error) {
+	if req.Id == "" {
+		return nil, &InvalidRequestError{message: "category id is required"}
+	}
+	category, err := s.store.FindCategory(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetCategoryResponse{Category: category}, nil
+}
+
+func (s *server) CreateCategory(ctx context.Context, req *pb.CreateCategoryRequest) (*pb.CreateCategoryResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, &InvalidRequestError{message: "category name is required"}
+	}
+	tree, err := s.categoryTree()
+	if err != nil {
+		return nil, err
+	}
+	if err := tree.checkPlacement("", name, req.ParentId); err != nil {
+		return nil, err
+	}
+	category, err := s.store.InsertCategory(&pb.Category{Name: name, ParentId: req.ParentId})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CreateCategoryResponse{Category: category}, nil
+}
+
+func (s *server) RenameCategory(ctx context.Context, req *pb.RenameCategoryRequest) (*pb.RenameCategoryResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if req.Id == "" {
+		return nil, &InvalidRequestError{message: "category id is required"}
+	}
+	if name == "" {
+		return nil, &InvalidRequestError{message: "category name is required"}
+	}
+	tree, err := s.categoryTree()
+	if err != nil {
+		return nil, err
+	}
+	existing, ok := tree.categories[req.Id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Could not find category with id "+req.Id)
+	}
+	if err := tree.checkPlacement(req.Id, name, existing.ParentId); err != nil {
+		return nil, err
+	}
+	category, err := s.store.UpdateCategory(&pb.Category{Id: req.Id, Name: name, ParentId: existing.ParentId})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.RenameCategoryResponse{Category: category}, nil
+}
+
+func (s *server) MoveCategory(ctx context.Context, req *pb.MoveCategoryRequest) (*pb.MoveCategoryResponse, error) {
+	if req.Id == "" {
+		return nil, &InvalidRequestError{message: "category id is required"}
+	}
+	tree, err := s.categoryTree()
+	if err != nil {
+		return nil, err
+	}
+	existing, ok := tree.categories[req.Id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Could not find category with id "+req.Id)
+	}
+	if err := tree.checkPlacement(req.Id, existing.Name, req.ParentId); err != nil {
+		return nil, err
+	}
+	category, err := s.store.UpdateCategory(&pb.Category{Id: req.Id, Name: existing.Name, ParentId: req.ParentId})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.MoveCategoryResponse{Category: category}, nil
+}
+
+func (s *server) DeleteCategory(ctx context.Context, req *pb.DeleteCategoryRequest) (*pb.DeleteCategoryResponse, error) {
+	if req.Id == "" {
+		return nil, &InvalidRequestError{message: "category id is required"}
+	}
+	tree, err := s.categoryTree()
+	if err != nil {
+		return nil, err
+	}
+	if len(tree.children[req.Id]) > 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "category %s has subcategories", req.Id)
+	}
+	count, err := s.store.DeleteCategory(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.DeleteCategoryResponse{Count: count}, nil
+}
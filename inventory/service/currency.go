@@ -0,0 +1,60 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+)
+
+// currencies holds the active ISO-4217 currency codes.
+var currencies = map[string]bool{}
+
+func init() {
+	for _, code := range strings.Fields(`
+		AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND
+		BOB BOV BRL BSD BTN BWP BYN BZD CAD CDF CHE CHF CHW CLF CLP CNY COP COU
+		CRC CUC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS
+		GIP GMD GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY
+		KES KGS KHR KMF KPW KRW KWD KYD KZT LAK LBP LKR LRD LSL LYD MAD MDL MGA
+		MKD MMK MNT MOP MRU MUR MVR MWK MXN MXV MYR MZN NAD NGN NIO NOK NPR NZD
+		OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD RUB RWF SAR SBD SCR SDG SEK
+		SGD SHP SLE SLL SOS SRD SSP STN SVC SYP SZL THB TJS TMT TND TOP TRY TTD
+		TWD TZS UAH UGX USD USN UYI UYU UYW UZS VED VES VND VUV WST XAF XAG XAU
+		XBA XBB XBC XBD XCD XDR XOF XPD XPF XPT XSU XTS XUA YER ZAR ZMW ZWL
+	`) {
+		currencies[code] = true
+	}
+}
+
+// validateMoney checks that money, if set, is a non-negative amount in a
+// known currency. The field name is used in error messages.
+func validateMoney(field string, money *pb.Money) error {
+	if money == nil {
+		return nil
+	}
+	if money.Amount < 0 {
+		return &InvalidRequestError{message: field + " must not be negative"}
+	}
+	if !currencies[money.Currency] {
+		return &InvalidRequestError{message: "unknown currency " + strconv.Quote(money.Currency) + " in " + field}
+	}
+	return nil
+}
+
+// validatePricing checks the price fields of an item.
+func validatePricing(item *pb.InventoryItem) error {
+	if err := validateMoney("price", item.GetPrice()); err != nil {
+		return err
+	}
+	if err := validateMoney("compare-at price", item.GetCompareAtPrice()); err != nil {
+		return err
+	}
+	if item.GetPrice() != nil && item.GetCompareAtPrice() != nil && item.Price.Currency != item.CompareAtPrice.Currency {
+		return &InvalidRequestError{message: "compare-at price must be in the same currency as the price"}
+	}
+	if _, ok := pb.TaxClass_name[int32(item.GetTaxClass())]; !ok {
+		return &InvalidRequestError{message: "unknown tax class"}
+	}
+	return nil
+}
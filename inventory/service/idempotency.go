@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"os"
+	"path"
+	"time"
+
+	"github.com/joesjo/grpc-store/inventory/database"
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+func idempotencyKey(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if keys := md.Get(pb.IdempotencyKeyMetadataKey); len(keys) > 0 {
+			return keys[0]
+		}
+	}
+	return ""
+}
+
+// idempotencyWindow reads how long the responses of calls made with an
+// idempotency key are kept for replay from the IDEMPOTENCY_WINDOW
+// environment variable, a duration such as 24h.
+func idempotencyWindow() time.Duration {
+	value, exists := os.LookupEnv("IDEMPOTENCY_WINDOW")
+	if !exists || value == "" {
+		return DEFAULT_IDEMPOTENCY_WINDOW
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil || window <= 0 {
+		log.Fatalf("Invalid IDEMPOTENCY_WINDOW %q, expected a positive duration such as 24h", value)
+	}
+	return window
+}
+
+// requestHash tells apart the calls made with the same idempotency key.
+func requestHash(action string, req interface{}) (string, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(req.(proto.Message))
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	hash.Write([]byte(action))
+	hash.Write([]byte{0})
+	hash.Write(data)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// replayIdempotent makes the RPCs that write idempotent for clients that
+// pass an idempotency key: the first call with a key runs and its response
+// is kept for the idempotency window, and later calls with the key get that
+// response back without running again. Calls that fail are forgotten, so
+// that they can be retried. Using a key for a different request, or while
+// the first call with it is still running, fails. Keys are kept per user of
+// the verified bearer token, so signed-in users cannot replay each other's
+// responses; anonymous calls share their keys.
+//
+// A key is claimed for the whole window up front. Should the call succeed
+// but its response not be stored, or the service stop while it runs, the
+// write may have happened, so the key stays claimed rather than let a retry
+// write again; retries fail as if the call were still running.
+func (s *server) replayIdempotent(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	action := path.Base(info.FullMethod)
+	key := idempotencyKey(ctx)
+	if _, ok := auditedMethods[action]; !ok || key == "" {
+		return handler(ctx, req)
+	}
+	if len(key) > MAX_IDEMPOTENCY_KEY_LENGTH {
+		return nil, &InvalidRequestError{message: "idempotency key is too long"}
+	}
+	hash, err := requestHash(action, req)
+	if err != nil {
+		return nil, err
+	}
+	key = actor(ctx) + "/" + key
+	call, err := s.store.ClaimIdempotencyKey(key, hash, time.Now().Add(s.idempotencyWindow))
+	if err != nil {
+		return nil, err
+	}
+	if call != nil {
+		return replay(ctx, call, hash)
+	}
+	resp, err := handler(ctx, req)
+	if err != nil {
+		if err := s.store.ReleaseIdempotencyKey(key); err != nil {
+			log.Println("Releasing idempotency key failed:", key, err)
+		}
+		return nil, err
+	}
+	message := resp.(proto.Message)
+	data, err := proto.Marshal(message)
+	if err == nil {
+		responseType := string(message.ProtoReflect().Descriptor().FullName())
+		err = s.store.CompleteIdempotencyKey(key, responseType, data, time.Now().Add(s.idempotencyWindow))
+	}
+	if err != nil {
+		log.Println("Storing idempotent response failed:", key, err)
+	}
+	return resp, nil
+}
+
+// replay returns the response of an earlier call made with the same
+// idempotency key.
+func replay(ctx context.Context, call *database.IdempotentCall, hash string) (interface{}, error) {
+	if call.RequestHash != hash {
+		return nil, &InvalidRequestError{message: "idempotency key was used for a different request"}
+	}
+	if !call.Done {
+		return nil, status.Errorf(codes.Aborted, "a call with the same idempotency key is still running or did not complete")
+	}
+	messageType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(call.ResponseType))
+	if err != nil {
+		return nil, err
+	}
+	resp := messageType.New().Interface()
+	if err := proto.Unmarshal(call.Response, resp); err != nil {
+		return nil, err
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(pb.IdempotentReplayMetadataKey, "true"))
+	return resp, nil
+}
+
+func expireIdempotencyKeys(store database.ItemStore) {
+	for now := range time.Tick(IDEMPOTENCY_SWEEP_INTERVAL) {
+		count, err := store.ExpireIdempotencyKeys(now)
+		if err != nil {
+			log.Println("Expiring idempotency keys failed:", err)
+			continue
+		}
+		if count > 0 {
+			log.Println("Expired idempotency keys:", count)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/joesjo/grpc-store/inventory/database"
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func withIdempotencyKey(ctx context.Context, key string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, pb.IdempotencyKeyMetadataKey, key)
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	s := newTestServer(t)
+	client := dial(t, s)
+	mug := insertItem(t, s, &pb.InventoryItem{Name: "Mug", Quantity: 5})
+	ctx := withIdempotencyKey(asUser(t, "alice"), "receive-1")
+	req := &pb.IncrementItemQuantityRequest{Id: mug.Id, Amount: 2}
+	for i, wantReplayed := range []bool{false, true, true} {
+		var header metadata.MD
+		res, err := client.IncrementItemQuantity(ctx, req, grpc.Header(&header))
+		if err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		if res.Count != 1 {
+			t.Errorf("call %d: count %d, want 1", i, res.Count)
+		}
+		if replayed := len(header.Get(pb.IdempotentReplayMetadataKey)) > 0; replayed != wantReplayed {
+			t.Errorf("call %d: replayed %v, want %v", i, replayed, wantReplayed)
+		}
+	}
+	item, err := s.store.FindById(mug.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Quantity != 7 {
+		t.Errorf("quantity %d after retries, want 7", item.Quantity)
+	}
+}
+
+func TestIdempotencyKeyReusedForAnotherRequest(t *testing.T) {
+	s := newTestServer(t)
+	client := dial(t, s)
+	mug := insertItem(t, s, &pb.InventoryItem{Name: "Mug", Quantity: 5})
+	ctx := withIdempotencyKey(asUser(t, "alice"), "receive-1")
+	if _, err := client.IncrementItemQuantity(ctx, &pb.IncrementItemQuantityRequest{Id: mug.Id, Amount: 2}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.IncrementItemQuantity(ctx, &pb.IncrementItemQuantityRequest{Id: mug.Id, Amount: 3})
+	if err == nil || !strings.Contains(err.Error(), "different request") {
+		t.Errorf("got error %v for a key used for another request", err)
+	}
+	if item, _ := s.store.FindById(mug.Id); item.Quantity != 7 {
+		t.Errorf("quantity %d, want 7", item.Quantity)
+	}
+}
+
+func TestIdempotencyKeysPerUser(t *testing.T) {
+	s := newTestServer(t)
+	client := dial(t, s)
+	mug := insertItem(t, s, &pb.InventoryItem{Name: "Mug", Quantity: 5})
+	req := &pb.IncrementItemQuantityRequest{Id: mug.Id, Amount: 2}
+	for _, username := range []string{"alice", "bob"} {
+		var header metadata.MD
+		ctx := withIdempotencyKey(asUser(t, username), "receive-1")
+		if _, err := client.IncrementItemQuantity(ctx, req, grpc.Header(&header)); err != nil {
+			t.Fatal(err)
+		}
+		if len(header.Get(pb.IdempotentReplayMetadataKey)) > 0 {
+			t.Errorf("replayed the response of another user to %s", username)
+		}
+	}
+	if item, _ := s.store.FindById(mug.Id); item.Quantity != 9 {
+		t.Errorf("quantity %d, want 9", item.Quantity)
+	}
+}
+
+func TestIdempotentCallFailureIsForgotten(t *testing.T) {
+	s := newTestServer(t)
+	client := dial(t, s)
+	mug := insertItem(t, s, &pb.InventoryItem{Name: "Mug", Quantity: 1})
+	ctx := withIdempotencyKey(asUser(t, "alice"), "sell-1")
+	req := &pb.IncrementItemQuantityRequest{Id: mug.Id, Amount: -3}
+	_, err := client.IncrementItemQuantity(ctx, req)
+	checkCode(t, err, codes.FailedPrecondition)
+	if _, err := s.store.IncrementItemQuantity(mug.Id, "", "", 5, false, 0, database.StockChange{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.IncrementItemQuantity(ctx, req); err != nil {
+		t.Errorf("retry after a failed call: %v", err)
+	}
+}
+
+func TestIdempotencyKeyTooLong(t *testing.T) {
+	s := newTestServer(t)
+	client := dial(t, s)
+	mug := insertItem(t, s, &pb.InventoryItem{Name: "Mug", Quantity: 1})
+	ctx := withIdempotencyKey(context.Background(), strings.Repeat("k", MAX_IDEMPOTENCY_KEY_LENGTH+1))
+	_, err := client.IncrementItemQuantity(ctx, &pb.IncrementItemQuantityRequest{Id: mug.Id, Amount: 1})
+	if err == nil || !strings.Contains(err.Error(), "too long") {
+		t.Errorf("got error %v for a key that is too long", err)
+	}
+}
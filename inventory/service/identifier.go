@@ -0,0 +1,51 @@
+package service
+
+import (
+	"strings"
+	"unicode"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+)
+
+const MAX_SKU_LENGTH = 64
+
+// validateIdentifiers checks the sku and barcode of an item, if set.
+func validateIdentifiers(item *pb.InventoryItem) error {
+	if sku := item.GetSku(); sku != "" {
+		if len(sku) > MAX_SKU_LENGTH {
+			return &InvalidRequestError{message: "sku must not be longer than 64 characters"}
+		}
+		if strings.IndexFunc(sku, unicode.IsSpace) >= 0 {
+			return &InvalidRequestError{message: "sku must not contain whitespace"}
+		}
+	}
+	if barcode := item.GetBarcode(); barcode != "" && !validGtin(barcode) {
+		return &InvalidRequestError{message: "barcode " + barcode + " is not a valid GTIN"}
+	}
+	return nil
+}
+
+// validGtin reports whether code is a GTIN-8, -12, -13 or -14 with a correct
+// check digit. Digits are weighted 3 and 1 alternately from the right, not
+// counting the check digit, and the check digit brings the sum to a multiple
+// of ten.
+func validGtin(code string) bool {
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return false
+	}
+	sum := 0
+	for i := len(code) - 1; i >= 0; i-- {
+		digit := code[i]
+		if digit < '0' || digit > '9' {
+			return false
+		}
+		weight := 1
+		if (len(code)-1-i)%2 == 1 {
+			weight = 3
+		}
+		sum += int(digit-'0') * weight
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"io"
+
+	"github.com/joesjo/grpc-store/audit"
+	"github.com/joesjo/grpc-store/inventory/database"
+	"github.com/joesjo/grpc-store/inventory/itemio"
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *server) ImportItems(stream pb.InventoryService_ImportItemsServer) error {
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return &InvalidRequestError{message: "import is empty"}
+	}
+	if err != nil {
+		return err
+	}
+	reader, err := itemio.NewReader(first.Format, &importReader{stream: stream, data: first.Data})
+	if err != nil {
+		return &InvalidRequestError{message: err.Error()}
+	}
+	response := &pb.ImportItemsResponse{DryRun: first.DryRun}
+	// The SKUs created so far. A dry run writes nothing, so it counts later
+	// rows with these SKUs as updates from here.
+	created := map[string]bool{}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Errors of the stream carry their own status, those of the
+			// document do not.
+			if status.Code(err) != codes.Unknown {
+				return err
+			}
+			return &InvalidRequestError{message: err.Error()}
+		}
+		if row.Err == nil {
+			row.Err = s.importRow(stream.Context(), row, first.DryRun, created, response)
+		}
+		if row.Err != nil {
+			response.Failed++
+			if len(response.Errors) < MAX_IMPORT_ERRORS {
+				response.Errors = append(response.Errors, &pb.ImportError{
+					Line:    int32(row.Line),
+					Sku:     row.Item.Sku,
+					Message: importErrorMessage(row.Err),
+				})
+			}
+		}
+	}
+	return stream.SendAndClose(response)
+}
+
+// importRow creates the item of a row or updates the item with its SKU,
+// and counts the outcome in response.
+func (s *server) importRow(ctx context.Context, row *itemio.Row, dryRun bool, created map[string]bool, response *pb.ImportItemsResponse) error {
+	item := row.Item
+	if item.Sku == "" {
+		return &InvalidRequestError{message: "sku is required"}
+	}
+	if err := validatePricing(item); err != nil {
+		return err
+	}
+	if err := validateIdentifiers(item); err != nil {
+		return err
+	}
+	if err := validateAttributes(item); err != nil {
+		return err
+	}
+	if err := validateReorder(item); err != nil {
+		return err
+	}
+	if err := s.checkCategories(item); err != nil {
+		return err
+	}
+	existing, err := s.store.FindBySku(item.Sku)
+	if status.Code(err) == codes.NotFound {
+		existing, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Sku != item.Sku {
+		return status.Errorf(codes.AlreadyExists, "sku %s belongs to a variant of item %s", item.Sku, existing.Id)
+	}
+	if existing == nil && !created[item.Sku] {
+		if item.Name == "" {
+			return &InvalidRequestError{message: "name is required for new items"}
+		}
+		if dryRun {
+			if err := s.checkImportRow(row, nil); err != nil {
+				return err
+			}
+		} else {
+			id, err := s.store.InsertItem(item, stockChange(ctx, pb.MovementReason_MOVEMENT_RECEIVE))
+			if err != nil {
+				return err
+			}
+			s.reindex(id)
+			s.auditItemWrite(ctx, "ImportItems", id, nil)
+		}
+		created[item.Sku] = true
+		response.Created++
+		return nil
+	}
+	if existing != nil && len(existing.Variants) > 0 && containsPath(row.Paths, "quantity") {
+		return &InvalidRequestError{message: "the quantity of an item with variants is the sum over its variants"}
+	}
+	if dryRun {
+		if err := s.checkImportRow(row, existing); err != nil {
+			return err
+		}
+	} else {
+		item.Id = existing.Id
+		if _, err := s.store.UpdateItem(item, row.Paths, 0, stockChange(ctx, pb.MovementReason_MOVEMENT_ADJUSTMENT)); err != nil {
+			return err
+		}
+		s.reindex(item.Id)
+		s.auditItemWrite(ctx, "ImportItems", item.Id, existing)
+	}
+	response.Updated++
+	return nil
+}
+
+// checkImportRow makes the checks the store makes when it writes a row,
+// for dry runs, which write nothing, to report the rows an import would
+// fail. existing is the item the row updates, or nil for new items and for
+// items created by earlier rows of the dry run.
+func (s *server) checkImportRow(row *itemio.Row, existing *pb.InventoryItem) error {
+	item := row.Item
+	if existing != nil {
+		if err := database.CheckItemUpdate(existing, item, row.Paths); err != nil {
+			return err
+		}
+	}
+	if item.Barcode == "" || (existing != nil && !containsPath(row.Paths, "barcode")) {
+		return nil
+	}
+	other, err := s.store.FindByBarcode(item.Barcode)
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing == nil || other.Id != existing.Id {
+		return status.Errorf(codes.AlreadyExists, "an item with barcode %s already exists", item.Barcode)
+	}
+	return nil
+}
+
+// auditItemWrite records an item written by a streaming RPC, which the
+// audit interceptor does not see, in the audit log. Calls and import rows
+// that fail are left out, as they write nothing.
+func (s *server) auditItemWrite(ctx context.Context, action string, itemId string, before *pb.InventoryItem) {
+	entry := audit.NewEntry(ctx, AUDIT_SERVICE_NAME, action, "item", itemId)
+	if before != nil {
+		entry.Before = snapshot(before, nil)
+	}
+	entry.After = snapshot(s.store.FindById(itemId))
+	s.recordAudit(entry)
+}
+
+// importErrorMessage returns the message of a row error without the code
+// or prefix it carries as a request error.
+func importErrorMessage(err error) string {
+	if invalid, ok := err.(*InvalidRequestError); ok {
+		return invalid.message
+	}
+	return status.Convert(err).Message()
+}
+
+// importReader reads the document of an import from the chunks sent by the
+// client.
+type importReader struct {
+	stream pb.InventoryService_ImportItemsServer
+	data   []byte
+}
+
+func (r *importReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.data = req.Data
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func (s *server) ExportItems(req *pb.ExportItemsRequest, stream pb.InventoryService_ExportItemsServer) error {
+	filter, err := filterFromRequest(&pb.FindItemsRequest{Filter: req.Filter})
+	if err != nil {
+		return err
+	}
+	if err := s.expandSubcategories(filter); err != nil {
+		return err
+	}
+	chunks := bufio.NewWriterSize(exportWriter{stream: stream}, EXPORT_CHUNK_SIZE)
+	writer, err := itemio.NewWriter(req.Format, chunks)
+	if err != nil {
+		return &InvalidRequestError{message: err.Error()}
+	}
+	if _, err := s.store.ListItems(database.ItemQuery{Filter: filter}, writer.Write); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return chunks.Flush()
+}
+
+// exportWriter sends what is written to it as chunks of an export.
+type exportWriter struct {
+	stream pb.InventoryService_ExportItemsServer
+}
+
+func (w exportWriter) Write(p []byte) (int, error) {
+	// Send marshals the chunk before returning, so p may be reused after.
+	if err := w.stream.Send(&pb.ExportItemsChunk{Data: p}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/grpc"
+)
+
+// importStream sends a document to ImportItems in a single message.
+type importStream struct {
+	grpc.ServerStream
+	requests []*pb.ImportItemsRequest
+	response *pb.ImportItemsResponse
+}
+
+func (s *importStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *importStream) Recv() (*pb.ImportItemsRequest, error) {
+	if len(s.requests) == 0 {
+		return nil, io.EOF
+	}
+	req := s.requests[0]
+	s.requests = s.requests[1:]
+	return req, nil
+}
+
+func (s *importStream) SendAndClose(response *pb.ImportItemsResponse) error {
+	s.response = response
+	return nil
+}
+
+func importCSV(t *testing.T, s *server, document string, dryRun bool) *pb.ImportItemsResponse {
+	t.Helper()
+	stream := &importStream{requests: []*pb.ImportItemsRequest{{Format: pb.ItemFormat_FORMAT_CSV, DryRun: dryRun, Data: []byte(document)}}}
+	if err := s.ImportItems(stream); err != nil {
+		t.Fatal(err)
+	}
+	return stream.response
+}
+
+func TestImportDryRunPredictsImport(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		created  int32
+		updated  int32
+		failed   int32
+	}{
+		{"new items", "sku,name,quantity\nCUP,Cup,3\nPLATE,Plate,1\n", 2, 0, 0},
+		{"update", "sku,quantity\nMUG,10\n", 0, 1, 0},
+		{"quantity below reserved", "sku,quantity\nMUG,1\n", 0, 0, 1},
+		{"barcode of another item", "sku,name,barcode\nCUP,Cup,4006381333931\n", 0, 0, 1},
+		{"barcode of another item on update", "sku,barcode\nSHIRT,4006381333931\n", 0, 0, 1},
+		{"own barcode", "sku,barcode\nMUG,4006381333931\n", 0, 1, 0},
+		{"variant sku", "sku,name\nSHIRT-S,Shirt\n", 0, 0, 1},
+		{"quantity of an item with variants", "sku,quantity\nSHIRT,4\n", 0, 0, 1},
+		{"row created earlier", "sku,name\nCUP,Cup\nCUP,Big cup\n", 1, 1, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestServer(t)
+			mug := insertItem(t, s, &pb.InventoryItem{Name: "Mug", Sku: "MUG", Quantity: 5, Barcode: "4006381333931"})
+			insertItem(t, s, &pb.InventoryItem{
+				Name:     "Shirt",
+				Sku:      "SHIRT",
+				Options:  []*pb.ProductOption{{Name: "size", Values: []string{"S"}}},
+				Variants: []*pb.Variant{{Sku: "SHIRT-S", Options: []*pb.OptionValue{{Name: "size", Value: "S"}}}},
+			})
+			if _, err := s.store.ReserveStock(mug.Id, "", 3, time.Now().Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			for _, dryRun := range []bool{true, false} {
+				res := importCSV(t, s, test.document, dryRun)
+				if res.Created != test.created || res.Updated != test.updated || res.Failed != test.failed {
+					t.Errorf("dry run %v: created %d, updated %d, failed %d (%v); want %d, %d, %d",
+						dryRun, res.Created, res.Updated, res.Failed, res.Errors, test.created, test.updated, test.failed)
+				}
+			}
+		})
+	}
+}
+
+func TestImportDryRunWritesNothing(t *testing.T) {
+	s := newTestServer(t)
+	mug := insertItem(t, s, &pb.InventoryItem{Name: "Mug", Sku: "MUG", Quantity: 5})
+	importCSV(t, s, "sku,name,quantity\nMUG,Big mug,8\nCUP,Cup,1\n", true)
+	after, err := s.store.FindById(mug.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Name != "Mug" || after.Quantity != 5 || after.Version != mug.Version {
+		t.Errorf("dry run changed the item to %v", after)
+	}
+	if _, err := s.store.FindBySku("CUP"); err == nil {
+		t.Error("dry run created an item")
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+
+	"github.com/joesjo/grpc-store/authentication/security"
+	"github.com/joesjo/grpc-store/inventory/database"
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+)
+
+// actor returns the user a request is made on behalf of, as verified from
+// its bearer token, or an empty string for anonymous requests.
+func actor(ctx context.Context) string {
+	return security.Username(ctx)
+}
+
+// stockChange describes the stock changes a request makes for the ledger.
+func stockChange(ctx context.Context, reason pb.MovementReason) database.StockChange {
+	return database.StockChange{Reason: reason, Actor: actor(ctx)}
+}
+
+// incrementReason validates the reason given for incrementing stock by
+// amount, defaulting it by the direction of the change.
+func incrementReason(reason pb.MovementReason, amount int32) (pb.MovementReason, error) {
+	switch reason {
+	case pb.MovementReason_MOVEMENT_UNSPECIFIED:
+		if amount < 0 {
+			return pb.MovementReason_MOVEMENT_PURCHASE, nil
+		}
+		return pb.MovementReason_MOVEMENT_RECEIVE, nil
+	case pb.MovementReason_MOVEMENT_RECEIVE, pb.MovementReason_MOVEMENT_RETURN:
+		if amount < 0 {
+			return 0, &InvalidRequestError{message: "received and returned stock must be positive"}
+		}
+	case pb.MovementReason_MOVEMENT_PURCHASE:
+		if amount > 0 {
+			return 0, &InvalidRequestError{message: "purchased stock must be negative"}
+		}
+	case pb.MovementReason_MOVEMENT_ADJUSTMENT:
+	case pb.MovementReason_MOVEMENT_TRANSFER:
+		return 0, &InvalidRequestError{message: "stock is transferred with TransferStock"}
+	default:
+		return 0, &InvalidRequestError{message: "unknown movement reason"}
+	}
+	return reason, nil
+}
+
+func (s *server) ListStockMovements(req *pb.ListStockMovementsRequest, stream pb.InventoryService_ListStockMovementsServer) error {
+	if req.ItemId == "" {
+		return &InvalidRequestError{message: "item id is required"}
+	}
+	return s.store.ListStockMovements(req.ItemId, func(movement *pb.StockMovement) error {
+		if req.VariantId != "" && movement.VariantId != req.VariantId {
+			return nil
+		}
+		if req.LocationId != "" && movement.LocationId != req.LocationId {
+			return nil
+		}
+		return stream.Send(movement)
+	})
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// checkLocation validates that a location named by a request exists. An
+// empty id leaves the choice to the store.
+func (s *server) checkLocation(locationId string) error {
+	if locationId == "" {
+		return nil
+	}
+	_, err := s.store.FindLocation(locationId)
+	return err
+}
+
+// checkLocationName validates that no other location is called name.
+func (s *server) checkLocationName(locationId, name string) error {
+	locations, err := s.store.ListLocations()
+	if err != nil {
+		return err
+	}
+	for _, location := range locations {
+		if location.Id != locationId && strings.EqualFold(location.Name, name) {
+			return status.Errorf(codes.AlreadyExists, "location %q already exists", name)
+		}
+	}
+	return nil
+}
+
+func (s *server) ListLocations(req *pb.ListLocationsRequest, stream pb.InventoryService_ListLocationsServer) error {
+	locations, err := s.store.ListLocations()
+	if err != nil {
+		return err
+	}
+	for _, location := range locations {
+		if err := stream.Send(location); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *server) GetLocation(ctx context.Context, req *pb.GetLocationRequest) (*pb.GetLocationResponse, error) {
+	if req.Id == "" {
+		return nil, &InvalidRequestError{message: "location id is required"}
+	}
+	location, err := s.store.FindLocation(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetLocationResponse{Location: location}, nil
+}
+
+func (s *server) CreateLocation(ctx context.Context, req *pb.CreateLocationRequest) (*pb.CreateLocationResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, &InvalidRequestError{message: "location name is required"}
+	}
+	if err := s.checkLocationName("", name); err != nil {
+		return nil, err
+	}
+	location, err := s.store.InsertLocation(&pb.Location{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CreateLocationResponse{Location: location}, nil
+}
+
+func (s *server) RenameLocation(ctx context.Context, req *pb.RenameLocationRequest) (*pb.RenameLocationResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if req.Id == "" {
+		return nil, &InvalidRequestError{message: "location id is required"}
+	}
+	if name == "" {
+		return nil, &InvalidRequestError{message: "location name is required"}
+	}
+	if err := s.checkLocationName(req.Id, name); err != nil {
+		return nil, err
+	}
+	location, err := s.store.UpdateLocation(&pb.Location{Id: req.Id, Name: name})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.RenameLocationResponse{Location: location}, nil
+}
+
+func (s *server) DeleteLocation(ctx context.Context, req *pb.DeleteLocationRequest) (*pb.DeleteLocationResponse, error) {
+	if req.Id == "" {
+		return nil, &InvalidRequestError{message: "location id is required"}
+	}
+	count, err := s.store.DeleteLocation(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.DeleteLocationResponse{Count: count}, nil
+}
+
+func (s *server) TransferStock(ctx context.Context, req *pb.TransferStockRequest) (*pb.TransferStockResponse, error) {
+	if req.ItemId == "" {
+		return nil, &InvalidRequestError{message: "item id is required"}
+	}
+	if req.FromLocationId == "" || req.ToLocationId == "" {
+		return nil, &InvalidRequestError{message: "source and destination locations are required"}
+	}
+	if req.FromLocationId == req.ToLocationId {
+		return nil, &InvalidRequestError{message: "source and destination locations must differ"}
+	}
+	if req.Quantity <= 0 {
+		return nil, &InvalidRequestError{message: "quantity must be positive"}
+	}
+	if err := s.checkLocation(req.FromLocationId); err != nil {
+		return nil, err
+	}
+	if err := s.checkLocation(req.ToLocationId); err != nil {
+		return nil, err
+	}
+	item, err := s.store.TransferStock(req.ItemId, req.VariantId, req.FromLocationId, req.ToLocationId, req.Quantity, req.ExpectedVersion, stockChange(ctx, pb.MovementReason_MOVEMENT_TRANSFER))
+	if err != nil {
+		return nil, err
+	}
+	return &pb.TransferStockResponse{Item: item}, nil
+}
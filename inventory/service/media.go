@@ -0,0 +1,205 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/joesjo/grpc-store/inventory/blobstore"
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The media types accepted for item images, as detected from their content.
+var imageContentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// validateAttributes checks the key/value attributes of an item. Keys end
+// up as field names in MongoDB, so they may not contain dots or start with
+// a dollar sign.
+func validateAttributes(item *pb.InventoryItem) error {
+	if len(item.Attributes) > MAX_ATTRIBUTES {
+		return &InvalidRequestError{message: "item has too many attributes"}
+	}
+	for key, value := range item.Attributes {
+		if key == "" {
+			return &InvalidRequestError{message: "attribute key is required"}
+		}
+		if strings.Contains(key, ".") || strings.HasPrefix(key, "$") {
+			return &InvalidRequestError{message: "attribute key must not contain dots or start with $: " + key}
+		}
+		if len(key) > MAX_ATTRIBUTE_LENGTH || len(value) > MAX_ATTRIBUTE_LENGTH {
+			return &InvalidRequestError{message: "attribute is too long: " + key}
+		}
+	}
+	return nil
+}
+
+func imageKey(itemId string, imageId string) string {
+	return itemId + "/" + imageId
+}
+
+func (s *server) UploadItemImage(stream pb.InventoryService_UploadItemImageServer) error {
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return &InvalidRequestError{message: "image is empty"}
+	}
+	if err != nil {
+		return err
+	}
+	if first.ItemId == "" {
+		return &InvalidRequestError{message: "item id is required"}
+	}
+	before, err := s.store.FindById(first.ItemId)
+	if err != nil {
+		return err
+	}
+	if first.ExpectedVersion != 0 && before.Version != first.ExpectedVersion {
+		return status.Errorf(codes.Aborted, "item %s is at version %d, expected %d", before.Id, before.Version, first.ExpectedVersion)
+	}
+	// Images are small enough to hold in memory, which lets the content
+	// be checked before anything is stored.
+	data, err := io.ReadAll(io.LimitReader(&imageReader{stream: stream, data: first.Data}, MAX_IMAGE_SIZE+1))
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return &InvalidRequestError{message: "image is empty"}
+	}
+	if len(data) > MAX_IMAGE_SIZE {
+		return &InvalidRequestError{message: "image is too large"}
+	}
+	contentType := http.DetectContentType(data)
+	if !imageContentTypes[contentType] {
+		return &InvalidRequestError{message: "unsupported image type: " + contentType}
+	}
+	image := &pb.ItemImage{
+		Id:          primitive.NewObjectID().Hex(),
+		Alt:         first.Alt,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+	}
+	key := imageKey(first.ItemId, image.Id)
+	if err := s.blobs.Put(key, bytes.NewReader(data)); err != nil {
+		return err
+	}
+	item, err := s.store.AddItemImage(first.ItemId, image, first.ExpectedVersion)
+	if err != nil {
+		s.deleteBlob(key)
+		return err
+	}
+	s.auditItemWrite(stream.Context(), "UploadItemImage", item.Id, before)
+	return stream.SendAndClose(&pb.UploadItemImageResponse{Item: item, Image: image})
+}
+
+// imageReader reads an image from the chunks sent by the client.
+type imageReader struct {
+	stream pb.InventoryService_UploadItemImageServer
+	data   []byte
+}
+
+func (r *imageReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.data = req.Data
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func (s *server) SetItemImages(ctx context.Context, req *pb.SetItemImagesRequest) (*pb.SetItemImagesResponse, error) {
+	if req.ItemId == "" {
+		return nil, &InvalidRequestError{message: "item id is required"}
+	}
+	seen := make(map[string]bool)
+	for _, image := range req.Images {
+		if image.GetId() == "" {
+			return nil, &InvalidRequestError{message: "image id is required"}
+		}
+		if seen[image.Id] {
+			return nil, &InvalidRequestError{message: "image is listed twice: " + image.Id}
+		}
+		seen[image.Id] = true
+	}
+	item, removed, err := s.store.SetItemImages(req.ItemId, req.Images, req.ExpectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	for _, image := range removed {
+		s.deleteBlob(imageKey(item.Id, image.Id))
+	}
+	return &pb.SetItemImagesResponse{Item: item}, nil
+}
+
+func (s *server) DownloadItemImage(req *pb.DownloadItemImageRequest, stream pb.InventoryService_DownloadItemImageServer) error {
+	if req.ItemId == "" || req.ImageId == "" {
+		return &InvalidRequestError{message: "item id and image id are required"}
+	}
+	item, err := s.store.FindById(req.ItemId)
+	if err != nil {
+		return err
+	}
+	var image *pb.ItemImage
+	for _, candidate := range item.Images {
+		if candidate.Id == req.ImageId {
+			image = candidate
+		}
+	}
+	if image == nil {
+		return status.Errorf(codes.NotFound, "item %s has no image %q", item.Id, req.ImageId)
+	}
+	blob, err := s.blobs.Open(imageKey(item.Id, image.Id))
+	if err == blobstore.ErrNotFound {
+		return status.Errorf(codes.NotFound, "image %s of item %s is missing", image.Id, item.Id)
+	}
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+	buffer := make([]byte, IMAGE_CHUNK_SIZE)
+	chunk := &pb.ItemImageChunk{ContentType: image.ContentType}
+	for {
+		n, err := io.ReadFull(blob, buffer)
+		if n > 0 {
+			chunk.Data = buffer[:n]
+			if err := stream.Send(chunk); err != nil {
+				return err
+			}
+			chunk = &pb.ItemImageChunk{}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// deleteBlob deletes a blob that is no longer referenced. Failures only
+// leave an orphaned file behind, so they are logged.
+func (s *server) deleteBlob(key string) {
+	if err := s.blobs.Delete(key); err != nil {
+		log.Println("Deleting blob failed:", key, err)
+	}
+}
+
+// deleteItemBlobs deletes the blobs of a purged item.
+func deleteItemBlobs(blobs blobstore.Store, itemId string) {
+	if err := blobs.DeleteAll(itemId); err != nil {
+		log.Println("Deleting item blobs failed:", itemId, err)
+	}
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/joesjo/grpc-store/inventory/database"
+	"github.com/joesjo/grpc-store/inventory/events"
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *server) SchedulePrice(ctx context.Context, req *pb.SchedulePriceRequest) (*pb.SchedulePriceResponse, error) {
+	if req.ItemId == "" {
+		return nil, &InvalidRequestError{message: "item id is required"}
+	}
+	if req.Price == nil {
+		return nil, &InvalidRequestError{message: "price is required"}
+	}
+	if err := validateMoney("price", req.Price); err != nil {
+		return nil, err
+	}
+	if err := validateMoney("compare-at price", req.CompareAtPrice); err != nil {
+		return nil, err
+	}
+	if req.CompareAtPrice != nil && req.CompareAtPrice.Currency != req.Price.Currency {
+		return nil, &InvalidRequestError{message: "compare-at price must be in the same currency as the price"}
+	}
+	item, err := s.store.FindById(req.ItemId)
+	if err != nil {
+		return nil, err
+	}
+	if item.Price != nil && item.Price.Currency != req.Price.Currency {
+		return nil, &InvalidRequestError{message: "scheduled price must be in the currency of the item's price"}
+	}
+	// Times are kept to milliseconds, the precision MongoDB stores them
+	// with.
+	now := time.Now().Truncate(time.Millisecond)
+	from := now
+	if req.EffectiveFrom != nil {
+		from = req.EffectiveFrom.AsTime().Truncate(time.Millisecond)
+	}
+	price := &pb.ScheduledPrice{
+		ItemId:         req.ItemId,
+		Price:          req.Price,
+		CompareAtPrice: req.CompareAtPrice,
+		EffectiveFrom:  timestamppb.New(from),
+		CreatedBy:      actor(ctx),
+		CreatedAt:      timestamppb.New(now),
+	}
+	if req.EffectiveTo != nil {
+		to := req.EffectiveTo.AsTime().Truncate(time.Millisecond)
+		if !to.After(from) {
+			return nil, &InvalidRequestError{message: "effective window must start before it ends"}
+		}
+		if !to.After(now) {
+			return nil, &InvalidRequestError{message: "effective window must end in the future"}
+		}
+		price.EffectiveTo = timestamppb.New(to)
+	}
+	price, err = s.store.InsertScheduledPrice(price)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.SchedulePriceResponse{ScheduledPrice: price}, nil
+}
+
+func (s *server) CancelScheduledPrice(ctx context.Context, req *pb.CancelScheduledPriceRequest) (*pb.CancelScheduledPriceResponse, error) {
+	if req.Id == "" {
+		return nil, &InvalidRequestError{message: "scheduled price id is required"}
+	}
+	price, err := s.store.CancelScheduledPrice(req.Id, time.Now().Truncate(time.Millisecond))
+	if err != nil {
+		return nil, err
+	}
+	if price.ActivatedAt != nil {
+		publishPriceChange(s.store, s.publisher, price, pb.PriceChangeType_PRICE_CHANGE_CANCELLED)
+	}
+	return &pb.CancelScheduledPriceResponse{ScheduledPrice: price}, nil
+}
+
+func (s *server) GetCurrentPrice(ctx context.Context, req *pb.GetCurrentPriceRequest) (*pb.GetCurrentPriceResponse, error) {
+	if req.ItemId == "" {
+		return nil, &InvalidRequestError{message: "item id is required"}
+	}
+	return currentPrice(s.store, req.ItemId, time.Now())
+}
+
+// GetPriceHistory does not check that the item exists, so that the history
+// of a purged item can still be read.
+func (s *server) GetPriceHistory(ctx context.Context, req *pb.GetPriceHistoryRequest) (*pb.GetPriceHistoryResponse, error) {
+	if req.ItemId == "" {
+		return nil, &InvalidRequestError{message: "item id is required"}
+	}
+	prices, err := s.store.ListScheduledPrices(req.ItemId)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetPriceHistoryResponse{Prices: prices}, nil
+}
+
+// currentPrice resolves the price of an item at a time from the windows of
+// its scheduled prices rather than their status, so that prices take effect
+// on time even before the activation job gets to them. Should windows
+// overlap, the price that starts last wins. Without a scheduled price, the
+// item's own price holds.
+func currentPrice(store database.ItemStore, itemId string, at time.Time) (*pb.GetCurrentPriceResponse, error) {
+	item, err := store.FindById(itemId)
+	if err != nil {
+		return nil, err
+	}
+	prices, err := store.ListScheduledPrices(itemId)
+	if err != nil {
+		return nil, err
+	}
+	response := &pb.GetCurrentPriceResponse{Price: item.Price, CompareAtPrice: item.CompareAtPrice}
+	for _, price := range prices {
+		if price.Status == pb.ScheduledPriceStatus_PRICE_CANCELLED {
+			continue
+		}
+		started := !price.EffectiveFrom.AsTime().After(at)
+		ended := price.EffectiveTo != nil && !price.EffectiveTo.AsTime().After(at)
+		if started && !ended {
+			response = &pb.GetCurrentPriceResponse{
+				Price:            price.Price,
+				CompareAtPrice:   price.CompareAtPrice,
+				ScheduledPriceId: price.Id,
+			}
+		}
+	}
+	return response, nil
+}
+
+// activateScheduledPrices periodically activates the scheduled prices whose
+// window started and expires those whose window ended, publishing an event
+// for each.
+func activateScheduledPrices(store database.ItemStore, publisher events.Publisher) {
+	for now := range time.Tick(PRICE_ACTIVATION_INTERVAL) {
+		now = now.Truncate(time.Millisecond)
+		expired, err := store.ExpireScheduledPrices(now)
+		for _, price := range expired {
+			publishPriceChange(store, publisher, price, pb.PriceChangeType_PRICE_CHANGE_EXPIRED)
+		}
+		if err != nil {
+			log.Println("Expiring scheduled prices failed:", err)
+		}
+		activated, err := store.ActivateScheduledPrices(now)
+		for _, price := range activated {
+			publishPriceChange(store, publisher, price, pb.PriceChangeType_PRICE_CHANGE_ACTIVATED)
+		}
+		if err != nil {
+			log.Println("Activating scheduled prices failed:", err)
+		}
+	}
+}
+
+// publishPriceChange publishes that a scheduled price took effect or ended,
+// along with the price of its item from then on.
+func publishPriceChange(store database.ItemStore, publisher events.Publisher, price *pb.ScheduledPrice, changeType pb.PriceChangeType) {
+	current, err := currentPrice(store, price.ItemId, time.Now())
+	if err != nil {
+		log.Println("Resolving price of changed item failed:", price.ItemId, err)
+		return
+	}
+	publisher.Publish(&pb.InventoryEvent{
+		OccurredAt: timestamppb.Now(),
+		Event: &pb.InventoryEvent_PriceChange{PriceChange: &pb.PriceChangeEvent{
+			ItemId:           price.ItemId,
+			ScheduledPriceId: price.Id,
+			Type:             changeType,
+			Price:            current.Price,
+			CompareAtPrice:   current.CompareAtPrice,
+		}},
+	})
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// validateReorder checks the reorder settings of an item.
+func validateReorder(item *pb.InventoryItem) error {
+	if item.ReorderPoint < 0 {
+		return &InvalidRequestError{message: "reorder point must not be negative"}
+	}
+	if item.ReorderQuantity < 0 {
+		return &InvalidRequestError{message: "reorder quantity must not be negative"}
+	}
+	return nil
+}
+
+// lowStockEvent reports that item dropped to its reorder point.
+func lowStockEvent(item *pb.InventoryItem) *pb.InventoryEvent {
+	return &pb.InventoryEvent{
+		OccurredAt: timestamppb.Now(),
+		Event: &pb.InventoryEvent_LowStock{LowStock: &pb.LowStockEvent{
+			ItemId:          item.Id,
+			Name:            item.Name,
+			Sku:             item.Sku,
+			Quantity:        item.Quantity,
+			ReorderPoint:    item.ReorderPoint,
+			ReorderQuantity: item.ReorderQuantity,
+		}},
+	}
+}
+
+func (s *server) ListLowStockItems(req *pb.ListLowStockItemsRequest, stream pb.InventoryService_ListLowStockItemsServer) error {
+	return s.store.ListLowStockItems(stream.Send)
+}
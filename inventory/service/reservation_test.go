@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/grpc/codes"
+)
+
+func reserve(s *server, itemId string, quantity int32) (*pb.Reservation, error) {
+	res, err := s.ReserveStock(context.Background(), &pb.ReserveStockRequest{ItemId: itemId, Quantity: quantity})
+	return res.GetReservation(), err
+}
+
+func TestReserveStock(t *testing.T) {
+	s := newTestServer(t)
+	mug := insertItem(t, s, &pb.InventoryItem{Name: "Mug", Quantity: 5})
+	reservation, err := reserve(s, mug.Id, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reservation.Status != pb.ReservationStatus_RESERVATION_PENDING {
+		t.Errorf("reservation is %v, want pending", reservation.Status)
+	}
+	if ttl := time.Until(reservation.ExpiresAt.AsTime()); ttl <= 0 || ttl > DEFAULT_RESERVATION_TTL {
+		t.Errorf("reservation expires in %v, want the default ttl", ttl)
+	}
+	_, err = reserve(s, mug.Id, 3)
+	checkCode(t, err, codes.FailedPrecondition)
+	_, err = reserve(s, mug.Id, 0)
+	checkCode(t, err, codes.Unknown)
+	_, err = reserve(s, "missing", 1)
+	checkCode(t, err, codes.NotFound)
+	item, err := s.store.FindById(mug.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Quantity != 5 || item.Reserved != 3 {
+		t.Errorf("item has %d with %d reserved, want 5 with 3", item.Quantity, item.Reserved)
+	}
+}
+
+func TestSettleReservation(t *testing.T) {
+	tests := []struct {
+		name         string
+		settle       func(s *server, id string) error
+		wantQuantity int32
+	}{
+		{"commit", func(s *server, id string) error {
+			_, err := s.CommitReservation(context.Background(), &pb.CommitReservationRequest{Id: id})
+			return err
+		}, 2},
+		{"release", func(s *server, id string) error {
+			_, err := s.ReleaseReservation(context.Background(), &pb.ReleaseReservationRequest{Id: id})
+			return err
+		}, 5},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestServer(t)
+			mug := insertItem(t, s, &pb.InventoryItem{Name: "Mug", Quantity: 5})
+			reservation, err := reserve(s, mug.Id, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := test.settle(s, reservation.Id); err != nil {
+				t.Fatal(err)
+			}
+			checkCode(t, test.settle(s, reservation.Id), codes.FailedPrecondition)
+			item, err := s.store.FindById(mug.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if item.Quantity != test.wantQuantity || item.Reserved != 0 {
+				t.Errorf("item has %d with %d reserved, want %d with none", item.Quantity, item.Reserved, test.wantQuantity)
+			}
+		})
+	}
+}
+
+func TestExpiredReservationCannotBeCommitted(t *testing.T) {
+	s := newTestServer(t)
+	mug := insertItem(t, s, &pb.InventoryItem{Name: "Mug", Quantity: 5})
+	reservation, err := s.store.ReserveStock(mug.Id, "", 3, time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.CommitReservation(context.Background(), &pb.CommitReservationRequest{Id: reservation.Id})
+	checkCode(t, err, codes.FailedPrecondition)
+	item, err := s.store.FindById(mug.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Quantity != 5 || item.Reserved != 0 {
+		t.Errorf("item has %d with %d reserved, want the hold released", item.Quantity, item.Reserved)
+	}
+}
+
+func TestQuantityBelowReservedIsRejected(t *testing.T) {
+	s := newTestServer(t)
+	mug := insertItem(t, s, &pb.InventoryItem{Name: "Mug", Quantity: 5})
+	if _, err := reserve(s, mug.Id, 3); err != nil {
+		t.Fatal(err)
+	}
+	_, err := s.UpdateItem(context.Background(), &pb.UpdateItemRequest{Item: &pb.InventoryItem{Id: mug.Id, Name: "Mug", Quantity: 2}})
+	checkCode(t, err, codes.FailedPrecondition)
+}
@@ -5,18 +5,66 @@ import (
 	"log"
 	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/joesjo/grpc-store/audit"
+	"github.com/joesjo/grpc-store/authentication/security"
+	"github.com/joesjo/grpc-store/inventory/blobstore"
 	"github.com/joesjo/grpc-store/inventory/database"
+	"github.com/joesjo/grpc-store/inventory/events"
 	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"github.com/joesjo/grpc-store/inventory/search"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 const (
 	DEFAULT_PORT = "8082"
+
+	DEFAULT_PAGE_SIZE = 50
+	MAX_PAGE_SIZE     = 500
+
+	DEFAULT_RESERVATION_TTL    = 15 * time.Minute
+	RESERVATION_SWEEP_INTERVAL = 10 * time.Second
+
+	PRICE_ACTIVATION_INTERVAL = 10 * time.Second
+
+	DEFAULT_ARCHIVE_RETENTION = 30 * 24 * time.Hour
+	ARCHIVE_PURGE_INTERVAL    = time.Hour
+
+	DEFAULT_SEARCH_LIMIT       = 20
+	MAX_SEARCH_LIMIT           = 100
+	INDEX_WATCH_RETRY_INTERVAL = 10 * time.Second
+
+	MAX_BATCH_SIZE = 100
+
+	DEFAULT_IDEMPOTENCY_WINDOW = 24 * time.Hour
+	IDEMPOTENCY_SWEEP_INTERVAL = time.Minute
+	MAX_IDEMPOTENCY_KEY_LENGTH = 255
+
+	MAX_IMPORT_ERRORS = 100
+	EXPORT_CHUNK_SIZE = 32 << 10
+
+	MAX_IMAGE_SIZE       = 10 << 20
+	IMAGE_CHUNK_SIZE     = 32 << 10
+	MAX_ATTRIBUTES       = 100
+	MAX_ATTRIBUTE_LENGTH = 1024
 )
 
 type server struct {
 	pb.UnimplementedInventoryServiceServer
+	store     database.ItemStore
+	index     *search.Index
+	auditLog  audit.Sink
+	blobs     blobstore.Store
+	publisher events.Publisher
+
+	idempotencyWindow time.Duration
 }
 
 type InvalidRequestError struct {
@@ -27,16 +75,20 @@ func (e *InvalidRequestError) Error() string {
 	return "Invalid request: " + e.message
 }
 
-func (s *server) GetInventory(req *pb.Empty, stream pb.InventoryService_GetInventoryServer) error {
-	items, err := database.GetAllItems()
+func (s *server) GetInventory(req *pb.GetInventoryRequest, stream pb.InventoryService_GetInventoryServer) error {
+	page, err := pageFromRequest(req.PageSize, req.PageToken)
 	if err != nil {
 		return err
 	}
-	for _, item := range items {
-		if err := stream.Send(item); err != nil {
-			return err
-		}
+	query := database.ItemQuery{Page: page}
+	if req.IncludeArchived {
+		query.Filter = &pb.ItemFilter{Archived: pb.ArchiveFilter_ARCHIVED_INCLUDE}
 	}
+	info, err := s.store.ListItems(query, stream.Send)
+	if err != nil {
+		return err
+	}
+	stream.SetTrailer(pageTrailer(info))
 	return nil
 }
 
@@ -44,75 +96,417 @@ func (s *server) GetItem(ctx context.Context, req *pb.GetItemRequest) (*pb.GetIt
 	if req.Id == "" {
 		return nil, &InvalidRequestError{message: "item id is required"}
 	}
-	item, err := database.FindById(req.Id)
+	item, err := s.store.FindById(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	response := &pb.GetItemResponse{Item: item}
+	return response, nil
+}
+
+func (s *server) GetItemBySku(ctx context.Context, req *pb.GetItemBySkuRequest) (*pb.GetItemResponse, error) {
+	if req.Sku == "" {
+		return nil, &InvalidRequestError{message: "sku is required"}
+	}
+	item, err := s.store.FindBySku(req.Sku)
 	if err != nil {
 		return nil, err
 	}
 	response := &pb.GetItemResponse{Item: item}
+	if item.Sku != req.Sku {
+		response.VariantId = variantBySku(item, req.Sku).GetId()
+	}
 	return response, nil
 }
 
+func (s *server) GetItemByBarcode(ctx context.Context, req *pb.GetItemByBarcodeRequest) (*pb.GetItemResponse, error) {
+	if req.Barcode == "" {
+		return nil, &InvalidRequestError{message: "barcode is required"}
+	}
+	item, err := s.store.FindByBarcode(req.Barcode)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetItemResponse{Item: item}, nil
+}
+
 func (s *server) FindItems(req *pb.FindItemsRequest, stream pb.InventoryService_FindItemsServer) error {
-	if req.Name == "" {
-		return &InvalidRequestError{message: "item name is required"}
+	filter, err := filterFromRequest(req)
+	if err != nil {
+		return err
 	}
-	items, err := database.FindByName(req.Name)
+	if err := s.expandSubcategories(filter); err != nil {
+		return err
+	}
+	page, err := pageFromRequest(req.PageSize, req.PageToken)
 	if err != nil {
 		return err
 	}
-	for _, item := range items {
-		if err := stream.Send(item); err != nil {
-			return err
-		}
+	query := database.ItemQuery{
+		Filter:    filter,
+		SortBy:    req.SortBy,
+		Direction: req.SortDirection,
+		Page:      page,
+	}
+	info, err := s.store.ListItems(query, stream.Send)
+	if err != nil {
+		return err
 	}
+	stream.SetTrailer(pageTrailer(info))
 	return nil
 }
 
+// filterFromRequest validates the filter of a FindItems request. The legacy
+// name field is treated as a substring match on the item name.
+func filterFromRequest(req *pb.FindItemsRequest) (*pb.ItemFilter, error) {
+	filter := &pb.ItemFilter{}
+	if req.Filter != nil {
+		filter = proto.Clone(req.Filter).(*pb.ItemFilter)
+	}
+	if filter.Name == nil && req.Name != "" {
+		filter.Name = &pb.StringMatch{Value: req.Name}
+	}
+	if quantity := filter.Quantity; quantity != nil && quantity.Min != nil && quantity.Max != nil && *quantity.Min > *quantity.Max {
+		return nil, &InvalidRequestError{message: "quantity min must not exceed max"}
+	}
+	for _, timeRange := range []*pb.TimeRange{filter.CreatedAt, filter.UpdatedAt} {
+		if timeRange.GetFrom() != nil && timeRange.GetTo() != nil && !timeRange.From.AsTime().Before(timeRange.To.AsTime()) {
+			return nil, &InvalidRequestError{message: "time range must start before it ends"}
+		}
+	}
+	if _, ok := pb.ArchiveFilter_name[int32(filter.Archived)]; !ok {
+		return nil, &InvalidRequestError{message: "unknown archive filter"}
+	}
+	if _, ok := pb.ItemSortField_name[int32(req.SortBy)]; !ok {
+		return nil, &InvalidRequestError{message: "unknown sort field"}
+	}
+	if _, ok := pb.SortDirection_name[int32(req.SortDirection)]; !ok {
+		return nil, &InvalidRequestError{message: "unknown sort direction"}
+	}
+	return filter, nil
+}
+
+func pageFromRequest(pageSize int32, pageToken string) (database.Page, error) {
+	if pageSize < 0 {
+		return database.Page{}, &InvalidRequestError{message: "page size must not be negative"}
+	}
+	if pageSize == 0 {
+		pageSize = DEFAULT_PAGE_SIZE
+	}
+	if pageSize > MAX_PAGE_SIZE {
+		pageSize = MAX_PAGE_SIZE
+	}
+	return database.Page{Size: pageSize, Token: pageToken}, nil
+}
+
+func pageTrailer(info *database.PageInfo) metadata.MD {
+	return metadata.Pairs(
+		"next-page-token", info.NextPageToken,
+		"total-count", strconv.FormatInt(info.TotalCount, 10),
+	)
+}
+
 func (s *server) InsertItem(ctx context.Context, req *pb.InsertItemRequest) (*pb.InsertItemResponse, error) {
 	item := req.Item
-	id, err := database.InsertItem(item)
+	if err := validatePricing(item); err != nil {
+		return nil, err
+	}
+	if err := validateIdentifiers(item); err != nil {
+		return nil, err
+	}
+	if err := validateReorder(item); err != nil {
+		return nil, err
+	}
+	if err := s.checkCategories(item); err != nil {
+		return nil, err
+	}
+	if err := checkItemVariants(item); err != nil {
+		return nil, err
+	}
+	if err := validateAttributes(item); err != nil {
+		return nil, err
+	}
+	if len(item.Images) > 0 {
+		return nil, &InvalidRequestError{message: "images are added with UploadItemImage"}
+	}
+	id, err := s.store.InsertItem(item, stockChange(ctx, pb.MovementReason_MOVEMENT_RECEIVE))
 	if err != nil {
 		return nil, err
 	}
-	return &pb.InsertItemResponse{ItemId: id.Hex()}, nil
+	s.reindex(id)
+	return &pb.InsertItemResponse{ItemId: id}, nil
 }
 
 func (s *server) UpdateItem(ctx context.Context, req *pb.UpdateItemRequest) (*pb.UpdateItemResponse, error) {
+	if err := s.checkUpdate(req); err != nil {
+		return nil, err
+	}
 	item := req.Item
-	count, err := database.UpdateItem(item)
+	count, err := s.store.UpdateItem(item, req.UpdateMask.GetPaths(), req.ExpectedVersion, stockChange(ctx, pb.MovementReason_MOVEMENT_ADJUSTMENT))
 	if err != nil {
 		return nil, err
 	}
+	s.reindex(item.Id)
 	return &pb.UpdateItemResponse{Count: count}, nil
 }
 
+// checkUpdate validates an update request before it is handed to the
+// store.
+func (s *server) checkUpdate(req *pb.UpdateItemRequest) error {
+	item := req.Item
+	if item.GetId() == "" {
+		return &InvalidRequestError{message: "item id is required"}
+	}
+	if err := validatePricing(item); err != nil {
+		return err
+	}
+	if err := validateIdentifiers(item); err != nil {
+		return err
+	}
+	if err := validateReorder(item); err != nil {
+		return err
+	}
+	if err := s.checkCategories(item); err != nil {
+		return err
+	}
+	if err := validateAttributes(item); err != nil {
+		return err
+	}
+	if paths := req.UpdateMask.GetPaths(); len(paths) == 0 || containsPath(paths, "options") {
+		if err := s.checkOptionsUpdate(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *server) DeleteItem(ctx context.Context, req *pb.DeleteItemRequest) (*pb.DeleteItemResponse, error) {
-	count, err := database.DeleteItem(req.Id)
+	if req.Id == "" {
+		return nil, &InvalidRequestError{message: "item id is required"}
+	}
+	count, err := s.store.ArchiveItem(req.Id, req.ExpectedVersion, actor(ctx))
 	if err != nil {
 		return nil, err
 	}
+	s.index.Remove(req.Id)
 	return &pb.DeleteItemResponse{Count: count}, nil
 }
 
 func (s *server) IncrementItemQuantity(ctx context.Context, req *pb.IncrementItemQuantityRequest) (*pb.IncrementItemQuantityResponse, error) {
-	count, err := database.IncrementItemQuantity(req.Id, req.Amount)
+	reason, err := incrementReason(req.Reason, req.Amount)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkLocation(req.LocationId); err != nil {
+		return nil, err
+	}
+	count, err := s.store.IncrementItemQuantity(req.Id, req.VariantId, req.LocationId, req.Amount, req.AllowBackorder, req.ExpectedVersion, stockChange(ctx, reason))
 	if err != nil {
 		return nil, err
 	}
 	return &pb.IncrementItemQuantityResponse{Count: count}, nil
 }
 
-func Start() {
+func (s *server) ReserveStock(ctx context.Context, req *pb.ReserveStockRequest) (*pb.ReserveStockResponse, error) {
+	if req.ItemId == "" {
+		return nil, &InvalidRequestError{message: "item id is required"}
+	}
+	if req.Quantity <= 0 {
+		return nil, &InvalidRequestError{message: "quantity must be positive"}
+	}
+	if req.TtlSeconds < 0 {
+		return nil, &InvalidRequestError{message: "ttl must not be negative"}
+	}
+	ttl := DEFAULT_RESERVATION_TTL
+	if req.TtlSeconds > 0 {
+		ttl = time.Duration(req.TtlSeconds) * time.Second
+	}
+	reservation, err := s.store.ReserveStock(req.ItemId, req.VariantId, req.Quantity, time.Now().Add(ttl))
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ReserveStockResponse{Reservation: reservation}, nil
+}
+
+func (s *server) CommitReservation(ctx context.Context, req *pb.CommitReservationRequest) (*pb.CommitReservationResponse, error) {
+	if req.Id == "" {
+		return nil, &InvalidRequestError{message: "reservation id is required"}
+	}
+	if err := s.checkLocation(req.LocationId); err != nil {
+		return nil, err
+	}
+	reservation, err := s.store.CommitReservation(req.Id, req.LocationId, stockChange(ctx, pb.MovementReason_MOVEMENT_PURCHASE))
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CommitReservationResponse{Reservation: reservation}, nil
+}
+
+func (s *server) ReleaseReservation(ctx context.Context, req *pb.ReleaseReservationRequest) (*pb.ReleaseReservationResponse, error) {
+	if req.Id == "" {
+		return nil, &InvalidRequestError{message: "reservation id is required"}
+	}
+	reservation, err := s.store.ReleaseReservation(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ReleaseReservationResponse{Reservation: reservation}, nil
+}
+
+func (s *server) SearchItems(req *pb.SearchItemsRequest, stream pb.InventoryService_SearchItemsServer) error {
+	if strings.TrimSpace(req.Query) == "" {
+		return &InvalidRequestError{message: "search query is required"}
+	}
+	if req.Limit < 0 {
+		return &InvalidRequestError{message: "limit must not be negative"}
+	}
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = DEFAULT_SEARCH_LIMIT
+	}
+	if limit > MAX_SEARCH_LIMIT {
+		limit = MAX_SEARCH_LIMIT
+	}
+	for _, hit := range s.index.Search(req.Query, limit, !req.Exact) {
+		item, err := s.store.FindById(hit.ItemId)
+		if status.Code(err) == codes.NotFound {
+			// Deleted since it was indexed.
+			s.index.Remove(hit.ItemId)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		result := &pb.SearchResult{Item: item, Score: hit.Score, Highlights: hit.Highlights}
+		if err := stream.Send(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reindex refreshes the search index entry of an item after a write.
+func (s *server) reindex(itemId string) {
+	item, err := s.store.FindById(itemId)
+	if status.Code(err) == codes.NotFound {
+		s.index.Remove(itemId)
+		return
+	}
+	if err != nil {
+		log.Println("Indexing item failed:", itemId, err)
+		return
+	}
+	if item.ArchivedAt != nil {
+		s.index.Remove(itemId)
+		return
+	}
+	s.index.Add(item)
+}
+
+// followChanges keeps the search index current with the writes of other
+// instances sharing the database, which it learns of by watching the
+// store. A watch that fails is resumed after the last change it sent, or,
+// when its resume token has expired, the index is rebuilt.
+func (s *server) followChanges() {
+	resumeToken := ""
+	for {
+		query := database.WatchQuery{ResumeToken: resumeToken}
+		err := s.store.WatchItems(context.Background(), query, func(change *pb.ItemChange) error {
+			if change.Type == pb.ItemChangeType_ITEM_DELETED || change.Item.GetArchivedAt() != nil {
+				s.index.Remove(change.ItemId)
+			} else {
+				s.index.Add(change.Item)
+			}
+			resumeToken = change.ResumeToken
+			return nil
+		})
+		log.Println("Watching items for the search index failed:", err)
+		time.Sleep(INDEX_WATCH_RETRY_INTERVAL)
+		if code := status.Code(err); code == codes.OutOfRange || code == codes.InvalidArgument {
+			resumeToken = ""
+			index, err := buildIndex(s.store)
+			if err != nil {
+				log.Println("Rebuilding the search index failed:", err)
+				continue
+			}
+			s.index.Replace(index)
+		}
+	}
+}
+
+// buildIndex indexes every stored item. Servers keep it current with
+// followChanges.
+func buildIndex(store database.ItemStore) (*search.Index, error) {
+	index := search.NewIndex()
+	_, err := store.ListItems(database.ItemQuery{}, func(item *pb.InventoryItem) error {
+		index.Add(item)
+		return nil
+	})
+	return index, err
+}
+
+// expireReservations periodically releases reservations whose hold has run
+// out without being committed.
+func expireReservations(store database.ItemStore) {
+	for now := range time.Tick(RESERVATION_SWEEP_INTERVAL) {
+		count, err := store.ExpireReservations(now)
+		if err != nil {
+			log.Println("Expiring reservations failed:", err)
+			continue
+		}
+		if count > 0 {
+			log.Println("Expired reservations:", count)
+		}
+	}
+}
+
+// grpcServer returns a gRPC server serving s behind the interceptors that
+// verify tokens, replay idempotent calls and record the audit log.
+func (s *server) grpcServer() *grpc.Server {
+	g := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(security.UnaryServerInterceptor, s.replayIdempotent, s.auditCalls),
+		grpc.StreamInterceptor(security.StreamServerInterceptor))
+	pb.RegisterInventoryServiceServer(g, s)
+	return g
+}
+
+func Start(store database.ItemStore) {
 	port, exists := os.LookupEnv("PORT")
 	if !exists {
 		port = DEFAULT_PORT
 	}
+	if err := security.CheckSecret(); err != nil {
+		log.Fatal(err)
+	}
 	lis, err := net.Listen("tcp", ":"+port)
 	if err != nil {
 		log.Fatal(err)
 	}
-	s := grpc.NewServer()
-	pb.RegisterInventoryServiceServer(s, &server{})
+	index, err := buildIndex(store)
+	if err != nil {
+		log.Fatal(err)
+	}
+	blobs, err := blobstore.Init()
+	if err != nil {
+		log.Fatal(err)
+	}
+	go expireReservations(store)
+	go expireIdempotencyKeys(store)
+	if retention := archiveRetention(); retention > 0 {
+		go purgeArchivedItems(store, blobs, retention)
+	}
+	publisher := events.Init()
+	store.OnLowStock(func(item *pb.InventoryItem) {
+		publisher.Publish(lowStockEvent(item))
+	})
+	go activateScheduledPrices(store, publisher)
+	auditLog, err := audit.Init()
+	if err != nil {
+		log.Fatal(err)
+	}
+	srv := &server{store: store, index: index, auditLog: auditLog, blobs: blobs, publisher: publisher, idempotencyWindow: idempotencyWindow()}
+	go srv.followChanges()
+	s := srv.grpcServer()
 	log.Printf("Starting inventory management server on port %s", port)
 	if err := s.Serve(lis); err != nil {
 		log.Fatal(err)
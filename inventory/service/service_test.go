@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/joesjo/grpc-store/audit"
+	"github.com/joesjo/grpc-store/authentication/security"
+	"github.com/joesjo/grpc-store/inventory/blobstore"
+	"github.com/joesjo/grpc-store/inventory/database"
+	"github.com/joesjo/grpc-store/inventory/events"
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"github.com/joesjo/grpc-store/inventory/search"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// newTestServer returns a server on a fresh memory store, with its files
+// in a temporary directory.
+func newTestServer(t *testing.T) *server {
+	t.Helper()
+	store := database.NewMemoryStore()
+	index, err := buildIndex(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobs, err := blobstore.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &server{
+		store:             store,
+		index:             index,
+		auditLog:          audit.NewMemorySink(),
+		blobs:             blobs,
+		publisher:         events.LogPublisher{},
+		idempotencyWindow: time.Hour,
+	}
+}
+
+// dial serves s on an in-process connection, behind the interceptors of
+// the service, and returns a client of it.
+func dial(t *testing.T, s *server) pb.InventoryServiceClient {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "test-secret")
+	lis := bufconn.Listen(1 << 20)
+	g := s.grpcServer()
+	go g.Serve(lis)
+	t.Cleanup(g.Stop)
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewInventoryServiceClient(conn)
+}
+
+// asUser returns a context for calls made with the token of username.
+func asUser(t *testing.T, username string) context.Context {
+	t.Helper()
+	token, err := security.CreateToken(username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return metadata.AppendToOutgoingContext(context.Background(), security.AuthorizationMetadataKey, "Bearer "+token)
+}
+
+func insertItem(t *testing.T, s *server, item *pb.InventoryItem) *pb.InventoryItem {
+	t.Helper()
+	res, err := s.InsertItem(context.Background(), &pb.InsertItemRequest{Item: item})
+	if err != nil {
+		t.Fatalf("InsertItem(%v): %v", item, err)
+	}
+	inserted, err := s.store.FindById(res.ItemId)
+	if err != nil {
+		t.Fatalf("FindById(%s): %v", res.ItemId, err)
+	}
+	return inserted
+}
+
+func checkCode(t *testing.T, err error, want codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Fatalf("got error %v, want code %v", err, want)
+	}
+}
+
+func TestGetItemBySku(t *testing.T) {
+	s := newTestServer(t)
+	mug := insertItem(t, s, &pb.InventoryItem{Name: "Mug", Sku: "MUG"})
+	shirt := insertItem(t, s, &pb.InventoryItem{
+		Name:    "Shirt",
+		Sku:     "SHIRT",
+		Options: []*pb.ProductOption{{Name: "size", Values: []string{"S", "M"}}},
+		Variants: []*pb.Variant{
+			{Sku: "SHIRT-S", Options: []*pb.OptionValue{{Name: "size", Value: "S"}}},
+			{Sku: "SHIRT-M", Options: []*pb.OptionValue{{Name: "size", Value: "M"}}},
+		},
+	})
+	tests := []struct {
+		name          string
+		sku           string
+		wantItem      string
+		wantVariantId string
+		wantCode      codes.Code
+	}{
+		{"item", "MUG", mug.Id, "", codes.OK},
+		{"item with variants", "SHIRT", shirt.Id, "", codes.OK},
+		{"variant", "SHIRT-M", shirt.Id, shirt.Variants[1].Id, codes.OK},
+		{"missing", "HAT", "", "", codes.NotFound},
+		{"empty", "", "", "", codes.Unknown},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := s.GetItemBySku(context.Background(), &pb.GetItemBySkuRequest{Sku: test.sku})
+			checkCode(t, err, test.wantCode)
+			if res.GetItem().GetId() != test.wantItem || res.GetVariantId() != test.wantVariantId {
+				t.Errorf("found item %q with variant %q, want %q with %q",
+					res.GetItem().GetId(), res.GetVariantId(), test.wantItem, test.wantVariantId)
+			}
+		})
+	}
+}
+
+func TestSearchIndexFollowsOtherInstances(t *testing.T) {
+	s := newTestServer(t)
+	other := &server{store: s.store, index: search.NewIndex()}
+	go other.followChanges()
+	// The watch starts with the next change, so wait until it sees one.
+	waitFor(t, func() bool {
+		insertItem(t, s, &pb.InventoryItem{Name: "Probe"})
+		return len(other.index.Search("probe", 1, false)) > 0
+	})
+	mug := insertItem(t, s, &pb.InventoryItem{Name: "Enamel mug", Sku: "MUG"})
+	waitFor(t, func() bool {
+		return len(other.index.Search("enamel", 1, false)) == 1
+	})
+	if _, err := s.DeleteItem(context.Background(), &pb.DeleteItemRequest{Id: mug.Id}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		return len(other.index.Search("enamel", 1, false)) == 0
+	})
+}
+
+// waitFor polls done until it holds, failing the test after a second.
+func waitFor(t *testing.T, done func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !done(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+	}
+}
+
+func TestUpdateItemFieldMask(t *testing.T) {
+	tests := []struct {
+		name     string
+		update   *pb.InventoryItem
+		paths    []string
+		wantCode codes.Code
+		want     func(item *pb.InventoryItem) bool
+	}{
+		{"named fields only", &pb.InventoryItem{Name: "Big mug", Description: "ignored"}, []string{"name"}, codes.OK,
+			func(item *pb.InventoryItem) bool {
+				return item.Name == "Big mug" && item.Description == "Enamel" && item.Quantity == 5
+			}},
+		{"cleared field", &pb.InventoryItem{}, []string{"description"}, codes.OK,
+			func(item *pb.InventoryItem) bool {
+				return item.Name == "Mug" && item.Description == "" && item.Quantity == 5
+			}},
+		{"every writable field without a mask", &pb.InventoryItem{Name: "Cup", Quantity: 7}, nil, codes.OK,
+			func(item *pb.InventoryItem) bool {
+				return item.Name == "Cup" && item.Description == "" && item.Quantity == 7 && item.Sku == ""
+			}},
+		{"read-only field", &pb.InventoryItem{Reserved: 3}, []string{"reserved"}, codes.InvalidArgument, nil},
+		{"unknown field", &pb.InventoryItem{}, []string{"colour"}, codes.InvalidArgument, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestServer(t)
+			mug := insertItem(t, s, &pb.InventoryItem{Name: "Mug", Description: "Enamel", Quantity: 5, Sku: "MUG"})
+			test.update.Id = mug.Id
+			req := &pb.UpdateItemRequest{Item: test.update}
+			if test.paths != nil {
+				req.UpdateMask = &fieldmaskpb.FieldMask{Paths: test.paths}
+			}
+			_, err := s.UpdateItem(context.Background(), req)
+			checkCode(t, err, test.wantCode)
+			item, err := s.store.FindById(mug.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.want == nil {
+				if item.Version != mug.Version {
+					t.Errorf("failed update wrote the item: %v", item)
+				}
+				return
+			}
+			if !test.want(item) {
+				t.Errorf("updated item to %v", item)
+			}
+		})
+	}
+}
+
+func TestUpdateItemExpectedVersion(t *testing.T) {
+	s := newTestServer(t)
+	mug := insertItem(t, s, &pb.InventoryItem{Name: "Mug"})
+	update := func(version int64) error {
+		_, err := s.UpdateItem(context.Background(), &pb.UpdateItemRequest{
+			Item:            &pb.InventoryItem{Id: mug.Id, Name: "Big mug"},
+			UpdateMask:      &fieldmaskpb.FieldMask{Paths: []string{"name"}},
+			ExpectedVersion: version,
+		})
+		return err
+	}
+	if err := update(mug.Version); err != nil {
+		t.Fatal(err)
+	}
+	checkCode(t, update(mug.Version), codes.Aborted)
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// optionKey identifies the combination of option values of a variant,
+// independent of the order they are listed in.
+func optionKey(values []*pb.OptionValue) string {
+	pairs := make([]string, 0, len(values))
+	for _, value := range values {
+		pairs = append(pairs, value.Name+"="+value.Value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "\x00")
+}
+
+// checkOptionValues validates that values picks exactly one allowed value
+// for every option of a product.
+func checkOptionValues(options []*pb.ProductOption, values []*pb.OptionValue) error {
+	allowed := make(map[string]map[string]bool)
+	for _, option := range options {
+		allowed[option.Name] = make(map[string]bool)
+		for _, value := range option.Values {
+			allowed[option.Name][value] = true
+		}
+	}
+	seen := make(map[string]bool)
+	for _, value := range values {
+		if allowed[value.Name] == nil {
+			return &InvalidRequestError{message: "unknown option " + value.Name}
+		}
+		if !allowed[value.Name][value.Value] {
+			return &InvalidRequestError{message: "unknown value " + value.Value + " for option " + value.Name}
+		}
+		if seen[value.Name] {
+			return &InvalidRequestError{message: "option " + value.Name + " is given more than once"}
+		}
+		seen[value.Name] = true
+	}
+	if len(seen) != len(options) {
+		return &InvalidRequestError{message: "a variant needs a value for every option"}
+	}
+	return nil
+}
+
+// checkOptions validates the option axes of a product.
+func checkOptions(options []*pb.ProductOption) error {
+	names := make(map[string]bool)
+	for _, option := range options {
+		if option.Name == "" {
+			return &InvalidRequestError{message: "option name is required"}
+		}
+		if names[option.Name] {
+			return &InvalidRequestError{message: "option " + option.Name + " is defined more than once"}
+		}
+		names[option.Name] = true
+		if len(option.Values) == 0 {
+			return &InvalidRequestError{message: "option " + option.Name + " needs at least one value"}
+		}
+	}
+	return nil
+}
+
+// checkVariant validates variant as a variant of item, alongside the item's
+// other variants.
+func checkVariant(item *pb.InventoryItem, variant *pb.Variant) error {
+	if variant.Sku == "" {
+		return &InvalidRequestError{message: "variant sku is required"}
+	}
+	if err := validateMoney("variant price", variant.Price); err != nil {
+		return err
+	}
+	if variant.Price != nil && item.Price != nil && variant.Price.Currency != item.Price.Currency {
+		return &InvalidRequestError{message: "variant price must be in the same currency as the item price"}
+	}
+	if err := checkOptionValues(item.Options, variant.Options); err != nil {
+		return err
+	}
+	for _, other := range item.Variants {
+		if other == variant || (variant.Id != "" && other.Id == variant.Id) {
+			continue
+		}
+		if other.Sku == variant.Sku {
+			return status.Errorf(codes.AlreadyExists, "item %s already has a variant with sku %s", item.Id, variant.Sku)
+		}
+		if optionKey(other.Options) == optionKey(variant.Options) {
+			return status.Errorf(codes.AlreadyExists, "item %s already has a variant with these options", item.Id)
+		}
+	}
+	return nil
+}
+
+// checkItemVariants validates the options and initial variants of a new or
+// updated item.
+func checkItemVariants(item *pb.InventoryItem) error {
+	if err := checkOptions(item.GetOptions()); err != nil {
+		return err
+	}
+	for _, variant := range item.GetVariants() {
+		if variant.Quantity < 0 {
+			return &InvalidRequestError{message: "variant quantity must not be negative"}
+		}
+		if err := checkVariant(item, variant); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkOptionsUpdate validates new options of an item against its existing
+// variants.
+func (s *server) checkOptionsUpdate(item *pb.InventoryItem) error {
+	if err := checkOptions(item.Options); err != nil {
+		return err
+	}
+	existing, err := s.store.FindById(item.Id)
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, variant := range existing.Variants {
+		if err := checkOptionValues(item.Options, variant.Options); err != nil {
+			return status.Errorf(codes.FailedPrecondition, "options do not fit variant %s: %v", variant.Sku, err)
+		}
+	}
+	return nil
+}
+
+func (s *server) AddVariant(ctx context.Context, req *pb.AddVariantRequest) (*pb.AddVariantResponse, error) {
+	if req.ItemId == "" {
+		return nil, &InvalidRequestError{message: "item id is required"}
+	}
+	if req.Variant == nil {
+		return nil, &InvalidRequestError{message: "variant is required"}
+	}
+	if req.Variant.Quantity < 0 {
+		return nil, &InvalidRequestError{message: "variant quantity must not be negative"}
+	}
+	item, err := s.store.FindById(req.ItemId)
+	if err != nil {
+		return nil, err
+	}
+	variant := &pb.Variant{Sku: req.Variant.Sku, Options: req.Variant.Options, Price: req.Variant.Price, Quantity: req.Variant.Quantity}
+	if err := checkVariant(item, variant); err != nil {
+		return nil, err
+	}
+	variant, err = s.store.AddVariant(req.ItemId, variant, req.ExpectedVersion, stockChange(ctx, pb.MovementReason_MOVEMENT_RECEIVE))
+	if err != nil {
+		return nil, err
+	}
+	return &pb.AddVariantResponse{Variant: variant}, nil
+}
+
+func (s *server) UpdateVariant(ctx context.Context, req *pb.UpdateVariantRequest) (*pb.UpdateVariantResponse, error) {
+	if req.ItemId == "" {
+		return nil, &InvalidRequestError{message: "item id is required"}
+	}
+	if req.Variant.GetId() == "" {
+		return nil, &InvalidRequestError{message: "variant id is required"}
+	}
+	item, err := s.store.FindById(req.ItemId)
+	if err != nil {
+		return nil, err
+	}
+	existing := findVariant(item, req.Variant.Id)
+	if existing == nil {
+		return nil, status.Errorf(codes.NotFound, "Could not find variant %s of item %s", req.Variant.Id, req.ItemId)
+	}
+	// Validate the variant as it will be after the update.
+	updated := &pb.Variant{Id: existing.Id, Sku: existing.Sku, Options: existing.Options, Price: existing.Price}
+	paths := req.UpdateMask.GetPaths()
+	if len(paths) == 0 || containsPath(paths, "sku") {
+		updated.Sku = req.Variant.Sku
+	}
+	if len(paths) == 0 || containsPath(paths, "options") {
+		updated.Options = req.Variant.Options
+	}
+	if len(paths) == 0 || containsPath(paths, "price") {
+		updated.Price = req.Variant.Price
+	}
+	if err := checkVariant(item, updated); err != nil {
+		return nil, err
+	}
+	variant, err := s.store.UpdateVariant(req.ItemId, req.Variant, paths, req.ExpectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.UpdateVariantResponse{Variant: variant}, nil
+}
+
+func (s *server) RemoveVariant(ctx context.Context, req *pb.RemoveVariantRequest) (*pb.RemoveVariantResponse, error) {
+	if req.ItemId == "" {
+		return nil, &InvalidRequestError{message: "item id is required"}
+	}
+	if req.VariantId == "" {
+		return nil, &InvalidRequestError{message: "variant id is required"}
+	}
+	count, err := s.store.RemoveVariant(req.ItemId, req.VariantId, req.ExpectedVersion, stockChange(ctx, pb.MovementReason_MOVEMENT_ADJUSTMENT))
+	if err != nil {
+		return nil, err
+	}
+	return &pb.RemoveVariantResponse{Count: count}, nil
+}
+
+func findVariant(item *pb.InventoryItem, variantId string) *pb.Variant {
+	for _, variant := range item.Variants {
+		if variant.Id == variantId {
+			return variant
+		}
+	}
+	return nil
+}
+
+// variantBySku returns the variant of item with sku, or nil.
+func variantBySku(item *pb.InventoryItem, sku string) *pb.Variant {
+	for _, variant := range item.Variants {
+		if variant.Sku == sku {
+			return variant
+		}
+	}
+	return nil
+}
+
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
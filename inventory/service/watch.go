@@ -0,0 +1,33 @@
+package service
+
+import (
+	"github.com/joesjo/grpc-store/inventory/database"
+	pb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+func (s *server) WatchInventory(req *pb.WatchInventoryRequest, stream pb.InventoryService_WatchInventoryServer) error {
+	for _, changeType := range req.Types {
+		if _, ok := pb.ItemChangeType_name[int32(changeType)]; !ok || changeType == pb.ItemChangeType_ITEM_CHANGE_UNSPECIFIED {
+			return &InvalidRequestError{message: "unknown change type"}
+		}
+	}
+	query := database.WatchQuery{
+		ItemIds:     req.ItemIds,
+		Types:       req.Types,
+		ResumeToken: req.ResumeToken,
+	}
+	if req.Filter != nil {
+		filter := proto.Clone(req.Filter).(*pb.ItemFilter)
+		if err := s.expandSubcategories(filter); err != nil {
+			return err
+		}
+		query.Filter = filter
+	}
+	// Let the client know the request was accepted before the first change.
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+	return s.store.WatchItems(stream.Context(), query, stream.Send)
+}
@@ -0,0 +1,539 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	inventorypb "github.com/joesjo/grpc-store/inventory/protobuf"
+	"github.com/joesjo/grpc-store/shopinterface/graph/model"
+	"github.com/joesjo/grpc-store/shopinterface/serviceclient"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// defaultLocationId is the id of the inventory's default location.
+const defaultLocationId = "default"
+
+func itemFromProto(item *inventorypb.InventoryItem) *model.Item {
+	return &model.Item{
+		ID:              item.GetId(),
+		Name:            item.GetName(),
+		Sku:             optionalString(item.GetSku()),
+		Barcode:         optionalString(item.GetBarcode()),
+		Description:     item.GetDescription(),
+		Images:          imagesFromProto(item),
+		Attributes:      attributesFromProto(item.GetAttributes()),
+		Quantity:        int(item.GetQuantity()),
+		Available:       int(item.GetAvailable()),
+		Stock:           stockFromProto(item.GetStock()),
+		Backorderable:   item.GetBackorderable(),
+		ReorderPoint:    int(item.GetReorderPoint()),
+		ReorderQuantity: int(item.GetReorderQuantity()),
+		Price:           moneyFromProto(item.GetPrice()),
+		CompareAtPrice:  moneyFromProto(item.GetCompareAtPrice()),
+		CategoryIds:     append([]string{}, item.GetCategoryIds()...),
+		Options:         optionsFromProto(item.GetOptions()),
+		Variants:        variantsFromProto(item),
+		TaxClass:        model.TaxClass(strings.TrimPrefix(item.GetTaxClass().String(), "TAX_CLASS_")),
+		Version:         int(item.GetVersion()),
+		CreatedAt:       timeFromProto(item.GetCreatedAt()),
+		UpdatedAt:       timeFromProto(item.GetUpdatedAt()),
+		ArchivedAt:      timeFromProto(item.GetArchivedAt()),
+		ArchivedBy:      optionalString(item.GetArchivedBy()),
+	}
+}
+
+func imagesFromProto(item *inventorypb.InventoryItem) []*model.ItemImage {
+	result := make([]*model.ItemImage, 0, len(item.GetImages()))
+	for _, image := range item.GetImages() {
+		result = append(result, &model.ItemImage{
+			ID:          image.GetId(),
+			URL:         imageURL(item.GetId(), image.GetId()),
+			Alt:         image.GetAlt(),
+			ContentType: image.GetContentType(),
+			Size:        int(image.GetSize()),
+		})
+	}
+	return result
+}
+
+// imageURL returns where the image handler serves an image, below the
+// PUBLIC_URL the shop interface is reached at, or relative to it when that
+// is not set.
+func imageURL(itemId string, imageId string) string {
+	return strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/") + "/images/" + itemId + "/" + imageId
+}
+
+func imagesToProto(images []*model.ItemImageInput) []*inventorypb.ItemImage {
+	result := make([]*inventorypb.ItemImage, 0, len(images))
+	for _, image := range images {
+		result = append(result, &inventorypb.ItemImage{Id: image.ID, Alt: image.Alt})
+	}
+	return result
+}
+
+// attributesFromProto converts the attributes of an item, sorted by key.
+func attributesFromProto(attributes map[string]string) []*model.Attribute {
+	result := make([]*model.Attribute, 0, len(attributes))
+	for key, value := range attributes {
+		result = append(result, &model.Attribute{Key: key, Value: value})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// attributesToMap converts attributes given as a list of key/value pairs,
+// as mutation arguments or decoded from JSON, into the map the item keeps
+// them in.
+func attributesToMap(value interface{}) (map[string]string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var attributes []model.AttributeInput
+	if err := json.Unmarshal(data, &attributes); err != nil {
+		return nil, err
+	}
+	result := make(map[string]string, len(attributes))
+	for _, attribute := range attributes {
+		if _, ok := result[attribute.Key]; ok {
+			return nil, fmt.Errorf("attribute %q is given twice", attribute.Key)
+		}
+		result[attribute.Key] = attribute.Value
+	}
+	return result, nil
+}
+
+func optionsFromProto(options []*inventorypb.ProductOption) []*model.ProductOption {
+	result := make([]*model.ProductOption, 0, len(options))
+	for _, option := range options {
+		result = append(result, &model.ProductOption{Name: option.GetName(), Values: append([]string{}, option.GetValues()...)})
+	}
+	return result
+}
+
+func variantsFromProto(item *inventorypb.InventoryItem) []*model.Variant {
+	result := make([]*model.Variant, 0, len(item.GetVariants()))
+	for _, variant := range item.GetVariants() {
+		result = append(result, variantFromProto(item, variant))
+	}
+	return result
+}
+
+// variantFromProto converts a variant of item, resolving its price.
+func variantFromProto(item *inventorypb.InventoryItem, variant *inventorypb.Variant) *model.Variant {
+	options := make([]*model.OptionValue, 0, len(variant.GetOptions()))
+	for _, option := range variant.GetOptions() {
+		options = append(options, &model.OptionValue{Name: option.GetName(), Value: option.GetValue()})
+	}
+	price := variant.GetPrice()
+	if price == nil {
+		price = item.GetPrice()
+	}
+	return &model.Variant{
+		ID:        variant.GetId(),
+		Sku:       variant.GetSku(),
+		Options:   options,
+		Price:     moneyFromProto(price),
+		Quantity:  int(variant.GetQuantity()),
+		Available: int(variant.GetAvailable()),
+		Stock:     stockFromProto(variant.GetStock()),
+	}
+}
+
+// stockFromProto converts a stock breakdown by location id, listing the
+// default location first.
+func stockFromProto(stock map[string]int32) []*model.StockLevel {
+	result := make([]*model.StockLevel, 0, len(stock))
+	for locationId, quantity := range stock {
+		result = append(result, &model.StockLevel{LocationID: locationId, Quantity: int(quantity)})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if (result[i].LocationID == defaultLocationId) != (result[j].LocationID == defaultLocationId) {
+			return result[i].LocationID == defaultLocationId
+		}
+		return result[i].LocationID < result[j].LocationID
+	})
+	return result
+}
+
+func locationFromProto(location *inventorypb.Location) *model.Location {
+	return &model.Location{ID: location.GetId(), Name: location.GetName()}
+}
+
+func categoryFromProto(category *inventorypb.Category) *model.Category {
+	result := &model.Category{ID: category.GetId(), Name: category.GetName()}
+	if parentId := category.GetParentId(); parentId != "" {
+		result.ParentID = &parentId
+	}
+	return result
+}
+
+func moneyFromProto(money *inventorypb.Money) *model.Money {
+	if money == nil {
+		return nil
+	}
+	return &model.Money{Amount: int(money.GetAmount()), Currency: money.GetCurrency()}
+}
+
+func moneyToProto(money *model.MoneyInput) *inventorypb.Money {
+	if money == nil {
+		return nil
+	}
+	return &inventorypb.Money{Amount: int64(money.Amount), Currency: money.Currency}
+}
+
+func currentPriceFromProto(price *inventorypb.GetCurrentPriceResponse) *model.CurrentPrice {
+	return &model.CurrentPrice{
+		Price:            moneyFromProto(price.GetPrice()),
+		CompareAtPrice:   moneyFromProto(price.GetCompareAtPrice()),
+		ScheduledPriceID: optionalString(price.GetScheduledPriceId()),
+	}
+}
+
+func scheduledPriceFromProto(price *inventorypb.ScheduledPrice) *model.ScheduledPrice {
+	return &model.ScheduledPrice{
+		ID:             price.GetId(),
+		ItemID:         price.GetItemId(),
+		Price:          moneyFromProto(price.GetPrice()),
+		CompareAtPrice: moneyFromProto(price.GetCompareAtPrice()),
+		EffectiveFrom:  price.GetEffectiveFrom().AsTime(),
+		EffectiveTo:    timeFromProto(price.GetEffectiveTo()),
+		Status:         model.ScheduledPriceStatus(strings.TrimPrefix(price.GetStatus().String(), "PRICE_")),
+		CreatedBy:      optionalString(price.GetCreatedBy()),
+		CreatedAt:      price.GetCreatedAt().AsTime(),
+		ActivatedAt:    timeFromProto(price.GetActivatedAt()),
+		EndedAt:        timeFromProto(price.GetEndedAt()),
+	}
+}
+
+func searchResultFromProto(result *inventorypb.SearchResult) *model.SearchResult {
+	highlights := make([]*model.Highlight, 0, len(result.GetHighlights()))
+	for _, highlight := range result.GetHighlights() {
+		highlights = append(highlights, &model.Highlight{Field: highlight.GetField(), Snippet: highlight.GetSnippet()})
+	}
+	return &model.SearchResult{
+		Item:       itemFromProto(result.GetItem()),
+		Score:      result.GetScore(),
+		Highlights: highlights,
+	}
+}
+
+func timeFromProto(timestamp *timestamppb.Timestamp) *time.Time {
+	if timestamp == nil {
+		return nil
+	}
+	t := timestamp.AsTime()
+	return &t
+}
+
+func timeToProto(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+// findItemsRequest builds the inventory request for a findItems query. The
+// page fields are filled in per page by the caller.
+func findItemsRequest(name *string, filter *model.ItemFilter, sortBy *model.ItemSortField, sortDirection *model.SortDirection) *inventorypb.FindItemsRequest {
+	req := &inventorypb.FindItemsRequest{}
+	if name != nil {
+		req.Name = *name
+	}
+	if sortBy != nil {
+		req.SortBy = inventorypb.ItemSortField(inventorypb.ItemSortField_value["ITEM_SORT_"+sortBy.String()])
+	}
+	if sortDirection != nil && *sortDirection == model.SortDirectionDesc {
+		req.SortDirection = inventorypb.SortDirection_SORT_DESCENDING
+	}
+	if filter == nil {
+		return req
+	}
+	req.Filter = &inventorypb.ItemFilter{
+		InStockOnly:          filter.InStockOnly != nil && *filter.InStockOnly,
+		CategoryIds:          filter.CategoryIds,
+		IncludeSubcategories: filter.IncludeSubcategories != nil && *filter.IncludeSubcategories,
+		CreatedAt:            timeRangeToProto(filter.CreatedAt),
+		UpdatedAt:            timeRangeToProto(filter.UpdatedAt),
+	}
+	if filter.Archived != nil {
+		req.Filter.Archived = inventorypb.ArchiveFilter(inventorypb.ArchiveFilter_value["ARCHIVED_"+filter.Archived.String()])
+	}
+	if match := filter.Name; match != nil {
+		req.Filter.Name = &inventorypb.StringMatch{
+			Value:           match.Value,
+			CaseInsensitive: match.CaseInsensitive != nil && *match.CaseInsensitive,
+		}
+		if match.Mode != nil {
+			req.Filter.Name.Mode = inventorypb.StringMatchMode(inventorypb.StringMatchMode_value["MATCH_"+match.Mode.String()])
+		}
+	}
+	if quantity := filter.Quantity; quantity != nil {
+		req.Filter.Quantity = &inventorypb.QuantityRange{}
+		if quantity.Min != nil {
+			min := int32(*quantity.Min)
+			req.Filter.Quantity.Min = &min
+		}
+		if quantity.Max != nil {
+			max := int32(*quantity.Max)
+			req.Filter.Quantity.Max = &max
+		}
+	}
+	return req
+}
+
+func timeRangeToProto(timeRange *model.TimeRange) *inventorypb.TimeRange {
+	if timeRange == nil {
+		return nil
+	}
+	return &inventorypb.TimeRange{From: timeToProto(timeRange.From), To: timeToProto(timeRange.To)}
+}
+
+// versionFromArg converts an optional version argument into the expected
+// version sent to the inventory service, where zero skips the check.
+func versionFromArg(version *int) int64 {
+	if version == nil {
+		return 0
+	}
+	return int64(*version)
+}
+
+// itemVariant fetches an item and converts one of its variants.
+func itemVariant(ctx context.Context, itemId string, variantId string) (*model.Variant, error) {
+	item, err := serviceclient.GetItem(ctx, itemId)
+	if err != nil {
+		return nil, err
+	}
+	for _, variant := range item.GetVariants() {
+		if variant.GetId() == variantId {
+			return variantFromProto(item, variant), nil
+		}
+	}
+	return nil, fmt.Errorf("could not find variant %s of item %s", variantId, itemId)
+}
+
+// stringFromArg converts an optional string argument, where omitted means
+// empty.
+func stringFromArg(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// optionalString converts an optional protobuf string, where empty means
+// unset.
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func reservationFromProto(reservation *inventorypb.Reservation) *model.Reservation {
+	return &model.Reservation{
+		ID:         reservation.GetId(),
+		VariantID:  optionalString(reservation.GetVariantId()),
+		LocationID: optionalString(reservation.GetLocationId()),
+		ItemID:     reservation.GetItemId(),
+		Quantity:   int(reservation.GetQuantity()),
+		Status:     model.ReservationStatus(strings.TrimPrefix(reservation.GetStatus().String(), "RESERVATION_")),
+		ExpiresAt:  reservation.GetExpiresAt().AsTime(),
+	}
+}
+
+func movementFromProto(movement *inventorypb.StockMovement) *model.StockMovement {
+	return &model.StockMovement{
+		ID:         movement.GetId(),
+		VariantID:  optionalString(movement.GetVariantId()),
+		LocationID: movement.GetLocationId(),
+		Reason:     model.MovementReason(strings.TrimPrefix(movement.GetReason().String(), "MOVEMENT_")),
+		Actor:      optionalString(movement.GetActor()),
+		Amount:     int(movement.GetAmount()),
+		Balance:    int(movement.GetBalance()),
+		CreatedAt:  movement.GetCreatedAt().AsTime(),
+	}
+}
+
+// movementReasonToProto converts an optional movement reason, leaving the
+// default to the inventory service when it is omitted.
+func movementReasonToProto(reason *model.MovementReason) inventorypb.MovementReason {
+	if reason == nil {
+		return inventorypb.MovementReason_MOVEMENT_UNSPECIFIED
+	}
+	return inventorypb.MovementReason(inventorypb.MovementReason_value["MOVEMENT_"+string(*reason)])
+}
+
+func itemChangeFromProto(change *inventorypb.ItemChange) *model.ItemChange {
+	result := &model.ItemChange{
+		ResumeToken: change.GetResumeToken(),
+		Type:        model.ItemChangeType(strings.TrimPrefix(change.GetType().String(), "ITEM_")),
+		ItemID:      change.GetItemId(),
+		OccurredAt:  change.GetOccurredAt().AsTime(),
+	}
+	if change.Item != nil {
+		result.Item = itemFromProto(change.Item)
+	}
+	return result
+}
+
+func itemChangeTypesToProto(types []model.ItemChangeType) []inventorypb.ItemChangeType {
+	var result []inventorypb.ItemChangeType
+	for _, changeType := range types {
+		result = append(result, inventorypb.ItemChangeType(inventorypb.ItemChangeType_value["ITEM_"+string(changeType)]))
+	}
+	return result
+}
+
+// enumPrefixes maps mutation arguments of enum types to the prefix of the
+// matching protobuf enum value names.
+var enumPrefixes = map[string]string{
+	"taxClass": "TAX_CLASS_",
+}
+
+// itemChanges collects the arguments given to the current mutation into an
+// item and the field mask paths naming them. Arguments listed in skip and
+// omitted optional arguments are ignored. Argument names match the protobuf
+// field names, so new optional arguments are picked up without changes here.
+func itemChanges(ctx context.Context, skip ...string) (*inventorypb.InventoryItem, []string, error) {
+	item := &inventorypb.InventoryItem{}
+	paths, err := argumentChanges(ctx, item, skip...)
+	return item, paths, err
+}
+
+// variantChanges is itemChanges for variant mutations.
+func variantChanges(ctx context.Context, skip ...string) (*inventorypb.Variant, []string, error) {
+	variant := &inventorypb.Variant{}
+	paths, err := argumentChanges(ctx, variant, skip...)
+	return variant, paths, err
+}
+
+// argumentChanges unmarshals the arguments of the current mutation into
+// message and returns their names. The client mutation id is passed on to
+// the services separately.
+func argumentChanges(ctx context.Context, message proto.Message, skip ...string) ([]string, error) {
+	return fieldChanges(graphql.GetFieldContext(ctx).Args, message, append(skip, "clientMutationId")...)
+}
+
+// fieldChanges unmarshals the given fields into message and returns their
+// names, leaving out those listed in skip and those without a value.
+func fieldChanges(fields map[string]interface{}, message proto.Message, skip ...string) ([]string, error) {
+	ignored := map[string]bool{}
+	for _, name := range skip {
+		ignored[name] = true
+	}
+	changes := map[string]interface{}{}
+	for name, value := range fields {
+		if ignored[name] || value == nil {
+			continue
+		}
+		if v := reflect.ValueOf(value); (v.Kind() == reflect.Ptr || v.Kind() == reflect.Slice) && v.IsNil() {
+			continue
+		}
+		if prefix, ok := enumPrefixes[name]; ok {
+			value = prefix + fmt.Sprint(reflect.Indirect(reflect.ValueOf(value)))
+		}
+		if name == "attributes" {
+			attributes, err := attributesToMap(value)
+			if err != nil {
+				return nil, err
+			}
+			value = attributes
+		}
+		changes[name] = value
+	}
+	paths := make([]string, 0, len(changes))
+	for name := range changes {
+		paths = append(paths, name)
+	}
+	sort.Strings(paths)
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return nil, err
+	}
+	if err := protojson.Unmarshal(data, message); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// itemUpdatesToProto converts the updates of a batch into update requests
+// the way updateItem converts its arguments.
+func itemUpdatesToProto(updates []*model.ItemUpdate) ([]*inventorypb.UpdateItemRequest, error) {
+	requests := make([]*inventorypb.UpdateItemRequest, 0, len(updates))
+	for _, update := range updates {
+		data, err := json.Marshal(update)
+		if err != nil {
+			return nil, err
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		item := &inventorypb.InventoryItem{}
+		paths, err := fieldChanges(fields, item, "_id", "version")
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no fields to update for item %s", update.ID)
+		}
+		item.Id = update.ID
+		requests = append(requests, &inventorypb.UpdateItemRequest{
+			Item:            item,
+			ExpectedVersion: versionFromArg(update.Version),
+			UpdateMask:      &fieldmaskpb.FieldMask{Paths: paths},
+		})
+	}
+	return requests, nil
+}
+
+func incrementsToProto(increments []*model.IncrementItem) []*inventorypb.IncrementItemQuantityRequest {
+	requests := make([]*inventorypb.IncrementItemQuantityRequest, 0, len(increments))
+	for _, input := range increments {
+		requests = append(requests, &inventorypb.IncrementItemQuantityRequest{
+			Id:              input.ID,
+			VariantId:       stringFromArg(input.VariantID),
+			LocationId:      stringFromArg(input.LocationID),
+			Amount:          int32(input.Quantity),
+			Reason:          movementReasonToProto(input.Reason),
+			AllowBackorder:  input.AllowBackorder != nil && *input.AllowBackorder,
+			ExpectedVersion: versionFromArg(input.Version),
+		})
+	}
+	return requests
+}
+
+func itemsFromProto(items []*inventorypb.InventoryItem) []*model.Item {
+	result := make([]*model.Item, 0, len(items))
+	for _, item := range items {
+		result = append(result, itemFromProto(item))
+	}
+	return result
+}
+
+// listCategories returns the children of parentId, or the top-level
+// categories when parentId is empty.
+func listCategories(ctx context.Context, parentId string) ([]*model.Category, error) {
+	categories, err := serviceclient.ListCategories(ctx, parentId)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*model.Category, 0, len(categories))
+	for _, category := range categories {
+		result = append(result, categoryFromProto(category))
+	}
+	return result, nil
+}
@@ -0,0 +1,25 @@
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"google.golang.org/grpc/status"
+)
+
+// PresentError adds the gRPC status code of errors returned by the backing
+// services to the error extensions, so clients can tell for example a
+// version conflict (Aborted) apart from a missing item (NotFound).
+func PresentError(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &grpcErr) {
+		if gqlErr.Extensions == nil {
+			gqlErr.Extensions = map[string]interface{}{}
+		}
+		gqlErr.Extensions["code"] = grpcErr.GRPCStatus().Code().String()
+	}
+	return gqlErr
+}
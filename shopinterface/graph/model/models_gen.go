@@ -2,13 +2,698 @@
 
 package model
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+type Attribute struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type AttributeInput struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type Category struct {
+	ID       string          `json:"_id"`
+	Name     string          `json:"name"`
+	ParentID *string         `json:"parentId"`
+	Parent   *Category       `json:"parent"`
+	Children []*Category     `json:"children"`
+	Items    *ItemConnection `json:"items"`
+}
+
+type CurrentPrice struct {
+	Price          *Money `json:"price"`
+	CompareAtPrice *Money `json:"compareAtPrice"`
+	// The scheduled price in effect, if any.
+	ScheduledPriceID *string `json:"scheduledPriceId"`
+}
+
+type Highlight struct {
+	Field   string `json:"field"`
+	Snippet string `json:"snippet"`
+}
+
 type IncrementItem struct {
-	ID       string `json:"_id"`
-	Quantity int    `json:"quantity"`
+	ID        string  `json:"_id"`
+	VariantID *string `json:"variantId"`
+	// Defaults to the default location for increments and to the location with the most stock for decrements.
+	LocationID     *string `json:"locationId"`
+	Quantity       int     `json:"quantity"`
+	AllowBackorder *bool   `json:"allowBackorder"`
+	Version        *int    `json:"version"`
+	// Defaults to RECEIVE for increments and PURCHASE for decrements.
+	Reason *MovementReason `json:"reason"`
 }
 
+type IntRange struct {
+	Min *int `json:"min"`
+	Max *int `json:"max"`
+}
+
+// An item is a product. Products with variants keep their stock on the variants, and their quantity fields are the sums over them.
 type Item struct {
-	ID       string `json:"_id"`
-	Name     string `json:"name"`
-	Quantity int    `json:"quantity"`
+	ID      string  `json:"_id"`
+	Name    string  `json:"name"`
+	Sku     *string `json:"sku"`
+	Barcode *string `json:"barcode"`
+	// The description of the item, in Markdown.
+	Description string `json:"description"`
+	// The images of the item, in display order.
+	Images []*ItemImage `json:"images"`
+	// Free-form properties of the item, such as material or size, sorted by key.
+	Attributes []*Attribute `json:"attributes"`
+	// The total quantity over all locations.
+	Quantity  int `json:"quantity"`
+	Available int `json:"available"`
+	// The quantity at each location holding stock.
+	Stock         []*StockLevel `json:"stock"`
+	Backorderable bool          `json:"backorderable"`
+	// The item is low on stock once its quantity drops to this level. Zero turns alerts off.
+	ReorderPoint int `json:"reorderPoint"`
+	// How much to order when the item runs low.
+	ReorderQuantity int    `json:"reorderQuantity"`
+	Price           *Money `json:"price"`
+	CompareAtPrice  *Money `json:"compareAtPrice"`
+	// The price at the time of the request, taking scheduled prices into account.
+	CurrentPrice *CurrentPrice `json:"currentPrice"`
+	// The scheduled prices of the item, in the order they take effect.
+	PriceHistory []*ScheduledPrice `json:"priceHistory"`
+	TaxClass     TaxClass          `json:"taxClass"`
+	CategoryIds  []string          `json:"categoryIds"`
+	Options      []*ProductOption  `json:"options"`
+	Variants     []*Variant        `json:"variants"`
+	// The stock ledger of the item, oldest first.
+	Movements []*StockMovement `json:"movements"`
+	Version   int              `json:"version"`
+	CreatedAt *time.Time       `json:"createdAt"`
+	UpdatedAt *time.Time       `json:"updatedAt"`
+	// When the item was deleted, for archived items.
+	ArchivedAt *time.Time `json:"archivedAt"`
+	// The user that deleted the item, if known.
+	ArchivedBy *string `json:"archivedBy"`
+}
+
+type ItemChange struct {
+	// Pass to inventoryChanged to resume after this change.
+	ResumeToken string         `json:"resumeToken"`
+	Type        ItemChangeType `json:"type"`
+	ItemID      string         `json:"itemId"`
+	// The item after the change. Null for deletions.
+	Item       *Item     `json:"item"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+type ItemConnection struct {
+	Edges      []*ItemEdge `json:"edges"`
+	PageInfo   *PageInfo   `json:"pageInfo"`
+	TotalCount int         `json:"totalCount"`
+}
+
+type ItemEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Item  `json:"node"`
+}
+
+type ItemFilter struct {
+	Name                 *StringMatch `json:"name"`
+	Quantity             *IntRange    `json:"quantity"`
+	InStockOnly          *bool        `json:"inStockOnly"`
+	CreatedAt            *TimeRange   `json:"createdAt"`
+	UpdatedAt            *TimeRange   `json:"updatedAt"`
+	CategoryIds          []string     `json:"categoryIds"`
+	IncludeSubcategories *bool        `json:"includeSubcategories"`
+	// Defaults to EXCLUDE.
+	Archived *ArchiveFilter `json:"archived"`
+}
+
+type ItemImage struct {
+	ID string `json:"_id"`
+	// Where the image is served from.
+	URL string `json:"url"`
+	// Describes the image for those who cannot see it.
+	Alt         string `json:"alt"`
+	ContentType string `json:"contentType"`
+	// The size of the image in bytes.
+	Size int `json:"size"`
+}
+
+type ItemImageInput struct {
+	ID  string `json:"_id"`
+	Alt string `json:"alt"`
+}
+
+// The fields of updateItem, for batch updates.
+type ItemUpdate struct {
+	ID              string                `json:"_id"`
+	Name            *string               `json:"name"`
+	Quantity        *int                  `json:"quantity"`
+	Backorderable   *bool                 `json:"backorderable"`
+	Sku             *string               `json:"sku"`
+	Barcode         *string               `json:"barcode"`
+	Description     *string               `json:"description"`
+	Price           *MoneyInput           `json:"price"`
+	CompareAtPrice  *MoneyInput           `json:"compareAtPrice"`
+	TaxClass        *TaxClass             `json:"taxClass"`
+	CategoryIds     []string              `json:"categoryIds"`
+	Options         []*ProductOptionInput `json:"options"`
+	Attributes      []*AttributeInput     `json:"attributes"`
+	ReorderPoint    *int                  `json:"reorderPoint"`
+	ReorderQuantity *int                  `json:"reorderQuantity"`
+	Version         *int                  `json:"version"`
+}
+
+// A place stock is kept. The default location holds all stock not placed anywhere else.
+type Location struct {
+	ID   string `json:"_id"`
+	Name string `json:"name"`
+}
+
+// An amount in the minor unit of an ISO-4217 currency, such as cents for USD.
+type Money struct {
+	Amount   int    `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+type MoneyInput struct {
+	Amount   int    `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+type OptionValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type OptionValueInput struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type PageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor"`
+}
+
+type ProductOption struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+type ProductOptionInput struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+type Reservation struct {
+	ID        string            `json:"_id"`
+	ItemID    string            `json:"itemId"`
+	Quantity  int               `json:"quantity"`
+	Status    ReservationStatus `json:"status"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+	VariantID *string           `json:"variantId"`
+	// The location the stock was taken from once committed.
+	LocationID *string `json:"locationId"`
+}
+
+// A price of an item that holds over a window of time, such as a sale.
+type ScheduledPrice struct {
+	ID             string    `json:"_id"`
+	ItemID         string    `json:"itemId"`
+	Price          *Money    `json:"price"`
+	CompareAtPrice *Money    `json:"compareAtPrice"`
+	EffectiveFrom  time.Time `json:"effectiveFrom"`
+	// Unset for prices that hold until they are cancelled.
+	EffectiveTo *time.Time           `json:"effectiveTo"`
+	Status      ScheduledPriceStatus `json:"status"`
+	// The user that scheduled the price, if known.
+	CreatedBy   *string    `json:"createdBy"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ActivatedAt *time.Time `json:"activatedAt"`
+	// When the price expired or was cancelled.
+	EndedAt *time.Time `json:"endedAt"`
+}
+
+type SearchResult struct {
+	Item       *Item        `json:"item"`
+	Score      float64      `json:"score"`
+	Highlights []*Highlight `json:"highlights"`
+}
+
+type StockLevel struct {
+	LocationID string    `json:"locationId"`
+	Location   *Location `json:"location"`
+	Quantity   int       `json:"quantity"`
+}
+
+// A change of stock at a location, as recorded in the stock ledger.
+type StockMovement struct {
+	ID         string         `json:"_id"`
+	VariantID  *string        `json:"variantId"`
+	LocationID string         `json:"locationId"`
+	Reason     MovementReason `json:"reason"`
+	// The user that made the change, if known.
+	Actor *string `json:"actor"`
+	// The change in stock, negative for stock leaving.
+	Amount int `json:"amount"`
+	// The quantity of the item, or of the variant, after the change.
+	Balance   int       `json:"balance"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type StringMatch struct {
+	Value           string           `json:"value"`
+	Mode            *StringMatchMode `json:"mode"`
+	CaseInsensitive *bool            `json:"caseInsensitive"`
+}
+
+type TimeRange struct {
+	From *time.Time `json:"from"`
+	To   *time.Time `json:"to"`
+}
+
+type Variant struct {
+	ID      string         `json:"_id"`
+	Sku     string         `json:"sku"`
+	Options []*OptionValue `json:"options"`
+	// The variant's own price if it overrides the product price, otherwise the product price.
+	Price     *Money        `json:"price"`
+	Quantity  int           `json:"quantity"`
+	Available int           `json:"available"`
+	Stock     []*StockLevel `json:"stock"`
+}
+
+type ArchiveFilter string
+
+const (
+	// Only items that have not been deleted.
+	ArchiveFilterExclude ArchiveFilter = "EXCLUDE"
+	ArchiveFilterInclude ArchiveFilter = "INCLUDE"
+	// Only deleted items that have not been purged yet.
+	ArchiveFilterOnly ArchiveFilter = "ONLY"
+)
+
+var AllArchiveFilter = []ArchiveFilter{
+	ArchiveFilterExclude,
+	ArchiveFilterInclude,
+	ArchiveFilterOnly,
+}
+
+func (e ArchiveFilter) IsValid() bool {
+	switch e {
+	case ArchiveFilterExclude, ArchiveFilterInclude, ArchiveFilterOnly:
+		return true
+	}
+	return false
+}
+
+func (e ArchiveFilter) String() string {
+	return string(e)
+}
+
+func (e *ArchiveFilter) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ArchiveFilter(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ArchiveFilter", str)
+	}
+	return nil
+}
+
+func (e ArchiveFilter) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ItemChangeType string
+
+const (
+	ItemChangeTypeCreated ItemChangeType = "CREATED"
+	// Any change other than one of stock, such as renaming the item or adding a variant.
+	ItemChangeTypeUpdated ItemChangeType = "UPDATED"
+	ItemChangeTypeDeleted ItemChangeType = "DELETED"
+	// Changes of quantity, reserved stock or stock by location only.
+	ItemChangeTypeQuantityChanged ItemChangeType = "QUANTITY_CHANGED"
+	ItemChangeTypeArchived        ItemChangeType = "ARCHIVED"
+	ItemChangeTypeRestored        ItemChangeType = "RESTORED"
+)
+
+var AllItemChangeType = []ItemChangeType{
+	ItemChangeTypeCreated,
+	ItemChangeTypeUpdated,
+	ItemChangeTypeDeleted,
+	ItemChangeTypeQuantityChanged,
+	ItemChangeTypeArchived,
+	ItemChangeTypeRestored,
+}
+
+func (e ItemChangeType) IsValid() bool {
+	switch e {
+	case ItemChangeTypeCreated, ItemChangeTypeUpdated, ItemChangeTypeDeleted, ItemChangeTypeQuantityChanged, ItemChangeTypeArchived, ItemChangeTypeRestored:
+		return true
+	}
+	return false
+}
+
+func (e ItemChangeType) String() string {
+	return string(e)
+}
+
+func (e *ItemChangeType) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ItemChangeType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ItemChangeType", str)
+	}
+	return nil
+}
+
+func (e ItemChangeType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ItemSortField string
+
+const (
+	ItemSortFieldID        ItemSortField = "ID"
+	ItemSortFieldName      ItemSortField = "NAME"
+	ItemSortFieldQuantity  ItemSortField = "QUANTITY"
+	ItemSortFieldCreatedAt ItemSortField = "CREATED_AT"
+	ItemSortFieldUpdatedAt ItemSortField = "UPDATED_AT"
+)
+
+var AllItemSortField = []ItemSortField{
+	ItemSortFieldID,
+	ItemSortFieldName,
+	ItemSortFieldQuantity,
+	ItemSortFieldCreatedAt,
+	ItemSortFieldUpdatedAt,
+}
+
+func (e ItemSortField) IsValid() bool {
+	switch e {
+	case ItemSortFieldID, ItemSortFieldName, ItemSortFieldQuantity, ItemSortFieldCreatedAt, ItemSortFieldUpdatedAt:
+		return true
+	}
+	return false
+}
+
+func (e ItemSortField) String() string {
+	return string(e)
+}
+
+func (e *ItemSortField) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ItemSortField(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ItemSortField", str)
+	}
+	return nil
+}
+
+func (e ItemSortField) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type MovementReason string
+
+const (
+	MovementReasonReceive    MovementReason = "RECEIVE"
+	MovementReasonPurchase   MovementReason = "PURCHASE"
+	MovementReasonAdjustment MovementReason = "ADJUSTMENT"
+	MovementReasonReturn     MovementReason = "RETURN"
+	MovementReasonTransfer   MovementReason = "TRANSFER"
+)
+
+var AllMovementReason = []MovementReason{
+	MovementReasonReceive,
+	MovementReasonPurchase,
+	MovementReasonAdjustment,
+	MovementReasonReturn,
+	MovementReasonTransfer,
+}
+
+func (e MovementReason) IsValid() bool {
+	switch e {
+	case MovementReasonReceive, MovementReasonPurchase, MovementReasonAdjustment, MovementReasonReturn, MovementReasonTransfer:
+		return true
+	}
+	return false
+}
+
+func (e MovementReason) String() string {
+	return string(e)
+}
+
+func (e *MovementReason) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = MovementReason(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid MovementReason", str)
+	}
+	return nil
+}
+
+func (e MovementReason) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ReservationStatus string
+
+const (
+	ReservationStatusPending   ReservationStatus = "PENDING"
+	ReservationStatusCommitted ReservationStatus = "COMMITTED"
+	ReservationStatusReleased  ReservationStatus = "RELEASED"
+	ReservationStatusExpired   ReservationStatus = "EXPIRED"
+)
+
+var AllReservationStatus = []ReservationStatus{
+	ReservationStatusPending,
+	ReservationStatusCommitted,
+	ReservationStatusReleased,
+	ReservationStatusExpired,
+}
+
+func (e ReservationStatus) IsValid() bool {
+	switch e {
+	case ReservationStatusPending, ReservationStatusCommitted, ReservationStatusReleased, ReservationStatusExpired:
+		return true
+	}
+	return false
+}
+
+func (e ReservationStatus) String() string {
+	return string(e)
+}
+
+func (e *ReservationStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ReservationStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ReservationStatus", str)
+	}
+	return nil
+}
+
+func (e ReservationStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ScheduledPriceStatus string
+
+const (
+	ScheduledPriceStatusScheduled ScheduledPriceStatus = "SCHEDULED"
+	ScheduledPriceStatusActive    ScheduledPriceStatus = "ACTIVE"
+	ScheduledPriceStatusExpired   ScheduledPriceStatus = "EXPIRED"
+	ScheduledPriceStatusCancelled ScheduledPriceStatus = "CANCELLED"
+)
+
+var AllScheduledPriceStatus = []ScheduledPriceStatus{
+	ScheduledPriceStatusScheduled,
+	ScheduledPriceStatusActive,
+	ScheduledPriceStatusExpired,
+	ScheduledPriceStatusCancelled,
+}
+
+func (e ScheduledPriceStatus) IsValid() bool {
+	switch e {
+	case ScheduledPriceStatusScheduled, ScheduledPriceStatusActive, ScheduledPriceStatusExpired, ScheduledPriceStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func (e ScheduledPriceStatus) String() string {
+	return string(e)
+}
+
+func (e *ScheduledPriceStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ScheduledPriceStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ScheduledPriceStatus", str)
+	}
+	return nil
+}
+
+func (e ScheduledPriceStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type SortDirection string
+
+const (
+	SortDirectionAsc  SortDirection = "ASC"
+	SortDirectionDesc SortDirection = "DESC"
+)
+
+var AllSortDirection = []SortDirection{
+	SortDirectionAsc,
+	SortDirectionDesc,
+}
+
+func (e SortDirection) IsValid() bool {
+	switch e {
+	case SortDirectionAsc, SortDirectionDesc:
+		return true
+	}
+	return false
+}
+
+func (e SortDirection) String() string {
+	return string(e)
+}
+
+func (e *SortDirection) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SortDirection(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SortDirection", str)
+	}
+	return nil
+}
+
+func (e SortDirection) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type StringMatchMode string
+
+const (
+	StringMatchModeSubstring StringMatchMode = "SUBSTRING"
+	StringMatchModePrefix    StringMatchMode = "PREFIX"
+	StringMatchModeExact     StringMatchMode = "EXACT"
+)
+
+var AllStringMatchMode = []StringMatchMode{
+	StringMatchModeSubstring,
+	StringMatchModePrefix,
+	StringMatchModeExact,
+}
+
+func (e StringMatchMode) IsValid() bool {
+	switch e {
+	case StringMatchModeSubstring, StringMatchModePrefix, StringMatchModeExact:
+		return true
+	}
+	return false
+}
+
+func (e StringMatchMode) String() string {
+	return string(e)
+}
+
+func (e *StringMatchMode) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = StringMatchMode(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid StringMatchMode", str)
+	}
+	return nil
+}
+
+func (e StringMatchMode) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type TaxClass string
+
+const (
+	TaxClassStandard TaxClass = "STANDARD"
+	TaxClassReduced  TaxClass = "REDUCED"
+	TaxClassZero     TaxClass = "ZERO"
+	TaxClassExempt   TaxClass = "EXEMPT"
+)
+
+var AllTaxClass = []TaxClass{
+	TaxClassStandard,
+	TaxClassReduced,
+	TaxClassZero,
+	TaxClassExempt,
+}
+
+func (e TaxClass) IsValid() bool {
+	switch e {
+	case TaxClassStandard, TaxClassReduced, TaxClassZero, TaxClassExempt:
+		return true
+	}
+	return false
+}
+
+func (e TaxClass) String() string {
+	return string(e)
+}
+
+func (e *TaxClass) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = TaxClass(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid TaxClass", str)
+	}
+	return nil
+}
+
+func (e TaxClass) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
 }
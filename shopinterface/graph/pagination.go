@@ -0,0 +1,96 @@
+package graph
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/joesjo/grpc-store/shopinterface/graph/model"
+	"github.com/joesjo/grpc-store/shopinterface/serviceclient"
+)
+
+const defaultPageSize = 50
+
+// itemCursor locates an edge within a listing: the inventory page token its
+// page was fetched with, and how many items of that page come up to and
+// including the edge.
+type itemCursor struct {
+	Token string `json:"t,omitempty"`
+	Skip  int    `json:"s,omitempty"`
+}
+
+func encodeCursor(cursor itemCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value *string) (itemCursor, error) {
+	var cursor itemCursor
+	if value == nil || *value == "" {
+		return cursor, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(*value)
+	if err != nil {
+		return cursor, fmt.Errorf("invalid cursor")
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, fmt.Errorf("invalid cursor")
+	}
+	return cursor, nil
+}
+
+// itemConnection loads the first items after a cursor using fetch and wraps
+// them in a Relay connection.
+func itemConnection(first *int, after *string, fetch func(pageSize int32, pageToken string) (*serviceclient.ItemPage, error)) (*model.ItemConnection, error) {
+	size := defaultPageSize
+	if first != nil {
+		size = *first
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("first must not be negative")
+	}
+	cursor, err := decodeCursor(after)
+	if err != nil {
+		return nil, err
+	}
+	connection := &model.ItemConnection{
+		Edges:    []*model.ItemEdge{},
+		PageInfo: &model.PageInfo{},
+	}
+	if size == 0 {
+		// Still look one item ahead so totalCount and hasNextPage are set.
+		page, err := fetch(int32(cursor.Skip+1), cursor.Token)
+		if err != nil {
+			return nil, err
+		}
+		connection.TotalCount = int(page.TotalCount)
+		connection.PageInfo.HasNextPage = len(page.Items) > cursor.Skip || page.NextPageToken != ""
+		return connection, nil
+	}
+	page, err := fetch(int32(cursor.Skip+size), cursor.Token)
+	if err != nil {
+		return nil, err
+	}
+	connection.TotalCount = int(page.TotalCount)
+	items := page.Items
+	if cursor.Skip < len(items) {
+		items = items[cursor.Skip:]
+	} else {
+		items = nil
+	}
+	for i, item := range items {
+		edgeCursor := itemCursor{Token: cursor.Token, Skip: cursor.Skip + i + 1}
+		if i == len(items)-1 && page.NextPageToken != "" {
+			edgeCursor = itemCursor{Token: page.NextPageToken}
+		}
+		connection.Edges = append(connection.Edges, &model.ItemEdge{
+			Cursor: encodeCursor(edgeCursor),
+			Node:   itemFromProto(item),
+		})
+	}
+	if len(connection.Edges) > 0 {
+		connection.PageInfo.EndCursor = &connection.Edges[len(connection.Edges)-1].Cursor
+	}
+	connection.PageInfo.HasNextPage = page.NextPageToken != ""
+	return connection, nil
+}
@@ -6,73 +6,333 @@ package graph
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/joesjo/grpc-store/shopinterface/graph/generated"
 	"github.com/joesjo/grpc-store/shopinterface/graph/model"
 	"github.com/joesjo/grpc-store/shopinterface/serviceclient"
 )
 
-func (r *mutationResolver) CreateItem(ctx context.Context, name string, quantity int) (*model.Item, error) {
-	itemId, err := serviceclient.CreateItem(name, int32(quantity))
+func (r *categoryResolver) Parent(ctx context.Context, obj *model.Category) (*model.Category, error) {
+	if obj.ParentID == nil {
+		return nil, nil
+	}
+	parent, err := serviceclient.GetCategory(ctx, *obj.ParentID)
+	if err != nil {
+		return nil, err
+	}
+	return categoryFromProto(parent), nil
+}
+
+func (r *categoryResolver) Children(ctx context.Context, obj *model.Category) ([]*model.Category, error) {
+	return listCategories(ctx, obj.ID)
+}
+
+func (r *categoryResolver) Items(ctx context.Context, obj *model.Category, first *int, after *string, includeSubcategories *bool) (*model.ItemConnection, error) {
+	filter := &model.ItemFilter{CategoryIds: []string{obj.ID}, IncludeSubcategories: includeSubcategories}
+	req := findItemsRequest(nil, filter, nil, nil)
+	return itemConnection(first, after, func(pageSize int32, pageToken string) (*serviceclient.ItemPage, error) {
+		req.PageSize = pageSize
+		req.PageToken = pageToken
+		return serviceclient.FindItems(ctx, req)
+	})
+}
+
+func (r *itemResolver) CurrentPrice(ctx context.Context, obj *model.Item) (*model.CurrentPrice, error) {
+	price, err := serviceclient.GetCurrentPrice(ctx, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	return currentPriceFromProto(price), nil
+}
+
+func (r *itemResolver) PriceHistory(ctx context.Context, obj *model.Item) ([]*model.ScheduledPrice, error) {
+	prices, err := serviceclient.GetPriceHistory(ctx, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*model.ScheduledPrice, 0, len(prices))
+	for _, price := range prices {
+		result = append(result, scheduledPriceFromProto(price))
+	}
+	return result, nil
+}
+
+func (r *itemResolver) Movements(ctx context.Context, obj *model.Item, variantID *string, locationID *string) ([]*model.StockMovement, error) {
+	movements, err := serviceclient.ListStockMovements(ctx, obj.ID, stringFromArg(variantID), stringFromArg(locationID))
+	if err != nil {
+		return nil, err
+	}
+	result := []*model.StockMovement{}
+	for _, movement := range movements {
+		result = append(result, movementFromProto(movement))
+	}
+	return result, nil
+}
+
+func (r *mutationResolver) CreateItem(ctx context.Context, name string, quantity int, backorderable *bool, sku *string, barcode *string, description *string, price *model.MoneyInput, compareAtPrice *model.MoneyInput, taxClass *model.TaxClass, categoryIds []string, options []*model.ProductOptionInput, attributes []*model.AttributeInput, reorderPoint *int, reorderQuantity *int, clientMutationID *string) (*model.Item, error) {
+	newItem, _, err := itemChanges(ctx)
+	if err != nil {
+		return nil, err
+	}
+	itemId, err := serviceclient.CreateItem(ctx, newItem)
+	if err != nil {
+		return nil, err
+	}
+	item, err := serviceclient.GetItem(ctx, itemId)
+	if err != nil {
+		return nil, err
+	}
+	return itemFromProto(item), nil
+}
+
+func (r *mutationResolver) UpdateItem(ctx context.Context, id string, name *string, quantity *int, backorderable *bool, sku *string, barcode *string, description *string, price *model.MoneyInput, compareAtPrice *model.MoneyInput, taxClass *model.TaxClass, categoryIds []string, options []*model.ProductOptionInput, attributes []*model.AttributeInput, reorderPoint *int, reorderQuantity *int, version *int, clientMutationID *string) (*model.Item, error) {
+	changes, paths, err := itemChanges(ctx, "_id", "version")
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no fields to update")
+	}
+	changes.Id = id
+	err = serviceclient.UpdateItem(ctx, changes, paths, versionFromArg(version))
+	if err != nil {
+		return nil, err
+	}
+	item, err := serviceclient.GetItem(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return itemFromProto(item), nil
+}
+
+func (r *mutationResolver) UploadItemImage(ctx context.Context, itemID string, file graphql.Upload, alt *string, version *int, clientMutationID *string) (*model.Item, error) {
+	item, err := serviceclient.UploadItemImage(ctx, itemID, stringFromArg(alt), file.File, versionFromArg(version))
+	if err != nil {
+		return nil, err
+	}
+	return itemFromProto(item), nil
+}
+
+func (r *mutationResolver) SetItemImages(ctx context.Context, itemID string, images []*model.ItemImageInput, version *int, clientMutationID *string) (*model.Item, error) {
+	item, err := serviceclient.SetItemImages(ctx, itemID, imagesToProto(images), versionFromArg(version))
+	if err != nil {
+		return nil, err
+	}
+	return itemFromProto(item), nil
+}
+
+func (r *mutationResolver) DeleteItem(ctx context.Context, id string, version *int, clientMutationID *string) (bool, error) {
+	err := serviceclient.DeleteItem(ctx, id, versionFromArg(version))
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) RestoreItem(ctx context.Context, id string, version *int, clientMutationID *string) (*model.Item, error) {
+	item, err := serviceclient.RestoreItem(ctx, id, versionFromArg(version))
 	if err != nil {
 		return nil, err
 	}
-	item, err := serviceclient.GetItem(itemId)
+	return itemFromProto(item), nil
+}
+
+func (r *mutationResolver) PurgeItem(ctx context.Context, id string, version *int, clientMutationID *string) (bool, error) {
+	err := serviceclient.PurgeItem(ctx, id, versionFromArg(version))
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) IncrementItem(ctx context.Context, input model.IncrementItem, clientMutationID *string) (*model.Item, error) {
+	err := serviceclient.StockItem(ctx, input.ID, stringFromArg(input.VariantID), stringFromArg(input.LocationID), int32(input.Quantity), movementReasonToProto(input.Reason), input.AllowBackorder != nil && *input.AllowBackorder, versionFromArg(input.Version))
 	if err != nil {
 		return nil, err
 	}
-	return &model.Item{
-		ID:       item.GetId(),
-		Name:     item.GetName(),
-		Quantity: int(item.GetQuantity()),
-	}, nil
+	item, err := serviceclient.GetItem(ctx, input.ID)
+	if err != nil {
+		return nil, err
+	}
+	return itemFromProto(item), nil
 }
 
-func (r *mutationResolver) UpdateItem(ctx context.Context, id string, name *string, quantity *int) (*model.Item, error) {
-	if name == nil || quantity == nil {
-		return nil, fmt.Errorf("optional parameters are not supported yet")
+func (r *mutationResolver) BatchUpdateItems(ctx context.Context, input []*model.ItemUpdate, clientMutationID *string) ([]*model.Item, error) {
+	requests, err := itemUpdatesToProto(input)
+	if err != nil {
+		return nil, err
 	}
-	err := serviceclient.UpdateItem(id, *name, int32(*quantity))
+	items, err := serviceclient.BatchUpdateItems(ctx, requests)
 	if err != nil {
 		return nil, err
 	}
-	item, err := serviceclient.GetItem(id)
+	return itemsFromProto(items), nil
+}
+
+func (r *mutationResolver) BatchAdjustQuantities(ctx context.Context, input []*model.IncrementItem, clientMutationID *string) ([]*model.Item, error) {
+	items, err := serviceclient.BatchAdjustQuantities(ctx, incrementsToProto(input))
 	if err != nil {
 		return nil, err
 	}
-	return &model.Item{
-		ID:       item.GetId(),
-		Name:     item.GetName(),
-		Quantity: int(item.GetQuantity()),
-	}, nil
+	return itemsFromProto(items), nil
 }
 
-func (r *mutationResolver) DeleteItem(ctx context.Context, id string) (bool, error) {
-	err := serviceclient.DeleteItem(id)
+func (r *mutationResolver) AddVariant(ctx context.Context, itemID string, sku string, options []*model.OptionValueInput, price *model.MoneyInput, quantity *int, version *int, clientMutationID *string) (*model.Variant, error) {
+	changes, _, err := variantChanges(ctx, "itemId", "version")
+	if err != nil {
+		return nil, err
+	}
+	variant, err := serviceclient.AddVariant(ctx, itemID, changes, versionFromArg(version))
+	if err != nil {
+		return nil, err
+	}
+	return itemVariant(ctx, itemID, variant.GetId())
+}
+
+func (r *mutationResolver) UpdateVariant(ctx context.Context, itemID string, id string, sku *string, options []*model.OptionValueInput, price *model.MoneyInput, version *int, clientMutationID *string) (*model.Variant, error) {
+	changes, paths, err := variantChanges(ctx, "itemId", "_id", "version")
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no fields to update")
+	}
+	changes.Id = id
+	_, err = serviceclient.UpdateVariant(ctx, itemID, changes, paths, versionFromArg(version))
+	if err != nil {
+		return nil, err
+	}
+	return itemVariant(ctx, itemID, id)
+}
+
+func (r *mutationResolver) RemoveVariant(ctx context.Context, itemID string, id string, version *int, clientMutationID *string) (bool, error) {
+	err := serviceclient.RemoveVariant(ctx, itemID, id, versionFromArg(version))
 	if err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
-func (r *mutationResolver) IncrementItem(ctx context.Context, input model.IncrementItem) (*model.Item, error) {
-	err := serviceclient.StockItem(input.ID, int32(input.Quantity))
+func (r *mutationResolver) ReserveItem(ctx context.Context, id string, variantID *string, quantity int, ttlSeconds *int, clientMutationID *string) (*model.Reservation, error) {
+	var ttl int64
+	if ttlSeconds != nil {
+		ttl = int64(*ttlSeconds)
+	}
+	reservation, err := serviceclient.ReserveItem(ctx, id, stringFromArg(variantID), int32(quantity), ttl)
+	if err != nil {
+		return nil, err
+	}
+	return reservationFromProto(reservation), nil
+}
+
+func (r *mutationResolver) CommitReservation(ctx context.Context, id string, locationID *string, clientMutationID *string) (*model.Reservation, error) {
+	reservation, err := serviceclient.CommitReservation(ctx, id, stringFromArg(locationID))
+	if err != nil {
+		return nil, err
+	}
+	return reservationFromProto(reservation), nil
+}
+
+func (r *mutationResolver) ReleaseReservation(ctx context.Context, id string, clientMutationID *string) (*model.Reservation, error) {
+	reservation, err := serviceclient.ReleaseReservation(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	item, err := serviceclient.GetItem(input.ID)
+	return reservationFromProto(reservation), nil
+}
+
+func (r *mutationResolver) SchedulePrice(ctx context.Context, itemID string, price model.MoneyInput, compareAtPrice *model.MoneyInput, effectiveFrom *time.Time, effectiveTo *time.Time, clientMutationID *string) (*model.ScheduledPrice, error) {
+	scheduled, err := serviceclient.SchedulePrice(ctx, itemID, moneyToProto(&price), moneyToProto(compareAtPrice), timeToProto(effectiveFrom), timeToProto(effectiveTo))
 	if err != nil {
 		return nil, err
 	}
-	return &model.Item{
-		ID:       item.GetId(),
-		Name:     item.GetName(),
-		Quantity: int(item.GetQuantity()),
-	}, nil
+	return scheduledPriceFromProto(scheduled), nil
+}
+
+func (r *mutationResolver) CancelScheduledPrice(ctx context.Context, id string, clientMutationID *string) (*model.ScheduledPrice, error) {
+	cancelled, err := serviceclient.CancelScheduledPrice(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return scheduledPriceFromProto(cancelled), nil
+}
+
+func (r *mutationResolver) CreateCategory(ctx context.Context, name string, parentID *string, clientMutationID *string) (*model.Category, error) {
+	var parent string
+	if parentID != nil {
+		parent = *parentID
+	}
+	category, err := serviceclient.CreateCategory(ctx, name, parent)
+	if err != nil {
+		return nil, err
+	}
+	return categoryFromProto(category), nil
+}
+
+func (r *mutationResolver) RenameCategory(ctx context.Context, id string, name string, clientMutationID *string) (*model.Category, error) {
+	category, err := serviceclient.RenameCategory(ctx, id, name)
+	if err != nil {
+		return nil, err
+	}
+	return categoryFromProto(category), nil
+}
+
+func (r *mutationResolver) MoveCategory(ctx context.Context, id string, parentID *string, clientMutationID *string) (*model.Category, error) {
+	var parent string
+	if parentID != nil {
+		parent = *parentID
+	}
+	category, err := serviceclient.MoveCategory(ctx, id, parent)
+	if err != nil {
+		return nil, err
+	}
+	return categoryFromProto(category), nil
+}
+
+func (r *mutationResolver) DeleteCategory(ctx context.Context, id string, clientMutationID *string) (bool, error) {
+	err := serviceclient.DeleteCategory(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) CreateLocation(ctx context.Context, name string, clientMutationID *string) (*model.Location, error) {
+	location, err := serviceclient.CreateLocation(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return locationFromProto(location), nil
+}
+
+func (r *mutationResolver) RenameLocation(ctx context.Context, id string, name string, clientMutationID *string) (*model.Location, error) {
+	location, err := serviceclient.RenameLocation(ctx, id, name)
+	if err != nil {
+		return nil, err
+	}
+	return locationFromProto(location), nil
+}
+
+func (r *mutationResolver) DeleteLocation(ctx context.Context, id string, clientMutationID *string) (bool, error) {
+	err := serviceclient.DeleteLocation(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) TransferStock(ctx context.Context, itemID string, variantID *string, fromLocationID string, toLocationID string, quantity int, version *int, clientMutationID *string) (*model.Item, error) {
+	item, err := serviceclient.TransferStock(ctx, itemID, stringFromArg(variantID), fromLocationID, toLocationID, int32(quantity), versionFromArg(version))
+	if err != nil {
+		return nil, err
+	}
+	return itemFromProto(item), nil
 }
 
 func (r *mutationResolver) CreateUser(ctx context.Context, username string, password string) (bool, error) {
-	response, err := serviceclient.CreateUser(username, password)
+	response, err := serviceclient.CreateUser(ctx, username, password)
 	if err != nil {
 		return false, err
 	}
@@ -82,52 +342,115 @@ func (r *mutationResolver) CreateUser(ctx context.Context, username string, pass
 	return true, err
 }
 
-func (r *queryResolver) Items(ctx context.Context) ([]*model.Item, error) {
-	itemArray, err := serviceclient.GetInventory()
+func (r *queryResolver) Items(ctx context.Context, first *int, after *string) (*model.ItemConnection, error) {
+	return itemConnection(first, after, func(pageSize int32, pageToken string) (*serviceclient.ItemPage, error) {
+		return serviceclient.GetInventory(ctx, pageSize, pageToken)
+	})
+}
+
+func (r *queryResolver) Item(ctx context.Context, id string) (*model.Item, error) {
+	item, err := serviceclient.GetItem(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	items := make([]*model.Item, len(itemArray))
-	for i, item := range itemArray {
-		items[i] = &model.Item{
-			ID:       item.GetId(),
-			Name:     item.GetName(),
-			Quantity: int(item.GetQuantity()),
-		}
+	return itemFromProto(item), nil
+}
+
+func (r *queryResolver) ItemBySku(ctx context.Context, sku string) (*model.Item, error) {
+	item, err := serviceclient.GetItemBySku(ctx, sku)
+	if err != nil {
+		return nil, err
 	}
-	return items, nil
+	return itemFromProto(item), nil
 }
 
-func (r *queryResolver) Item(ctx context.Context, id string) (*model.Item, error) {
-	item, err := serviceclient.GetItem(id)
+func (r *queryResolver) ItemByBarcode(ctx context.Context, barcode string) (*model.Item, error) {
+	item, err := serviceclient.GetItemByBarcode(ctx, barcode)
 	if err != nil {
 		return nil, err
 	}
-	return &model.Item{
-		ID:       item.GetId(),
-		Name:     item.GetName(),
-		Quantity: int(item.GetQuantity()),
-	}, nil
+	return itemFromProto(item), nil
 }
 
-func (r *queryResolver) FindItems(ctx context.Context, name string) ([]*model.Item, error) {
-	itemArray, err := serviceclient.FindItems(name)
+func (r *queryResolver) Categories(ctx context.Context) ([]*model.Category, error) {
+	return listCategories(ctx, "")
+}
+
+func (r *queryResolver) Category(ctx context.Context, id string) (*model.Category, error) {
+	category, err := serviceclient.GetCategory(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	items := make([]*model.Item, len(itemArray))
-	for i, item := range itemArray {
-		items[i] = &model.Item{
-			ID:       item.GetId(),
-			Name:     item.GetName(),
-			Quantity: int(item.GetQuantity()),
-		}
+	return categoryFromProto(category), nil
+}
+
+func (r *queryResolver) Locations(ctx context.Context) ([]*model.Location, error) {
+	locations, err := serviceclient.ListLocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*model.Location, 0, len(locations))
+	for _, location := range locations {
+		result = append(result, locationFromProto(location))
+	}
+	return result, nil
+}
+
+func (r *queryResolver) Location(ctx context.Context, id string) (*model.Location, error) {
+	location, err := serviceclient.GetLocation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return locationFromProto(location), nil
+}
+
+func (r *queryResolver) Search(ctx context.Context, query string, limit *int, fuzzy *bool) ([]*model.SearchResult, error) {
+	var maxResults int32
+	if limit != nil {
+		maxResults = int32(*limit)
+	}
+	results, err := serviceclient.SearchItems(ctx, query, maxResults, fuzzy == nil || *fuzzy)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*model.SearchResult, 0, len(results))
+	for _, result := range results {
+		items = append(items, searchResultFromProto(result))
 	}
 	return items, nil
 }
 
+func (r *queryResolver) FindItems(ctx context.Context, name *string, filter *model.ItemFilter, sortBy *model.ItemSortField, sortDirection *model.SortDirection, first *int, after *string) (*model.ItemConnection, error) {
+	req := findItemsRequest(name, filter, sortBy, sortDirection)
+	return itemConnection(first, after, func(pageSize int32, pageToken string) (*serviceclient.ItemPage, error) {
+		req.PageSize = pageSize
+		req.PageToken = pageToken
+		return serviceclient.FindItems(ctx, req)
+	})
+}
+
+func (r *queryResolver) LowStockItems(ctx context.Context) ([]*model.Item, error) {
+	items, err := serviceclient.ListLowStockItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := []*model.Item{}
+	for _, item := range items {
+		result = append(result, itemFromProto(item))
+	}
+	return result, nil
+}
+
+func (r *queryResolver) ItemsByIds(ctx context.Context, ids []string) ([]*model.Item, error) {
+	items, err := serviceclient.BatchGetItems(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return itemsFromProto(items), nil
+}
+
 func (r *queryResolver) Login(ctx context.Context, username string, password string) (string, error) {
-	token, err := serviceclient.Login(username, password)
+	token, err := serviceclient.Login(ctx, username, password)
 	if err != nil {
 		return "", err
 	}
@@ -135,28 +458,97 @@ func (r *queryResolver) Login(ctx context.Context, username string, password str
 }
 
 func (r *queryResolver) ValidateToken(ctx context.Context, token string) (string, error) {
-	username, err := serviceclient.ValidateToken(token)
+	username, err := serviceclient.ValidateToken(ctx, token)
 	if err != nil {
 		return "", err
 	}
 	return username, nil
 }
 
+func (r *stockLevelResolver) Location(ctx context.Context, obj *model.StockLevel) (*model.Location, error) {
+	location, err := serviceclient.GetLocation(ctx, obj.LocationID)
+	if err != nil {
+		return nil, err
+	}
+	return locationFromProto(location), nil
+}
+
+func (r *subscriptionResolver) ItemUpdated(ctx context.Context, id string) (<-chan *model.Item, error) {
+	changes, err := serviceclient.WatchInventory(ctx, []string{id}, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := serviceclient.GetItem(ctx, id); err != nil {
+		return nil, err
+	}
+	items := make(chan *model.Item)
+	go func() {
+		defer close(items)
+		for change := range changes {
+			if change.GetItem() == nil {
+				// The item was deleted.
+				return
+			}
+			select {
+			case items <- itemFromProto(change.Item):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return items, nil
+}
+
+func (r *subscriptionResolver) InventoryChanged(ctx context.Context, itemIds []string, types []model.ItemChangeType, resumeToken *string) (<-chan *model.ItemChange, error) {
+	changes, err := serviceclient.WatchInventory(ctx, itemIds, itemChangeTypesToProto(types), stringFromArg(resumeToken))
+	if err != nil {
+		return nil, err
+	}
+	result := make(chan *model.ItemChange)
+	go func() {
+		defer close(result)
+		for change := range changes {
+			select {
+			case result <- itemChangeFromProto(change):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return result, nil
+}
+
+// Category returns generated.CategoryResolver implementation.
+func (r *Resolver) Category() generated.CategoryResolver { return &categoryResolver{r} }
+
+// Item returns generated.ItemResolver implementation.
+func (r *Resolver) Item() generated.ItemResolver { return &itemResolver{r} }
+
 // Mutation returns generated.MutationResolver implementation.
 func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResolver{r} }
 
 // Query returns generated.QueryResolver implementation.
 func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
 
+// StockLevel returns generated.StockLevelResolver implementation.
+func (r *Resolver) StockLevel() generated.StockLevelResolver { return &stockLevelResolver{r} }
+
+// Subscription returns generated.SubscriptionResolver implementation.
+func (r *Resolver) Subscription() generated.SubscriptionResolver { return &subscriptionResolver{r} }
+
+type categoryResolver struct{ *Resolver }
+type itemResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type stockLevelResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
 
 // !!! WARNING !!!
 // The code below was going to be deleted when updating resolvers. It has been copied here so you have
 // one last chance to move it out of harms way if you want. There are two reasons this happens:
-//  - When renaming or deleting a resolver the old code will be put in here. You can safely delete
-//    it when you're done.
-//  - You have helper methods in this file. Move them out to keep these resolver files clean.
+//   - When renaming or deleting a resolver the old code will be put in here. You can safely delete
+//     it when you're done.
+//   - You have helper methods in this file. Move them out to keep these resolver files clean.
 func (r *queryResolver) FindItem(ctx context.Context, name string) (*model.Item, error) {
 	panic(fmt.Errorf("not implemented"))
 }
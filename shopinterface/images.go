@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/joesjo/grpc-store/shopinterface/serviceclient"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// serveImage serves the images of items at /images/{itemId}/{imageId}, the
+// URLs the GraphQL API hands out. Image ids are never reused, so images may
+// be cached for good.
+func serveImage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ids := strings.Split(strings.TrimPrefix(r.URL.Path, "/images/"), "/")
+	if len(ids) != 2 || ids[0] == "" || ids[1] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	ctx := serviceclient.WithClient(r.Context(), r.RemoteAddr, r.UserAgent())
+	contentType, image, err := serviceclient.DownloadItemImage(ctx, ids[0], ids[1])
+	if err != nil {
+		switch status.Code(err) {
+		case codes.NotFound, codes.InvalidArgument:
+			http.NotFound(w, r)
+		default:
+			log.Println("Downloading image failed:", r.URL.Path, err)
+			http.Error(w, "could not load image", http.StatusBadGateway)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(w, image); err != nil {
+		log.Println("Sending image failed:", r.URL.Path, err)
+	}
+}
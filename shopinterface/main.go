@@ -4,12 +4,18 @@ import (
 	// gin
 
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql"
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/joesjo/grpc-store/shopinterface/graph"
 	"github.com/joesjo/grpc-store/shopinterface/graph/generated"
@@ -27,16 +33,71 @@ func main() {
 		port = defaultPort
 	}
 
-	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: &graph.Resolver{}}))
+	srv := handler.New(generated.NewExecutableSchema(generated.Config{Resolvers: &graph.Resolver{}}))
+	// Subscriptions are served over websockets. Browsers cannot set headers
+	// on those, so the token may also come in the connection payload.
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		InitFunc: func(ctx context.Context, payload transport.InitPayload) (context.Context, error) {
+			return authenticateToken(ctx, payload.Authorization())
+		},
+	})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{})
+	srv.SetQueryCache(lru.New(1000))
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.AutomaticPersistedQuery{Cache: lru.New(100)})
+	srv.SetErrorPresenter(graph.PresentError)
 	srv.AroundOperations(func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
 		oc := graphql.GetOperationContext(ctx)
 		log.Println("Calling: " + oc.Operation.Name)
 		return next(ctx)
 	})
+	// The client mutation id of a mutation becomes the idempotency key of
+	// the writes it makes.
+	srv.AroundFields(func(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+		fc := graphql.GetFieldContext(ctx)
+		if id, ok := fc.Args["clientMutationId"].(*string); ok && id != nil && fc.Object == "Mutation" {
+			ctx = serviceclient.WithIdempotencyKey(ctx, *id)
+		}
+		return next(ctx)
+	})
 
 	http.Handle("/", playground.Handler("GraphQL playground", "/query"))
-	http.Handle("/query", srv)
+	http.Handle("/query", authenticate(srv))
+	http.HandleFunc("/images/", serveImage)
 
 	log.Printf("connect to http://localhost:%s/ for GraphQL playground", port)
 	log.Fatal(http.ListenAndServe(":"+port, nil))
 }
+
+// authenticate validates the bearer token a request may carry and passes
+// the user it belongs to on to the services as the actor of the request,
+// along with the address and user agent of the client. Requests without a
+// token are let through anonymously.
+func authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := serviceclient.WithClient(r.Context(), r.RemoteAddr, r.UserAgent())
+		ctx, err := authenticateToken(ctx, r.Header.Get("Authorization"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticateToken validates the bearer token in an authorization value,
+// if there is one, and returns ctx passing it on to the services.
+func authenticateToken(ctx context.Context, authorization string) (context.Context, error) {
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	if token == "" {
+		return ctx, nil
+	}
+	if _, err := serviceclient.ValidateToken(ctx, token); err != nil {
+		return nil, errors.New("invalid token")
+	}
+	return serviceclient.WithToken(ctx, token), nil
+}
@@ -5,15 +5,23 @@ import (
 	"io"
 	"log"
 	"os"
+	"strconv"
 
+	"github.com/joesjo/grpc-store/audit"
 	authenticationpb "github.com/joesjo/grpc-store/authentication/protobuf"
+	"github.com/joesjo/grpc-store/authentication/security"
 	inventorypb "github.com/joesjo/grpc-store/inventory/protobuf"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 const (
 	INVENTORY_URI      = "localhost:8082"
 	AUTHENTICATION_URI = "localhost:8081"
+
+	IMAGE_CHUNK_SIZE = 32 << 10
 )
 
 var (
@@ -39,7 +47,9 @@ func Init() {
 }
 
 func connectInventory(url string) inventorypb.InventoryServiceClient {
-	conn, err := grpc.Dial(url, grpc.WithInsecure())
+	conn, err := grpc.Dial(url, grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(callerUnaryInterceptor),
+		grpc.WithStreamInterceptor(callerStreamInterceptor))
 	if err != nil {
 		panic(err)
 	}
@@ -47,8 +57,65 @@ func connectInventory(url string) inventorypb.InventoryServiceClient {
 	return inventorypb.NewInventoryServiceClient(conn)
 }
 
+type tokenKey struct{}
+
+type clientKey struct{}
+
+type idempotencyKey struct{}
+
+type client struct {
+	address   string
+	userAgent string
+}
+
+// WithToken returns a copy of ctx with the bearer token of the user that
+// requests made with it are made for. The services verify the token and
+// record its user as the actor of any stock changes and in the audit log.
+func WithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+// WithClient returns a copy of ctx naming the client that requests made
+// with it are made for, for the audit log of the services.
+func WithClient(ctx context.Context, address string, userAgent string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client{address: address, userAgent: userAgent})
+}
+
+// WithIdempotencyKey returns a copy of ctx with a key that makes the writes
+// requested with it idempotent: the inventory service replays the response
+// of the first request with the key to any retries.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// withCallerMetadata passes the token, client and idempotency key of ctx on
+// to the service called with it.
+func withCallerMetadata(ctx context.Context) context.Context {
+	if token, ok := ctx.Value(tokenKey{}).(string); ok && token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, security.AuthorizationMetadataKey, "Bearer "+token)
+	}
+	if caller, ok := ctx.Value(clientKey{}).(client); ok {
+		ctx = metadata.AppendToOutgoingContext(ctx,
+			audit.ClientMetadataKey, caller.address,
+			audit.UserAgentMetadataKey, caller.userAgent)
+	}
+	if key, ok := ctx.Value(idempotencyKey{}).(string); ok && key != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, inventorypb.IdempotencyKeyMetadataKey, key)
+	}
+	return ctx
+}
+
+func callerUnaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(withCallerMetadata(ctx), method, req, reply, cc, opts...)
+}
+
+func callerStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(withCallerMetadata(ctx), desc, cc, method, opts...)
+}
+
 func connectAuthentication(url string) authenticationpb.AuthenticationServiceClient {
-	conn, err := grpc.Dial(url, grpc.WithInsecure())
+	conn, err := grpc.Dial(url, grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(callerUnaryInterceptor))
 	if err != nil {
 		panic(err)
 	}
@@ -56,13 +123,22 @@ func connectAuthentication(url string) authenticationpb.AuthenticationServiceCli
 	return authenticationpb.NewAuthenticationServiceClient(conn)
 }
 
-// Get inventory using grpc stream
-func GetInventory() ([]*inventorypb.InventoryItem, error) {
-	stream, err := inventoryClient.GetInventory(context.Background(), &inventorypb.Empty{})
-	if err != nil {
-		return nil, err
-	}
-	var items []*inventorypb.InventoryItem
+// ItemPage is one page of an inventory listing.
+type ItemPage struct {
+	Items         []*inventorypb.InventoryItem
+	NextPageToken string
+	TotalCount    int64
+}
+
+type itemStream interface {
+	Recv() (*inventorypb.InventoryItem, error)
+	Trailer() metadata.MD
+}
+
+// receivePage reads a page of items from a listing stream along with the
+// paging details the inventory service sends in its trailer.
+func receivePage(stream itemStream) (*ItemPage, error) {
+	page := &ItemPage{}
 	for {
 		item, err := stream.Recv()
 		if err != nil {
@@ -71,81 +147,499 @@ func GetInventory() ([]*inventorypb.InventoryItem, error) {
 			}
 			return nil, err
 		}
-		items = append(items, item)
+		page.Items = append(page.Items, item)
 	}
-	return items, nil
+	trailer := stream.Trailer()
+	if values := trailer.Get("next-page-token"); len(values) > 0 {
+		page.NextPageToken = values[0]
+	}
+	if values := trailer.Get("total-count"); len(values) > 0 {
+		page.TotalCount, _ = strconv.ParseInt(values[0], 10, 64)
+	}
+	return page, nil
+}
+
+// Get a page of the inventory using grpc stream
+func GetInventory(ctx context.Context, pageSize int32, pageToken string) (*ItemPage, error) {
+	itemRequest := &inventorypb.GetInventoryRequest{PageSize: pageSize, PageToken: pageToken}
+	stream, err := inventoryClient.GetInventory(ctx, itemRequest)
+	if err != nil {
+		return nil, err
+	}
+	return receivePage(stream)
 }
 
-func GetItem(itemId string) (*inventorypb.InventoryItem, error) {
+func GetItem(ctx context.Context, itemId string) (*inventorypb.InventoryItem, error) {
 	itemRequest := &inventorypb.GetItemRequest{Id: itemId}
-	item, err := inventoryClient.GetItem(context.Background(), itemRequest)
+	item, err := inventoryClient.GetItem(ctx, itemRequest)
+	return item.GetItem(), err
+}
+
+func GetItemBySku(ctx context.Context, sku string) (*inventorypb.InventoryItem, error) {
+	item, err := inventoryClient.GetItemBySku(ctx, &inventorypb.GetItemBySkuRequest{Sku: sku})
 	return item.GetItem(), err
 }
 
-func FindItems(name string) ([]*inventorypb.InventoryItem, error) {
-	itemRequest := &inventorypb.FindItemsRequest{Name: name}
-	stream, err := inventoryClient.FindItems(context.Background(), itemRequest)
+func GetItemByBarcode(ctx context.Context, barcode string) (*inventorypb.InventoryItem, error) {
+	item, err := inventoryClient.GetItemByBarcode(ctx, &inventorypb.GetItemByBarcodeRequest{Barcode: barcode})
+	return item.GetItem(), err
+}
+
+func FindItems(ctx context.Context, itemRequest *inventorypb.FindItemsRequest) (*ItemPage, error) {
+	stream, err := inventoryClient.FindItems(ctx, itemRequest)
 	if err != nil {
 		return nil, err
 	}
-	var items []*inventorypb.InventoryItem
+	return receivePage(stream)
+}
+
+// SearchItems returns the items matching query, most relevant first.
+func SearchItems(ctx context.Context, query string, limit int32, fuzzy bool) ([]*inventorypb.SearchResult, error) {
+	searchRequest := &inventorypb.SearchItemsRequest{Query: query, Limit: limit, Exact: !fuzzy}
+	stream, err := inventoryClient.SearchItems(ctx, searchRequest)
+	if err != nil {
+		return nil, err
+	}
+	var results []*inventorypb.SearchResult
 	for {
-		item, err := stream.Recv()
+		result, err := stream.Recv()
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
 			return nil, err
 		}
-		items = append(items, item)
+		results = append(results, result)
 	}
-	return items, nil
+	return results, nil
 }
 
-func CreateItem(name string, quantity int32) (string, error) {
-	itemRequest := &inventorypb.InsertItemRequest{Item: &inventorypb.InventoryItem{Name: name, Quantity: quantity}}
-	itemId, err := inventoryClient.InsertItem(context.Background(), itemRequest)
+func CreateItem(ctx context.Context, item *inventorypb.InventoryItem) (string, error) {
+	itemRequest := &inventorypb.InsertItemRequest{Item: item}
+	itemId, err := inventoryClient.InsertItem(ctx, itemRequest)
 	return itemId.GetItemId(), err
 }
 
-func StockItem(itemId string, quantity int32) error {
-	itemRequest := &inventorypb.IncrementItemQuantityRequest{Id: itemId, Amount: quantity}
-	_, err := inventoryClient.IncrementItemQuantity(context.Background(), itemRequest)
+func StockItem(ctx context.Context, itemId string, variantId string, locationId string, quantity int32, reason inventorypb.MovementReason, allowBackorder bool, version int64) error {
+	itemRequest := &inventorypb.IncrementItemQuantityRequest{Id: itemId, VariantId: variantId, LocationId: locationId, Amount: quantity, Reason: reason, AllowBackorder: allowBackorder, ExpectedVersion: version}
+	_, err := inventoryClient.IncrementItemQuantity(ctx, itemRequest)
+	return err
+}
+
+func PurchaseItem(ctx context.Context, itemId string, variantId string, locationId string, quantity int32, allowBackorder bool, version int64) error {
+	itemRequest := &inventorypb.IncrementItemQuantityRequest{Id: itemId, VariantId: variantId, LocationId: locationId, Amount: -quantity, Reason: inventorypb.MovementReason_MOVEMENT_PURCHASE, AllowBackorder: allowBackorder, ExpectedVersion: version}
+	_, err := inventoryClient.IncrementItemQuantity(ctx, itemRequest)
 	return err
 }
 
-func PurchaseItem(itemId string, quantity int32) error {
-	itemRequest := &inventorypb.IncrementItemQuantityRequest{Id: itemId, Amount: -quantity}
-	_, err := inventoryClient.IncrementItemQuantity(context.Background(), itemRequest)
+// UpdateItem writes the fields of item named by paths, leaving all other
+// fields of the stored item untouched.
+func UpdateItem(ctx context.Context, item *inventorypb.InventoryItem, paths []string, version int64) error {
+	itemRequest := &inventorypb.UpdateItemRequest{
+		Item:            item,
+		ExpectedVersion: version,
+		UpdateMask:      &fieldmaskpb.FieldMask{Paths: paths},
+	}
+	_, err := inventoryClient.UpdateItem(ctx, itemRequest)
 	return err
 }
 
-func UpdateItem(itemId string, name string, quantity int32) error {
-	itemRequest := &inventorypb.UpdateItemRequest{Item: &inventorypb.InventoryItem{Id: itemId, Name: name, Quantity: quantity}}
-	_, err := inventoryClient.UpdateItem(context.Background(), itemRequest)
+// BatchGetItems returns the items with the given ids in that order.
+func BatchGetItems(ctx context.Context, itemIds []string) ([]*inventorypb.InventoryItem, error) {
+	response, err := inventoryClient.BatchGetItems(ctx, &inventorypb.BatchGetItemsRequest{Ids: itemIds})
+	return response.GetItems(), err
+}
+
+// BatchUpdateItems applies all updates or none, and returns the items after
+// them.
+func BatchUpdateItems(ctx context.Context, requests []*inventorypb.UpdateItemRequest) ([]*inventorypb.InventoryItem, error) {
+	response, err := inventoryClient.BatchUpdateItems(ctx, &inventorypb.BatchUpdateItemsRequest{Requests: requests})
+	return response.GetItems(), err
+}
+
+// BatchAdjustQuantities applies all stock changes or none, and returns the
+// items after them.
+func BatchAdjustQuantities(ctx context.Context, requests []*inventorypb.IncrementItemQuantityRequest) ([]*inventorypb.InventoryItem, error) {
+	response, err := inventoryClient.BatchAdjustQuantities(ctx, &inventorypb.BatchAdjustQuantitiesRequest{Requests: requests})
+	return response.GetItems(), err
+}
+
+func ReserveItem(ctx context.Context, itemId string, variantId string, quantity int32, ttlSeconds int64) (*inventorypb.Reservation, error) {
+	reserveRequest := &inventorypb.ReserveStockRequest{ItemId: itemId, VariantId: variantId, Quantity: quantity, TtlSeconds: ttlSeconds}
+	response, err := inventoryClient.ReserveStock(ctx, reserveRequest)
+	return response.GetReservation(), err
+}
+
+func CommitReservation(ctx context.Context, reservationId string, locationId string) (*inventorypb.Reservation, error) {
+	commitRequest := &inventorypb.CommitReservationRequest{Id: reservationId, LocationId: locationId}
+	response, err := inventoryClient.CommitReservation(ctx, commitRequest)
+	return response.GetReservation(), err
+}
+
+func ReleaseReservation(ctx context.Context, reservationId string) (*inventorypb.Reservation, error) {
+	releaseRequest := &inventorypb.ReleaseReservationRequest{Id: reservationId}
+	response, err := inventoryClient.ReleaseReservation(ctx, releaseRequest)
+	return response.GetReservation(), err
+}
+
+func AddVariant(ctx context.Context, itemId string, variant *inventorypb.Variant, version int64) (*inventorypb.Variant, error) {
+	variantRequest := &inventorypb.AddVariantRequest{ItemId: itemId, Variant: variant, ExpectedVersion: version}
+	response, err := inventoryClient.AddVariant(ctx, variantRequest)
+	return response.GetVariant(), err
+}
+
+// UpdateVariant writes the fields of variant named by paths, leaving all
+// other fields of the stored variant as they are.
+func UpdateVariant(ctx context.Context, itemId string, variant *inventorypb.Variant, paths []string, version int64) (*inventorypb.Variant, error) {
+	variantRequest := &inventorypb.UpdateVariantRequest{
+		ItemId:          itemId,
+		Variant:         variant,
+		ExpectedVersion: version,
+		UpdateMask:      &fieldmaskpb.FieldMask{Paths: paths},
+	}
+	response, err := inventoryClient.UpdateVariant(ctx, variantRequest)
+	return response.GetVariant(), err
+}
+
+func RemoveVariant(ctx context.Context, itemId string, variantId string, version int64) error {
+	variantRequest := &inventorypb.RemoveVariantRequest{ItemId: itemId, VariantId: variantId, ExpectedVersion: version}
+	_, err := inventoryClient.RemoveVariant(ctx, variantRequest)
 	return err
 }
 
-func DeleteItem(itemId string) error {
-	itemRequest := &inventorypb.DeleteItemRequest{Id: itemId}
-	_, err := inventoryClient.DeleteItem(context.Background(), itemRequest)
+func DeleteItem(ctx context.Context, itemId string, version int64) error {
+	itemRequest := &inventorypb.DeleteItemRequest{Id: itemId, ExpectedVersion: version}
+	_, err := inventoryClient.DeleteItem(ctx, itemRequest)
+	return err
+}
+
+func RestoreItem(ctx context.Context, itemId string, version int64) (*inventorypb.InventoryItem, error) {
+	restoreRequest := &inventorypb.RestoreItemRequest{Id: itemId, ExpectedVersion: version}
+	response, err := inventoryClient.RestoreItem(ctx, restoreRequest)
+	if err != nil {
+		return nil, err
+	}
+	return response.Item, nil
+}
+
+func PurgeItem(ctx context.Context, itemId string, version int64) error {
+	purgeRequest := &inventorypb.PurgeItemRequest{Id: itemId, ExpectedVersion: version}
+	_, err := inventoryClient.PurgeItem(ctx, purgeRequest)
 	return err
 }
 
-func CreateUser(username string, password string) (string, error) {
+// UploadItemImage streams an image read from data to the inventory
+// service, which adds it after the images of the item.
+func UploadItemImage(ctx context.Context, itemId string, alt string, data io.Reader, version int64) (*inventorypb.InventoryItem, error) {
+	stream, err := inventoryClient.UploadItemImage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	uploadRequest := &inventorypb.UploadItemImageRequest{ItemId: itemId, Alt: alt, ExpectedVersion: version}
+	buffer := make([]byte, IMAGE_CHUNK_SIZE)
+	// The first message carries the settings of the upload, even when the
+	// image is empty.
+	for first := true; ; first = false {
+		n, err := io.ReadFull(data, buffer)
+		if n > 0 || first {
+			uploadRequest.Data = buffer[:n]
+			if err := stream.Send(uploadRequest); err != nil {
+				// The service may have ended the call early, in which case
+				// its error is returned by CloseAndRecv.
+				if err == io.EOF {
+					break
+				}
+				return nil, err
+			}
+			uploadRequest = &inventorypb.UploadItemImageRequest{}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	response, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, err
+	}
+	return response.Item, nil
+}
+
+func SetItemImages(ctx context.Context, itemId string, images []*inventorypb.ItemImage, version int64) (*inventorypb.InventoryItem, error) {
+	imagesRequest := &inventorypb.SetItemImagesRequest{ItemId: itemId, Images: images, ExpectedVersion: version}
+	response, err := inventoryClient.SetItemImages(ctx, imagesRequest)
+	if err != nil {
+		return nil, err
+	}
+	return response.Item, nil
+}
+
+// DownloadItemImage starts streaming an image from the inventory service
+// and returns its media type and a reader for its content. Errors such as
+// a missing image are returned here rather than by the reader.
+func DownloadItemImage(ctx context.Context, itemId string, imageId string) (string, io.Reader, error) {
+	downloadRequest := &inventorypb.DownloadItemImageRequest{ItemId: itemId, ImageId: imageId}
+	stream, err := inventoryClient.DownloadItemImage(ctx, downloadRequest)
+	if err != nil {
+		return "", nil, err
+	}
+	first, err := stream.Recv()
+	if err != nil {
+		return "", nil, err
+	}
+	return first.ContentType, &imageReader{stream: stream, data: first.Data}, nil
+}
+
+// imageReader reads an image from the chunks sent by the inventory service.
+type imageReader struct {
+	stream inventorypb.InventoryService_DownloadItemImageClient
+	data   []byte
+}
+
+func (r *imageReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		chunk, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.data = chunk.Data
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// SchedulePrice schedules a price for an item from effectiveFrom, or now
+// when it is nil, until effectiveTo, or until it is cancelled when that is
+// nil.
+func SchedulePrice(ctx context.Context, itemId string, price *inventorypb.Money, compareAtPrice *inventorypb.Money, effectiveFrom *timestamppb.Timestamp, effectiveTo *timestamppb.Timestamp) (*inventorypb.ScheduledPrice, error) {
+	scheduleRequest := &inventorypb.SchedulePriceRequest{
+		ItemId:         itemId,
+		Price:          price,
+		CompareAtPrice: compareAtPrice,
+		EffectiveFrom:  effectiveFrom,
+		EffectiveTo:    effectiveTo,
+	}
+	response, err := inventoryClient.SchedulePrice(ctx, scheduleRequest)
+	return response.GetScheduledPrice(), err
+}
+
+func CancelScheduledPrice(ctx context.Context, priceId string) (*inventorypb.ScheduledPrice, error) {
+	cancelRequest := &inventorypb.CancelScheduledPriceRequest{Id: priceId}
+	response, err := inventoryClient.CancelScheduledPrice(ctx, cancelRequest)
+	return response.GetScheduledPrice(), err
+}
+
+func GetCurrentPrice(ctx context.Context, itemId string) (*inventorypb.GetCurrentPriceResponse, error) {
+	return inventoryClient.GetCurrentPrice(ctx, &inventorypb.GetCurrentPriceRequest{ItemId: itemId})
+}
+
+func GetPriceHistory(ctx context.Context, itemId string) ([]*inventorypb.ScheduledPrice, error) {
+	response, err := inventoryClient.GetPriceHistory(ctx, &inventorypb.GetPriceHistoryRequest{ItemId: itemId})
+	return response.GetPrices(), err
+}
+
+// ListCategories returns the direct children of parentId, or the top-level
+// categories when parentId is empty.
+func ListCategories(ctx context.Context, parentId string) ([]*inventorypb.Category, error) {
+	listRequest := &inventorypb.ListCategoriesRequest{ParentId: parentId, Root: parentId == ""}
+	stream, err := inventoryClient.ListCategories(ctx, listRequest)
+	if err != nil {
+		return nil, err
+	}
+	var categories []*inventorypb.Category
+	for {
+		category, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, nil
+}
+
+func GetCategory(ctx context.Context, categoryId string) (*inventorypb.Category, error) {
+	categoryRequest := &inventorypb.GetCategoryRequest{Id: categoryId}
+	response, err := inventoryClient.GetCategory(ctx, categoryRequest)
+	return response.GetCategory(), err
+}
+
+func CreateCategory(ctx context.Context, name string, parentId string) (*inventorypb.Category, error) {
+	categoryRequest := &inventorypb.CreateCategoryRequest{Name: name, ParentId: parentId}
+	response, err := inventoryClient.CreateCategory(ctx, categoryRequest)
+	return response.GetCategory(), err
+}
+
+func RenameCategory(ctx context.Context, categoryId string, name string) (*inventorypb.Category, error) {
+	categoryRequest := &inventorypb.RenameCategoryRequest{Id: categoryId, Name: name}
+	response, err := inventoryClient.RenameCategory(ctx, categoryRequest)
+	return response.GetCategory(), err
+}
+
+func MoveCategory(ctx context.Context, categoryId string, parentId string) (*inventorypb.Category, error) {
+	categoryRequest := &inventorypb.MoveCategoryRequest{Id: categoryId, ParentId: parentId}
+	response, err := inventoryClient.MoveCategory(ctx, categoryRequest)
+	return response.GetCategory(), err
+}
+
+func DeleteCategory(ctx context.Context, categoryId string) error {
+	categoryRequest := &inventorypb.DeleteCategoryRequest{Id: categoryId}
+	_, err := inventoryClient.DeleteCategory(ctx, categoryRequest)
+	return err
+}
+
+func ListLocations(ctx context.Context) ([]*inventorypb.Location, error) {
+	stream, err := inventoryClient.ListLocations(ctx, &inventorypb.ListLocationsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	var locations []*inventorypb.Location
+	for {
+		location, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		locations = append(locations, location)
+	}
+	return locations, nil
+}
+
+func GetLocation(ctx context.Context, locationId string) (*inventorypb.Location, error) {
+	locationRequest := &inventorypb.GetLocationRequest{Id: locationId}
+	response, err := inventoryClient.GetLocation(ctx, locationRequest)
+	return response.GetLocation(), err
+}
+
+func CreateLocation(ctx context.Context, name string) (*inventorypb.Location, error) {
+	locationRequest := &inventorypb.CreateLocationRequest{Name: name}
+	response, err := inventoryClient.CreateLocation(ctx, locationRequest)
+	return response.GetLocation(), err
+}
+
+func RenameLocation(ctx context.Context, locationId string, name string) (*inventorypb.Location, error) {
+	locationRequest := &inventorypb.RenameLocationRequest{Id: locationId, Name: name}
+	response, err := inventoryClient.RenameLocation(ctx, locationRequest)
+	return response.GetLocation(), err
+}
+
+func DeleteLocation(ctx context.Context, locationId string) error {
+	locationRequest := &inventorypb.DeleteLocationRequest{Id: locationId}
+	_, err := inventoryClient.DeleteLocation(ctx, locationRequest)
+	return err
+}
+
+// TransferStock moves stock of an item, or of one of its variants, between
+// two locations and returns the updated item.
+func TransferStock(ctx context.Context, itemId string, variantId string, fromLocationId string, toLocationId string, quantity int32, version int64) (*inventorypb.InventoryItem, error) {
+	transferRequest := &inventorypb.TransferStockRequest{
+		ItemId:          itemId,
+		VariantId:       variantId,
+		FromLocationId:  fromLocationId,
+		ToLocationId:    toLocationId,
+		Quantity:        quantity,
+		ExpectedVersion: version,
+	}
+	response, err := inventoryClient.TransferStock(ctx, transferRequest)
+	return response.GetItem(), err
+}
+
+// ListStockMovements returns the stock ledger of an item, oldest first,
+// optionally narrowed to a variant and a location.
+func ListStockMovements(ctx context.Context, itemId string, variantId string, locationId string) ([]*inventorypb.StockMovement, error) {
+	listRequest := &inventorypb.ListStockMovementsRequest{ItemId: itemId, VariantId: variantId, LocationId: locationId}
+	stream, err := inventoryClient.ListStockMovements(ctx, listRequest)
+	if err != nil {
+		return nil, err
+	}
+	var movements []*inventorypb.StockMovement
+	for {
+		movement, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		movements = append(movements, movement)
+	}
+	return movements, nil
+}
+
+func ListLowStockItems(ctx context.Context) ([]*inventorypb.InventoryItem, error) {
+	stream, err := inventoryClient.ListLowStockItems(ctx, &inventorypb.ListLowStockItemsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	var items []*inventorypb.InventoryItem
+	for {
+		item, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// WatchInventory sends the changes to the given items, or to all items, on
+// the returned channel until ctx is done or the watch fails, then closes it.
+func WatchInventory(ctx context.Context, itemIds []string, types []inventorypb.ItemChangeType, resumeToken string) (<-chan *inventorypb.ItemChange, error) {
+	watchRequest := &inventorypb.WatchInventoryRequest{ItemIds: itemIds, Types: types, ResumeToken: resumeToken}
+	stream, err := inventoryClient.WatchInventory(ctx, watchRequest)
+	if err != nil {
+		return nil, err
+	}
+	// The service sends its headers once it accepted the request, so that
+	// invalid requests fail here rather than on the first change.
+	if _, err := stream.Header(); err != nil {
+		return nil, err
+	}
+	changes := make(chan *inventorypb.ItemChange)
+	go func() {
+		defer close(changes)
+		for {
+			change, err := stream.Recv()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Println("Inventory watch ended:", err)
+				}
+				return
+			}
+			select {
+			case changes <- change:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return changes, nil
+}
+
+func CreateUser(ctx context.Context, username string, password string) (string, error) {
 	userRequest := &authenticationpb.CreateUserRequest{User: &authenticationpb.User{Username: username, Password: password}}
-	userId, err := authenticationClient.CreateUser(context.Background(), userRequest)
+	userId, err := authenticationClient.CreateUser(ctx, userRequest)
 	return userId.GetError(), err
 }
 
-func Login(username string, password string) (string, error) {
+func Login(ctx context.Context, username string, password string) (string, error) {
 	userRequest := &authenticationpb.AuthenticateRequest{User: &authenticationpb.User{Username: username, Password: password}}
-	userId, err := authenticationClient.Authenticate(context.Background(), userRequest)
+	userId, err := authenticationClient.Authenticate(ctx, userRequest)
 	return userId.GetToken(), err
 }
 
-func ValidateToken(token string) (string, error) {
+func ValidateToken(ctx context.Context, token string) (string, error) {
 	userRequest := &authenticationpb.ValidateTokenRequest{Token: token}
-	userId, err := authenticationClient.ValidateToken(context.Background(), userRequest)
+	userId, err := authenticationClient.ValidateToken(ctx, userRequest)
 	return userId.GetUsername(), err
 }